@@ -0,0 +1,215 @@
+// Package binance is a small client for Binance's public spot REST API.
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL = "https://api.binance.com"
+	// Binance's default request weight allowance per IP per minute
+	DefaultWeightLimit = 6000
+)
+
+// Client calls Binance's public endpoints. It retries transient failures with
+// jittered backoff and tracks the request weight Binance reports, holding
+// requests back rather than risking a ban. Safe for concurrent use.
+type Client struct {
+	BaseURL    string
+	HTTP       *http.Client
+	MaxRetries int           // retries after the first attempt
+	RetryBase  time.Duration // first backoff; doubles per retry
+	RetryMax   time.Duration
+	// WeightLimit is the per-minute weight budget; requests wait for the next
+	// minute once UsedWeight reaches it.
+	WeightLimit int
+
+	mu          sync.Mutex
+	usedWeight  int
+	weightAt    time.Time // minute the used weight belongs to
+	bannedUntil time.Time
+}
+
+// NewClient returns a client for baseURL (DefaultBaseURL if empty) with a 10s
+// timeout per attempt and up to 3 retries.
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:     strings.TrimRight(baseURL, "/"),
+		HTTP:        &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:  3,
+		RetryBase:   250 * time.Millisecond,
+		RetryMax:    5 * time.Second,
+		WeightLimit: DefaultWeightLimit,
+	}
+}
+
+// UsedWeight is the request weight used in the current minute, as last
+// reported by Binance.
+func (c *Client) UsedWeight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Truncate(time.Minute).After(c.weightAt) {
+		return 0
+	}
+	return c.usedWeight
+}
+
+// Get calls a public GET endpoint and decodes the JSON reply into out.
+func (c *Client) Get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	u := c.BaseURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.waitForWeight(ctx); err != nil {
+			return err
+		}
+		var body []byte
+		body, err = c.do(ctx, u)
+		if err == nil {
+			if jerr := json.Unmarshal(body, out); jerr != nil {
+				return &DecodeError{Path: path, Err: jerr}
+			}
+			return nil
+		}
+
+		wait, retry := c.retryable(err, attempt)
+		if !retry || attempt >= c.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	c.trackWeight(resp)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return body, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot:
+		retryAfter := time.Minute
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(s) * time.Second
+		}
+		banned := resp.StatusCode == http.StatusTeapot
+		if banned {
+			c.mu.Lock()
+			c.bannedUntil = time.Now().Add(retryAfter)
+			c.mu.Unlock()
+		}
+		return nil, &RateLimitError{RetryAfter: retryAfter, Banned: banned}
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	json.Unmarshal(body, apiErr)
+	return nil, apiErr
+}
+
+// retryable decides whether err is worth another attempt and how long to wait
+func (c *Client) retryable(err error, attempt int) (time.Duration, bool) {
+	var rl *RateLimitError
+	var apiErr *APIError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &rl):
+		// A short 429 back-off is worth waiting out; a ban is not
+		return rl.RetryAfter, !rl.Banned && rl.RetryAfter <= c.RetryMax
+	case errors.As(err, &apiErr):
+		return c.backoff(attempt), apiErr.StatusCode >= 500 ||
+			apiErr.Code == CodeUnknown || apiErr.Code == CodeDisconnected || apiErr.Code == CodeTimeout
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return 0, false
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return c.backoff(attempt), true
+	}
+	return 0, false
+}
+
+// backoff is exponential with full jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.RetryBase << attempt
+	if d <= 0 || d > c.RetryMax {
+		d = c.RetryMax
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// trackWeight records X-MBX-USED-WEIGHT-1M from a response
+func (c *Client) trackWeight(resp *http.Response) {
+	used, err := strconv.Atoi(resp.Header.Get("X-Mbx-Used-Weight-1m"))
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	minute := time.Now().Truncate(time.Minute)
+	if minute.After(c.weightAt) || used > c.usedWeight {
+		c.usedWeight, c.weightAt = used, minute
+	}
+}
+
+// waitForWeight blocks until the client is allowed to send: not banned and
+// under the weight limit for the current minute.
+func (c *Client) waitForWeight(ctx context.Context) error {
+	c.mu.Lock()
+	now := time.Now()
+	var until time.Time
+	if now.Before(c.bannedUntil) {
+		banned := c.bannedUntil
+		c.mu.Unlock()
+		return &RateLimitError{RetryAfter: banned.Sub(now), Banned: true}
+	}
+	if c.WeightLimit > 0 && !now.Truncate(time.Minute).After(c.weightAt) && c.usedWeight >= c.WeightLimit {
+		until = c.weightAt.Add(time.Minute)
+	}
+	c.mu.Unlock()
+	if until.IsZero() {
+		return nil
+	}
+
+	wait := until.Sub(now)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(until) {
+		return &RateLimitError{RetryAfter: wait}
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
@@ -0,0 +1,302 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer answers the n-th request (1-based) with respond(n) and counts requests
+func testServer(t *testing.T, respond func(n int, w http.ResponseWriter)) (*Client, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(int(atomic.AddInt32(&hits, 1)), w)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.URL)
+	c.RetryBase = time.Millisecond
+	c.RetryMax = 10 * time.Millisecond
+	return c, &hits
+}
+
+func apiError(w http.ResponseWriter, status, code int) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"code":%d,"msg":"test error"}`, code)
+}
+
+func TestGetRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		respond  func(n int, w http.ResponseWriter)
+		wantHits int32
+		check    func(t *testing.T, err error)
+	}{
+		{
+			name: "5xx retried until success",
+			respond: func(n int, w http.ResponseWriter) {
+				if n < 3 {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				fmt.Fprint(w, `{"price":"1"}`)
+			},
+			wantHits: 3,
+		},
+		{
+			name:     "-1001 retried until retries run out",
+			respond:  func(n int, w http.ResponseWriter) { apiError(w, http.StatusInternalServerError, CodeDisconnected) },
+			wantHits: 4,
+			check: func(t *testing.T, err error) {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) || apiErr.Code != CodeDisconnected {
+					t.Fatalf("err = %v, want APIError %d", err, CodeDisconnected)
+				}
+			},
+		},
+		{
+			name:     "-1121 not retried",
+			respond:  func(n int, w http.ResponseWriter) { apiError(w, http.StatusBadRequest, CodeInvalidSymbol) },
+			wantHits: 1,
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrInvalidSymbol) {
+					t.Fatalf("err = %v, want ErrInvalidSymbol", err)
+				}
+				if errors.Is(err, ErrInvalidInterval) {
+					t.Fatal("err matches ErrInvalidInterval")
+				}
+			},
+		},
+		{
+			name: "short 429 waited out",
+			respond: func(n int, w http.ResponseWriter) {
+				if n == 1 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, `{"price":"1"}`)
+			},
+			wantHits: 2,
+		},
+		{
+			name: "long 429 returned",
+			respond: func(n int, w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "30")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			wantHits: 1,
+			check: func(t *testing.T, err error) {
+				var rl *RateLimitError
+				if !errors.As(err, &rl) || rl.Banned || rl.RetryAfter != 30*time.Second {
+					t.Fatalf("err = %v, want 30s rate limit", err)
+				}
+				if !errors.Is(err, ErrTooManyRequests) {
+					t.Fatal("rate limit doesn't match ErrTooManyRequests")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, hits := testServer(t, tt.respond)
+			var out struct{ Price string }
+			err := c.Get(context.Background(), "/api/v3/ticker/price", nil, &out)
+			if got := atomic.LoadInt32(hits); got != tt.wantHits {
+				t.Errorf("attempts = %d, want %d", got, tt.wantHits)
+			}
+			if tt.check != nil {
+				tt.check(t, err)
+			} else if err != nil || out.Price != "1" {
+				t.Fatalf("Get = %v, price %q", err, out.Price)
+			}
+		})
+	}
+}
+
+func TestGetBanned(t *testing.T) {
+	c, hits := testServer(t, func(n int, w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	for i := 0; i < 2; i++ {
+		err := c.Get(context.Background(), "/api/v3/ping", nil, &struct{}{})
+		var rl *RateLimitError
+		if !errors.As(err, &rl) || !rl.Banned {
+			t.Fatalf("call %d: err = %v, want ban", i+1, err)
+		}
+		if rl.RetryAfter <= 0 || rl.RetryAfter > 120*time.Second {
+			t.Fatalf("call %d: retry after %s", i+1, rl.RetryAfter)
+		}
+	}
+	// The second call is refused locally while the ban lasts
+	if got := atomic.LoadInt32(hits); got != 1 {
+		t.Fatalf("requests = %d, want 1", got)
+	}
+}
+
+// awayFromMinuteEnd waits out the end of the minute, so weight tracked now
+// still applies when the test checks it
+func awayFromMinuteEnd() {
+	if left := time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)); left < 2*time.Second {
+		time.Sleep(left + 10*time.Millisecond)
+	}
+}
+
+func TestWeightTracking(t *testing.T) {
+	awayFromMinuteEnd()
+	c, hits := testServer(t, func(n int, w http.ResponseWriter) {
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", fmt.Sprint(100*n))
+		fmt.Fprint(w, `{}`)
+	})
+	c.WeightLimit = 200
+
+	ctx := context.Background()
+	if err := c.Get(ctx, "/api/v3/ping", nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.UsedWeight(); got != 100 {
+		t.Fatalf("UsedWeight = %d, want 100", got)
+	}
+	if err := c.Get(ctx, "/api/v3/ping", nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.UsedWeight(); got != 200 {
+		t.Fatalf("UsedWeight = %d, want 200", got)
+	}
+
+	// At the limit, a caller that can't wait for the next minute is refused
+	// without a request
+	short, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err := c.Get(short, "/api/v3/ping", nil, &struct{}{})
+	var rl *RateLimitError
+	if !errors.As(err, &rl) || rl.Banned || rl.RetryAfter <= 0 || rl.RetryAfter > time.Minute {
+		t.Fatalf("err = %v, want a wait for the next minute", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+
+	// A caller without a deadline waits, until cancelled
+	cancelled, cancel2 := context.WithCancel(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel2)
+	if err := c.waitForWeight(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("waitForWeight = %v, want context.Canceled", err)
+	}
+
+	// Last minute's weight doesn't count
+	c.mu.Lock()
+	c.weightAt = c.weightAt.Add(-time.Minute)
+	c.mu.Unlock()
+	if got := c.UsedWeight(); got != 0 {
+		t.Fatalf("UsedWeight after the minute = %d, want 0", got)
+	}
+	if err := c.waitForWeight(short); err != nil {
+		t.Fatalf("waitForWeight after the minute = %v", err)
+	}
+}
+
+func TestGetCancelDuringBackoff(t *testing.T) {
+	c, _ := testServer(t, func(n int, w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c.RetryBase, c.RetryMax = time.Hour, time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err := c.Get(ctx, "/api/v3/ping", nil, &struct{}{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("Get returned after %s, want it to stop at cancellation", d)
+	}
+}
+
+func TestGetDecodeError(t *testing.T) {
+	c, _ := testServer(t, func(n int, w http.ResponseWriter) {
+		fmt.Fprint(w, `[[1499040000000,"0.01634790"]]`)
+	})
+	_, err := c.Klines(context.Background(), KlinesRequest{Symbol: "BTCUSDT", Interval: "1m"})
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Path != "/api/v3/klines" {
+		t.Fatalf("err = %v, want DecodeError for /api/v3/klines", err)
+	}
+}
+
+func TestKlineUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Kline
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			in:   `[1499040000000,"0.01634790","0.80000000","0.01575800","0.01577100","148976.11427815",1499644799999,"2434.19055334",308,"1756.87402397","28.46694368","0"]`,
+			want: Kline{OpenTime: 1499040000000, Open: 0.0163479, High: 0.8, Low: 0.015758, Close: 0.015771,
+				Volume: 148976.11427815, CloseTime: 1499644799999, Trades: 308},
+		},
+		{name: "too few fields", in: `[1499040000000,"1","2","3","4","5",1499644799999,"6"]`, wantErr: true},
+		{name: "not an array", in: `{"openTime":1499040000000}`, wantErr: true},
+		{name: "quoted open time", in: `["1499040000000","1","2","3","4","5",1499644799999,"6",308]`, wantErr: true},
+		{name: "unquoted price", in: `[1499040000000,1,"2","3","4","5",1499644799999,"6",308]`, wantErr: true},
+		{name: "non-numeric price", in: `[1499040000000,"1","2","x","4","5",1499644799999,"6",308]`, wantErr: true},
+		{name: "fractional trade count", in: `[1499040000000,"1","2","3","4","5",1499644799999,"6",1.5]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var k Kline
+			err := k.UnmarshalJSON([]byte(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decoded %+v, want an error", k)
+				}
+				return
+			}
+			if err != nil || k != tt.want {
+				t.Fatalf("got %+v, %v; want %+v", k, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceLevelUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    PriceLevel
+		wantErr bool
+	}{
+		{name: "valid", in: `["4.00000000","431.00000000"]`, want: PriceLevel{Price: 4, Quantity: 431}},
+		{name: "numbers", in: `[4,431]`, wantErr: true},
+		{name: "missing quantity", in: `["4.00000000"]`, wantErr: true},
+		{name: "non-numeric price", in: `["abc","431"]`, wantErr: true},
+		{name: "object", in: `{"price":"4","qty":"431"}`, wantErr: true},
+		{name: "null pair", in: `[null,null]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l PriceLevel
+			err := l.UnmarshalJSON([]byte(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("decoded %+v, want an error", l)
+				}
+				return
+			}
+			if err != nil || l != tt.want {
+				t.Fatalf("got %+v, %v; want %+v", l, err, tt.want)
+			}
+		})
+	}
+}
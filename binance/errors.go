@@ -0,0 +1,78 @@
+package binance
+
+import (
+	"fmt"
+	"time"
+)
+
+// Binance API error codes we act on. See
+// https://developers.binance.com/docs/binance-spot-api-docs/errors
+const (
+	CodeUnknown         = -1000
+	CodeDisconnected    = -1001
+	CodeTooManyRequests = -1003
+	CodeTimeout         = -1007
+	CodeIllegalChars    = -1100
+	CodeTooManyParams   = -1101
+	CodeMandatoryParam  = -1102
+	CodeBadInterval     = -1120
+	CodeInvalidSymbol   = -1121
+)
+
+// APIError is an error response from Binance: the {code, msg} body and the HTTP status
+type APIError struct {
+	StatusCode int
+	Code       int
+	Msg        string
+}
+
+func (e *APIError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("binance: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("binance error %d: %s", e.Code, e.Msg)
+}
+
+// Is matches API errors by code, so errors.Is(err, binance.ErrInvalidSymbol) works
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code != 0 && t.Code == e.Code
+}
+
+// Sentinels for errors.Is
+var (
+	ErrInvalidSymbol   = &APIError{Code: CodeInvalidSymbol}
+	ErrInvalidInterval = &APIError{Code: CodeBadInterval}
+	ErrTooManyRequests = &APIError{Code: CodeTooManyRequests}
+)
+
+// RateLimitError means Binance is refusing requests (HTTP 429, or 418 once the
+// IP is banned) or the client held back to stay under the weight limit.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Banned     bool
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Banned {
+		return fmt.Sprintf("binance: IP banned, retry after %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("binance: rate limited, retry after %s", e.RetryAfter)
+}
+
+// Is lets a rate-limit error match ErrTooManyRequests
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
+// DecodeError means a response didn't have the expected shape
+type DecodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("binance: decoding %s: %v", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
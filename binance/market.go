@@ -0,0 +1,158 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Kline is one candle from /api/v3/klines
+type Kline struct {
+	OpenTime  int64 // Unix ms
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    float64
+	CloseTime int64 // Unix ms
+	Trades    int64
+}
+
+// UnmarshalJSON decodes Binance's positional array form:
+// [openTime, "open", "high", "low", "close", "volume", closeTime, "quoteVolume", trades, ...]
+func (k *Kline) UnmarshalJSON(b []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if len(fields) < 9 {
+		return fmt.Errorf("kline has %d fields, want at least 9", len(fields))
+	}
+	ints := []struct {
+		i   int
+		dst *int64
+	}{{0, &k.OpenTime}, {6, &k.CloseTime}, {8, &k.Trades}}
+	for _, f := range ints {
+		if err := json.Unmarshal(fields[f.i], f.dst); err != nil {
+			return fmt.Errorf("kline field %d: %w", f.i, err)
+		}
+	}
+	floats := []struct {
+		i   int
+		dst *float64
+	}{{1, &k.Open}, {2, &k.High}, {3, &k.Low}, {4, &k.Close}, {5, &k.Volume}}
+	for _, f := range floats {
+		var s string
+		if err := json.Unmarshal(fields[f.i], &s); err != nil {
+			return fmt.Errorf("kline field %d: %w", f.i, err)
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("kline field %d: %w", f.i, err)
+		}
+		*f.dst = v
+	}
+	return nil
+}
+
+// KlinesRequest selects candles; zero times and limit are left to Binance's defaults
+type KlinesRequest struct {
+	Symbol    string
+	Interval  string
+	StartTime time.Time
+	EndTime   time.Time
+	Limit     int // max 1000
+}
+
+// Klines fetches one page of candles
+func (c *Client) Klines(ctx context.Context, r KlinesRequest) ([]Kline, error) {
+	params := url.Values{"symbol": {r.Symbol}, "interval": {r.Interval}}
+	if !r.StartTime.IsZero() {
+		params.Set("startTime", strconv.FormatInt(r.StartTime.UnixMilli(), 10))
+	}
+	if !r.EndTime.IsZero() {
+		params.Set("endTime", strconv.FormatInt(r.EndTime.UnixMilli(), 10))
+	}
+	if r.Limit > 0 {
+		params.Set("limit", strconv.Itoa(r.Limit))
+	}
+	var klines []Kline
+	if err := c.Get(ctx, "/api/v3/klines", params, &klines); err != nil {
+		return nil, err
+	}
+	return klines, nil
+}
+
+// TickerPrice returns the last traded price of symbol
+func (c *Client) TickerPrice(ctx context.Context, symbol string) (float64, error) {
+	var data struct {
+		Price string `json:"price"`
+	}
+	if err := c.Get(ctx, "/api/v3/ticker/price", url.Values{"symbol": {symbol}}, &data); err != nil {
+		return 0, err
+	}
+	price, err := strconv.ParseFloat(data.Price, 64)
+	if err != nil || price <= 0 {
+		return 0, &DecodeError{Path: "/api/v3/ticker/price", Err: fmt.Errorf("invalid price %q", data.Price)}
+	}
+	return price, nil
+}
+
+// SymbolInfo is the part of exchangeInfo's symbol entry we use
+type SymbolInfo struct {
+	Symbol     string `json:"symbol"`
+	Status     string `json:"status"` // TRADING, BREAK, ...
+	BaseAsset  string `json:"baseAsset"`
+	QuoteAsset string `json:"quoteAsset"`
+}
+
+// ExchangeSymbols lists every symbol on the exchange
+func (c *Client) ExchangeSymbols(ctx context.Context) ([]SymbolInfo, error) {
+	var info struct {
+		Symbols []SymbolInfo `json:"symbols"`
+	}
+	if err := c.Get(ctx, "/api/v3/exchangeInfo", nil, &info); err != nil {
+		return nil, err
+	}
+	return info.Symbols, nil
+}
+
+// PriceLevel is one order book level
+type PriceLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// UnmarshalJSON decodes Binance's ["price", "qty"] pair form
+func (l *PriceLevel) UnmarshalJSON(b []byte) error {
+	var pair [2]string
+	if err := json.Unmarshal(b, &pair); err != nil {
+		return err
+	}
+	var err error
+	if l.Price, err = strconv.ParseFloat(pair[0], 64); err != nil {
+		return err
+	}
+	l.Quantity, err = strconv.ParseFloat(pair[1], 64)
+	return err
+}
+
+// DepthSnapshot is an order book snapshot from /api/v3/depth
+type DepthSnapshot struct {
+	LastUpdateID int64        `json:"lastUpdateId"`
+	Bids         []PriceLevel `json:"bids"`
+	Asks         []PriceLevel `json:"asks"`
+}
+
+// Depth fetches the top limit levels of symbol's order book (max 5000)
+func (c *Client) Depth(ctx context.Context, symbol string, limit int) (*DepthSnapshot, error) {
+	var snap DepthSnapshot
+	params := url.Values{"symbol": {symbol}, "limit": {strconv.Itoa(limit)}}
+	if err := c.Get(ctx, "/api/v3/depth", params, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
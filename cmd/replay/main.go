@@ -0,0 +1,101 @@
+// Command replay plays a market recording (see MARKET_RECORD_DIR) back
+// through the market stream handlers, serving /trading WebSocket clients as
+// the live server would, and can settle trades again against it to check a
+// disputed outcome.
+//
+//	go run ./cmd/replay -from 2026-10-19T14:00:00Z -to 2026-10-19T14:30:00Z -speed 10 -trades 1042,1043
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+func main() {
+	godotenv.Load()
+
+	dir := flag.String("dir", os.Getenv("MARKET_RECORD_DIR"), "recording directory")
+	from := flag.String("from", "", "start of the range (RFC3339), required")
+	to := flag.String("to", "", "end of the range (RFC3339); default the end of the recording")
+	symbols := flag.String("symbols", "", "comma-separated symbols to replay; default all")
+	speed := flag.Float64("speed", 1, "playback speed: 1 real time, 10 ten times faster, 0 as fast as possible")
+	addr := flag.String("addr", ":8090", "address to serve the /trading WebSocket on; empty to disable")
+	wait := flag.Duration("wait", 0, "pause before starting, to let clients connect")
+	tradeIDs := flag.String("trades", "", "comma-separated trade IDs to settle again (reads the database)")
+	flag.Parse()
+
+	opts := services.ReplayOptions{Dir: *dir, Speed: *speed}
+	var err error
+	if opts.From, err = time.Parse(time.RFC3339, *from); err != nil {
+		log.Fatal("-from: ", err)
+	}
+	if *to != "" {
+		if opts.To, err = time.Parse(time.RFC3339, *to); err != nil {
+			log.Fatal("-to: ", err)
+		}
+	}
+	if opts.Dir == "" {
+		log.Fatal("-dir or MARKET_RECORD_DIR is required")
+	}
+	for _, s := range strings.Split(*symbols, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			opts.Symbols = append(opts.Symbols, strings.ToUpper(s))
+		}
+	}
+
+	var trades []models.Trade
+	if *tradeIDs != "" {
+		var ids []uint
+		for _, s := range strings.Split(*tradeIDs, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				log.Fatalf("-trades: invalid ID %q", s)
+			}
+			ids = append(ids, uint(id))
+		}
+		config.ConnectDB()
+		if err := config.DB.Where("id IN ?", ids).Order("expired_at").Find(&trades).Error; err != nil {
+			log.Fatal("load trades: ", err)
+		}
+		if len(trades) != len(ids) {
+			log.Printf("found %d of %d trades", len(trades), len(ids))
+		}
+	}
+
+	if *addr != "" {
+		http.HandleFunc("/trading", services.ServeWS)
+		go func() {
+			log.Fatal(http.ListenAndServe(*addr, nil))
+		}()
+		log.Printf("serving /trading on %s", *addr)
+	}
+	time.Sleep(*wait)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	started := time.Now()
+	results, err := services.ReplayMarket(ctx, opts, trades)
+	if err != nil {
+		log.Printf("replay stopped: %v", err)
+	}
+	log.Printf("replay finished in %s", time.Since(started).Round(time.Millisecond))
+
+	for _, r := range results {
+		out, _ := json.Marshal(r)
+		fmt.Println(string(out))
+	}
+}
@@ -46,3 +46,17 @@ func (h *Hub) SendToUser(userID uint, msg string) {
 		}
 	}
 }
+
+// Broadcast sends a message to every connected user
+func (h *Hub) Broadcast(msg string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for userID, conns := range h.clients {
+		for conn := range conns {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				conn.Close()
+				delete(h.clients[userID], conn)
+			}
+		}
+	}
+}
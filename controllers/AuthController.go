@@ -1,18 +1,28 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"log"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/solchef/crypto-options-backend/config"
 	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
 	"github.com/solchef/crypto-options-backend/utils"
 	"gorm.io/gorm"
 )
 
+var (
+	errAccountFrozen = errors.New("account is frozen")
+	errRefreshReused = errors.New("refresh token reused")
+)
+
 // --- helpers ---
 func setRefreshCookie(c *gin.Context, token string, exp time.Time) {
 	secure := os.Getenv("COOKIE_SECURE") == "true"
@@ -38,16 +48,29 @@ func clearRefreshCookie(c *gin.Context) {
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param user body models.User true "User info"
+// @Param user body object{username=string,email=string,password=string} true "User info"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Router /auth/register [post]
 func Register(c *gin.Context) {
-	var input models.User
-	if err := c.ShouldBindJSON(&input); err != nil {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !emailAcceptable(c, req.Email) {
+		return
+	}
+	input := models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+		Role:     models.RoleUser,
+	}
 
 	// Check if username already exists
 	var existing models.User
@@ -70,14 +93,10 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	defaultCurrencies := []string{"USD", "BTC", "ETH"}
-	for _, currency := range defaultCurrencies {
-		wallet := models.Wallet{
-			UserID:   input.ID,
-			Currency: currency,
-			Balance:  0,
-		}
-		config.DB.Create(&wallet)
+	services.CreateDefaultWallets(input.ID)
+
+	if err := services.SendVerificationEmail(&input); err != nil {
+		log.Println("verification email:", err)
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
@@ -85,7 +104,7 @@ func Register(c *gin.Context) {
 
 // Login godoc
 // @Summary Login a user
-// @Description Authenticate and get JWT tokens
+// @Description Authenticate and get JWT tokens. If 2FA is enabled the response is {mfa_required, mfa_token}; finish with /auth/login/2fa
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -104,19 +123,61 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	fmt.Print(input)
-
 	var user models.User
 	if err := config.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
+		audit(c, 0, services.AuditLoginFailed, "user", input.Username, nil, gin.H{"reason": "unknown user"})
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	if lockedFor := services.LoginLockedFor(&user); lockedFor > 0 {
+		accountLocked(c, lockedFor)
+		return
+	}
 	// password hashing check you already have
 	if !utils.CheckPasswordHash(input.Password, user.Password) {
+		audit(c, 0, services.AuditLoginFailed, "user", user.ID, nil, gin.H{"reason": "bad password"})
+		if lockedFor := services.RecordFailedLogin(&user); lockedFor > 0 {
+			accountLocked(c, lockedFor)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	if user.Frozen {
+		audit(c, 0, services.AuditLoginFailed, "user", user.ID, nil, gin.H{"reason": "frozen"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is frozen"})
+		return
+	}
 
+	// Second factor: hand back a short-lived challenge instead of tokens
+	if user.TOTPEnabled {
+		// failures are reset only once the OTP step passes too
+		challenge, exp, err := utils.NewMFAChallengeToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "challenge token error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_at":   exp.Unix(),
+		})
+		return
+	}
+
+	services.ResetFailedLogins(&user)
+	issueSession(c, user)
+}
+
+// accountLocked writes the 429 response for a login-locked account.
+func accountLocked(c *gin.Context, d time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, account temporarily locked"})
+}
+
+// issueSession creates an access + refresh token pair for a fully authenticated
+// user and writes the login response.
+func issueSession(c *gin.Context, user models.User) {
 	// access + refresh
 	access, accessExp, err := utils.NewAccessToken(user.ID, user.Username)
 	if err != nil {
@@ -129,12 +190,18 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// persist refresh
+	// persist refresh; a login starts a new token family (session)
+	now := time.Now()
 	rt := models.RefreshToken{
-		UserID:    user.ID,
-		JTI:       jti,
-		ExpiresAt: refreshExp,
-		Revoked:   false,
+		UserID:           user.ID,
+		JTI:              jti,
+		FamilyID:         uuid.NewString(),
+		ExpiresAt:        refreshExp,
+		Revoked:          false,
+		UserAgent:        c.Request.UserAgent(),
+		IP:               c.ClientIP(),
+		SessionStartedAt: now,
+		LastUsedAt:       now,
 	}
 	if err := config.DB.Create(&rt).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist refresh"})
@@ -146,6 +213,8 @@ func Login(c *gin.Context) {
 		setRefreshCookie(c, refresh, refreshExp)
 	}
 
+	audit(c, user.ID, services.AuditLogin, "user", user.ID, nil, gin.H{"jti": jti})
+
 	c.JSON(http.StatusOK, gin.H{
 		"access_token": access,
 		"expires_at":   accessExp.Unix(),
@@ -192,25 +261,50 @@ func Refresh(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh not found"})
 		return
 	}
-	if dbRT.Revoked || time.Now().After(dbRT.ExpiresAt) {
+	if dbRT.Revoked {
+		refreshReused(c, dbRT)
+		return
+	}
+	if time.Now().After(dbRT.ExpiresAt) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh revoked or expired"})
 		return
 	}
 
-	// rotate: revoke old, create new
+	// rotate: revoke old, create new in the same family
+	family := dbRT.FamilyID
+	if family == "" {
+		family = dbRT.JTI
+	}
+	started := dbRT.SessionStartedAt
+	if started.IsZero() {
+		started = dbRT.CreatedAt
+	}
 	err = config.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&dbRT).Update("revoked", true).Error; err != nil {
-			return err
+		// Conditional so two concurrent refreshes with one token can't both
+		// rotate. A legacy token joins the family it starts.
+		res := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked = ?", dbRT.ID, false).
+			Updates(map[string]interface{}{"revoked": true, "family_id": family})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errRefreshReused
 		}
 		newRefresh, newJTI, newExp, err := utils.NewRefreshToken(claims.Sub)
 		if err != nil {
 			return err
 		}
 		if err := tx.Create(&models.RefreshToken{
-			UserID:    claims.Sub,
-			JTI:       newJTI,
-			ExpiresAt: newExp,
-			Revoked:   false,
+			UserID:           claims.Sub,
+			JTI:              newJTI,
+			FamilyID:         family,
+			ExpiresAt:        newExp,
+			Revoked:          false,
+			UserAgent:        c.Request.UserAgent(),
+			IP:               c.ClientIP(),
+			SessionStartedAt: started,
+			LastUsedAt:       time.Now(),
 		}).Error; err != nil {
 			return err
 		}
@@ -220,6 +314,9 @@ func Refresh(c *gin.Context) {
 		if err := tx.First(&user, claims.Sub).Error; err != nil {
 			return err
 		}
+		if user.Frozen {
+			return errAccountFrozen
+		}
 		access, accessExp, err := utils.NewAccessToken(user.ID, user.Username)
 		if err != nil {
 			return err
@@ -230,6 +327,8 @@ func Refresh(c *gin.Context) {
 			setRefreshCookie(c, newRefresh, newExp)
 		}
 
+		audit(c, user.ID, services.AuditTokenRefresh, "refresh_token", dbRT.JTI, nil, gin.H{"jti": newJTI})
+
 		c.JSON(http.StatusOK, gin.H{
 			"access_token": access,
 			"expires_at":   accessExp.Unix(),
@@ -237,11 +336,30 @@ func Refresh(c *gin.Context) {
 		})
 		return nil
 	})
+	if errors.Is(err, errRefreshReused) {
+		refreshReused(c, dbRT)
+		return
+	}
+	if errors.Is(err, errAccountFrozen) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is frozen"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh failed"})
 	}
 }
 
+// refreshReused handles a rotated-out refresh token being presented again:
+// either the client or an attacker holds a stolen copy, so the whole family dies.
+func refreshReused(c *gin.Context, rt models.RefreshToken) {
+	if err := services.RevokeTokenFamily(rt); err != nil {
+		log.Println("revoke token family:", err)
+	}
+	audit(c, 0, services.AuditRefreshReuse, "refresh_token", rt.JTI, nil, gin.H{"user_id": rt.UserID, "family_id": rt.FamilyID})
+	clearRefreshCookie(c)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, session revoked"})
+}
+
 // Logout godoc
 // @Summary Logout user
 // @Description Revoke refresh token and clear cookie
@@ -266,21 +384,9 @@ func Logout(c *gin.Context) {
 			config.DB.Model(&models.RefreshToken{}).
 				Where("jti = ? AND user_id = ?", claims.JTI, claims.Sub).
 				Update("revoked", true)
+			audit(c, claims.Sub, services.AuditLogout, "refresh_token", claims.JTI, nil, nil)
 		}
 	}
 	clearRefreshCookie(c)
 	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
 }
-
-// Profile godoc
-// @Summary Get user profile
-// @Description Get authenticated user info
-// @Tags auth
-// @Produce json
-// @Success 200 {object} map[string]string
-// @Security ApiKeyAuth
-// @Router /profile [get]
-func Profile(c *gin.Context) {
-	username, _ := c.Get("username")
-	c.JSON(http.StatusOK, gin.H{"message": "Welcome to your profile", "username": username})
-}
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Consume the token from the verification email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body object{token=string} true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ut, err := services.ConsumeUserToken(req.Token, models.TokenPurposeEmailVerify)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only verify the address the token was sent to, in case it changed since
+	res := config.DB.Model(&models.User{}).
+		Where("id = ? AND email = ?", ut.UserID, ut.Email).
+		Update("email_verified", true)
+	if res.Error != nil || res.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidUserToken.Error()})
+		return
+	}
+	audit(c, ut.UserID, services.AuditEmailVerify, "user", ut.UserID, nil, gin.H{"email": ut.Email})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /account/verify-email/resend [post]
+func ResendVerification(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.EmailVerified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already verified"})
+		return
+	}
+	if err := services.SendVerificationEmail(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Always returns 200 so the endpoint can't be used to probe for accounts
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body object{email=string} true "Account email"
+// @Success 200 {object} map[string]string
+// @Router /auth/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err == nil && !user.Frozen {
+		if err := services.SendPasswordResetEmail(&user); err != nil {
+			log.Println("password reset email:", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a reset link has been sent"})
+}
+
+// ResetPassword godoc
+// @Summary Reset password with a token
+// @Description Sets a new password and revokes every refresh token for the account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body object{token=string,password=string} true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Reject a bad password before the token is used up
+	if !passwordAcceptable(c, req.Password) {
+		return
+	}
+
+	ut, err := services.ConsumeUserToken(req.Token, models.TokenPurposePasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, ut.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidUserToken.Error()})
+		return
+	}
+	if !setPassword(c, &user, req.Password) {
+		return
+	}
+	audit(c, user.ID, services.AuditPasswordReset, "user", user.ID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Requires the current password (and a 2FA code when enabled). Signs out all sessions
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body object{current_password=string,new_password=string,otp=string} true "Passwords"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /account/password [post]
+func ChangePassword(c *gin.Context) {
+	var req struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+		OTP             string `json:"otp"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if !requireStepUp(c, user.ID, req.OTP) {
+		return
+	}
+	if !setPassword(c, &user, req.NewPassword) {
+		return
+	}
+	clearRefreshCookie(c)
+	audit(c, user.ID, services.AuditPasswordChange, "user", user.ID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated, please log in again"})
+}
+
+// passwordAcceptable checks a new password against the password rules,
+// writing a 400 and returning false if it fails them
+func passwordAcceptable(c *gin.Context, password string) bool {
+	if len(password) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters"})
+		return false
+	}
+	return true
+}
+
+// emailAcceptable checks that email is a bare address (no display name),
+// writing a 400 and returning false if it isn't
+func emailAcceptable(c *gin.Context, email string) bool {
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return false
+	}
+	return true
+}
+
+// setPassword hashes and stores a new password, revokes all sessions and sends
+// a notification. It writes the error response and returns false on failure.
+func setPassword(c *gin.Context, user *models.User, password string) bool {
+	if !passwordAcceptable(c, password) {
+		return false
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return false
+	}
+	if err := config.DB.Model(user).Updates(map[string]interface{}{
+		"password":   hashed,
+		"updated_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
+		return false
+	}
+	if err := services.RevokeAllRefreshTokens(user.ID); err != nil {
+		log.Println("revoke sessions:", err)
+	}
+	if err := services.SendTemplate(user.Email, "password_changed", user); err != nil {
+		log.Println("password changed email:", err)
+	}
+	return true
+}
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"gorm.io/gorm"
+)
+
+// AdminGetUser godoc
+// @Summary Get a user
+// @Description Admin view of a user with wallets and recent trades
+// @Tags admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/users/{id} [get]
+func AdminGetUser(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var wallets []models.Wallet
+	config.DB.Where("user_id = ?", user.ID).Find(&wallets)
+
+	var trades []models.Trade
+	config.DB.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(50).Find(&trades)
+
+	c.JSON(http.StatusOK, gin.H{"user": user, "wallets": wallets, "recent_trades": trades})
+}
+
+// AdminFreezeUser godoc
+// @Summary Freeze or unfreeze an account
+// @Description Frozen accounts cannot log in, refresh tokens or call protected endpoints
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param body body object{frozen=bool,reason=string} true "Freeze request"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/users/{id}/freeze [post]
+func AdminFreezeUser(c *gin.Context) {
+	var req struct {
+		Frozen *bool  `json:"frozen" binding:"required"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *req.Frozen && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	before := gin.H{"frozen": user.Frozen, "frozen_reason": user.FrozenReason}
+	updates := map[string]interface{}{"frozen": *req.Frozen, "frozen_reason": req.Reason, "frozen_at": nil}
+	if *req.Frozen {
+		updates["frozen_at"] = time.Now()
+	}
+	config.DB.Model(&user).Updates(updates)
+
+	// Kill existing sessions so the freeze takes effect once access tokens expire
+	if *req.Frozen {
+		config.DB.Model(&models.RefreshToken{}).Where("user_id = ?", user.ID).Update("revoked", true)
+	}
+
+	config.DB.First(&user, user.ID)
+	audit(c, c.GetUint("userID"), services.AuditAdminFreeze, "user", user.ID,
+		before, gin.H{"frozen": user.Frozen, "frozen_reason": user.FrozenReason})
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminSetRole godoc
+// @Summary Change a user's role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param body body object{role=string} true "user, support, risk or admin"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/users/{id}/role [post]
+func AdminSetRole(c *gin.Context) {
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	before := user.Role
+	config.DB.Model(&user).Update("role", req.Role)
+	audit(c, c.GetUint("userID"), services.AuditAdminRole, "user", user.ID,
+		gin.H{"role": before}, gin.H{"role": req.Role})
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminAdjustBalance godoc
+// @Summary Adjust a wallet balance
+// @Description Credit (positive) or debit (negative) a wallet with a mandatory reason
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Wallet ID"
+// @Param body body object{amount=number,reason=string} true "Adjustment"
+// @Success 200 {object} models.Wallet
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/wallets/{id}/adjust [post]
+func AdminAdjustBalance(c *gin.Context) {
+	var req struct {
+		Amount float64 `json:"amount" binding:"required"`
+		Reason string  `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wallet id"})
+		return
+	}
+
+	wallet, err := services.AdjustBalance(uint(walletID), req.Amount, req.Reason, c.GetUint("userID"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
+	case errors.Is(err, services.ErrNegativeBalance):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Adjustment failed"})
+	default:
+		audit(c, c.GetUint("userID"), services.AuditAdminAdjust, "wallet", wallet.ID,
+			gin.H{"balance": wallet.Balance - req.Amount},
+			gin.H{"balance": wallet.Balance, "amount": req.Amount, "reason": req.Reason})
+		c.JSON(http.StatusOK, wallet)
+	}
+}
+
+// AdminVoidTrade godoc
+// @Summary Void a trade
+// @Description Cancel a trade, refunding the stake and reversing any payout
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Trade ID"
+// @Param body body object{reason=string} true "Void reason"
+// @Success 200 {object} models.Trade
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/trades/{id}/void [post]
+func AdminVoidTrade(c *gin.Context) {
+	var req struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tradeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trade id"})
+		return
+	}
+
+	trade, err := services.VoidTrade(uint(tradeID), req.Reason, c.GetUint("userID"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trade not found"})
+	case errors.Is(err, services.ErrTradeVoided):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Void failed"})
+	default:
+		audit(c, c.GetUint("userID"), services.AuditAdminVoid, "trade", trade.ID,
+			nil, gin.H{"status": trade.Status, "reason": req.Reason})
+		c.JSON(http.StatusOK, trade)
+	}
+}
+
+// AdminUserStatement godoc
+// @Summary Download a user's statement
+// @Description Same as /statements but for any user, for support and compliance
+// @Tags admin
+// @Produce text/csv
+// @Produce application/pdf
+// @Param id path int true "User ID"
+// @Param from query string true "Start of period (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "End of period, exclusive (defaults to now; YYYY-MM-DD includes that day)"
+// @Param format query string false "csv or pdf" default(csv)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/users/{id}/statement [get]
+func AdminUserStatement(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user id"})
+		return
+	}
+	audit(c, c.GetUint("userID"), services.AuditAdminStatement, "user", userID,
+		nil, gin.H{"from": c.Query("from"), "to": c.Query("to"), "format": c.Query("format")})
+	serveStatement(c, uint(userID))
+}
+
+// AdminGetAuditLog godoc
+// @Summary Query the audit log
+// @Tags admin
+// @Produce json
+// @Param actor_id query int false "Filter by actor"
+// @Param action query string false "Filter by action (e.g. wallet.deposit)"
+// @Param target_type query string false "Filter by target type (user, wallet, trade, ...)"
+// @Param target_id query string false "Filter by target ID"
+// @Param from query string false "Start of date range (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "End of date range, exclusive (RFC3339, or YYYY-MM-DD to include that day)"
+// @Param limit query int false "Page size (max 200)" default(50)
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/audit [get]
+func AdminGetAuditLog(c *gin.Context) {
+	page, err := parsePageParams(c, map[string]string{"created_at": "created_at"}, "created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := config.DB.Model(&models.AuditLog{})
+	for _, f := range []string{"actor_id", "action", "target_type", "target_id"} {
+		if v := c.Query(f); v != "" {
+			query = query.Where(f+" = ?", v)
+		}
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	logs, next, err := paginate(query, page, func(l models.AuditLog) (interface{}, uint) {
+		return l.CreatedAt, l.ID
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse{Data: logs, NextCursor: next})
+}
+
+// AdminVerifyAuditLog godoc
+// @Summary Verify the audit hash chain
+// @Description Recomputes every hash; reports the first tampered row if any
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /admin/audit/verify [get]
+func AdminVerifyAuditLog(c *gin.Context) {
+	brokenAt, checked, err := services.VerifyAuditChain()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Verification failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":     brokenAt == 0,
+		"checked":   checked,
+		"broken_at": brokenAt,
+	})
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Returns the key ID and secret; the secret is shown only once. Requires a 2FA code when enabled.
+// @Description Sign requests with X-API-Key, X-API-Timestamp (unix ms), X-API-Nonce and
+// @Description X-API-Signature = hex(HMAC-SHA256(secret, ts\nnonce\nMETHOD\npath?query\nhex(sha256(body)))).
+// @Tags apikeys
+// @Accept json
+// @Produce json
+// @Param body body object{name=string,scopes=[]string,allowed_ips=[]string,otp=string} true "Key settings (scopes: read, trade, withdraw)"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /apikeys [post]
+func CreateAPIKey(c *gin.Context) {
+	var req struct {
+		Name       string   `json:"name" binding:"required"`
+		Scopes     []string `json:"scopes" binding:"required"`
+		AllowedIPs []string `json:"allowed_ips"`
+		OTP        string   `json:"otp"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if !requireStepUp(c, userID, req.OTP) {
+		return
+	}
+
+	key, secret, err := services.CreateAPIKey(userID, req.Name, req.Scopes, req.AllowedIPs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	audit(c, userID, services.AuditAPIKeyCreate, "api_key", key.KeyID, nil,
+		gin.H{"scopes": key.Scopes, "allowed_ips": key.AllowedIPs})
+
+	c.JSON(http.StatusCreated, gin.H{"api_key": key, "secret": secret})
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Tags apikeys
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Security ApiKeyAuth
+// @Router /apikeys [get]
+func GetAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	config.DB.Where("user_id = ?", c.GetUint("userID")).Order("created_at DESC").Find(&keys)
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Tags apikeys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /apikeys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	userID := c.GetUint("userID")
+	res := config.DB.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", c.Param("id"), userID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil || res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	audit(c, userID, services.AuditAPIKeyRevoke, "api_key", c.Param("id"), nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// audit appends an audit entry tagged with the request's IP and user agent.
+// actorID 0 means anonymous (e.g. a failed login).
+func audit(c *gin.Context, actorID uint, action, targetType string, targetID, before, after interface{}) {
+	var actor *uint
+	if actorID != 0 {
+		actor = &actorID
+	}
+	services.RecordAudit(services.AuditEntry{
+		ActorID:    actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	})
+}
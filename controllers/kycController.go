@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"gorm.io/gorm"
+)
+
+const maxKYCDocumentSize = 10 << 20
+
+var kycDocumentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+var kycIDDocuments = map[string]bool{
+	"passport":         true,
+	"national_id":      true,
+	"driving_licence":  true,
+	"residence_permit": true,
+	"proof_of_address": true,
+}
+
+// kycLimitExceeded writes a 403 for a KYC limit error and reports whether it did
+func kycLimitExceeded(c *gin.Context, err error) bool {
+	var limitErr *services.KYCLimitError
+	switch {
+	case errors.As(err, &limitErr):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":     limitErr.Error(),
+			"kyc_level": limitErr.Level,
+			"remaining": limitErr.Remaining,
+		})
+	case errors.Is(err, services.ErrPriceUnavailable):
+		// Limits are in USD; a crypto amount can't be checked without a price
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Price unavailable, try again shortly"})
+	default:
+		return false
+	}
+	return true
+}
+
+// GetKYCStatus godoc
+// @Summary Get KYC status
+// @Description Current verification level, its limits and the latest submission
+// @Tags kyc
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security ApiKeyAuth
+// @Router /kyc [get]
+func GetKYCStatus(c *gin.Context) {
+	userID := c.GetUint("userID")
+	level, limits, err := services.KYCLimitsFor(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load KYC status"})
+		return
+	}
+
+	resp := gin.H{"level": level, "limits": limits, "tiers": models.KYCTierLimits}
+	var latest models.KYCSubmission
+	if err := config.DB.Where("user_id = ?", userID).Order("created_at DESC").First(&latest).Error; err == nil {
+		resp["latest_submission"] = latest
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SubmitKYC godoc
+// @Summary Submit identity document
+// @Description Request a higher KYC level by uploading an ID document (JPEG, PNG or PDF, max 10 MB)
+// @Tags kyc
+// @Accept multipart/form-data
+// @Produce json
+// @Param level formData string true "basic or full"
+// @Param full_name formData string true "Name as on the document"
+// @Param date_of_birth formData string true "YYYY-MM-DD"
+// @Param country formData string true "ISO 3166-1 alpha-2 country code"
+// @Param document_type formData string true "passport, national_id, driving_licence, residence_permit or proof_of_address"
+// @Param document formData file true "Document scan"
+// @Success 201 {object} models.KYCSubmission
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /kyc/submissions [post]
+func SubmitKYC(c *gin.Context) {
+	level := c.PostForm("level")
+	if level != models.KYCBasic && level != models.KYCFull {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level must be basic or full"})
+		return
+	}
+	fullName := strings.TrimSpace(c.PostForm("full_name"))
+	if fullName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "full_name is required"})
+		return
+	}
+	dob, err := time.Parse("2006-01-02", c.PostForm("date_of_birth"))
+	if err != nil || dob.After(time.Now().AddDate(-18, 0, 0)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date_of_birth must be YYYY-MM-DD and at least 18 years ago"})
+		return
+	}
+	country := strings.ToUpper(c.PostForm("country"))
+	if len(country) != 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "country must be a 2-letter ISO code"})
+		return
+	}
+	docType := c.PostForm("document_type")
+	if !kycIDDocuments[docType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid document_type"})
+		return
+	}
+	data, ext, err := readUpload(c, "document", maxKYCDocumentSize, kycDocumentTypes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	sub := models.KYCSubmission{
+		UserID:       userID,
+		Level:        level,
+		FullName:     fullName,
+		DateOfBirth:  dob,
+		Country:      country,
+		DocumentType: docType,
+	}
+	err = services.SubmitKYC(&sub, bytes.NewReader(data), ext)
+	switch {
+	case errors.Is(err, services.ErrKYCLevel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrKYCPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit document"})
+		return
+	}
+
+	audit(c, userID, services.AuditKYCSubmit, "kyc_submission", sub.ID,
+		nil, gin.H{"level": sub.Level, "document_type": sub.DocumentType})
+	c.JSON(http.StatusCreated, sub)
+}
+
+// AdminGetKYCSubmissions godoc
+// @Summary List KYC submissions
+// @Tags admin
+// @Produce json
+// @Param status query string false "pending, approved or rejected" default(pending)
+// @Param user_id query int false "Filter by user"
+// @Param order query string false "asc or desc" default(asc)
+// @Param limit query int false "Page size (max 200)" default(50)
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/kyc [get]
+func AdminGetKYCSubmissions(c *gin.Context) {
+	page, err := parsePageParams(c, map[string]string{"created_at": "created_at"}, "created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Oldest first by default so the review queue is worked in order
+	if c.Query("order") == "" {
+		page.desc = false
+	}
+
+	query := config.DB.Where("status = ?", c.DefaultQuery("status", models.KYCPending))
+	if v := c.Query("user_id"); v != "" {
+		query = query.Where("user_id = ?", v)
+	}
+
+	subs, next, err := paginate(query, page, func(s models.KYCSubmission) (interface{}, uint) {
+		return s.CreatedAt, s.ID
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch submissions"})
+		return
+	}
+	c.JSON(http.StatusOK, pageResponse{Data: subs, NextCursor: next})
+}
+
+// AdminGetKYCDocument godoc
+// @Summary Download a KYC document
+// @Tags admin
+// @Produce application/octet-stream
+// @Param id path int true "Submission ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/kyc/{id}/document [get]
+func AdminGetKYCDocument(c *gin.Context) {
+	var sub models.KYCSubmission
+	if err := config.DB.First(&sub, c.Param("id")).Error; err != nil || sub.DocumentPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+		return
+	}
+	audit(c, c.GetUint("userID"), services.AuditKYCDocument, "kyc_submission", sub.ID, nil, nil)
+	c.File(sub.DocumentPath)
+}
+
+// AdminReviewKYC godoc
+// @Summary Approve or reject a KYC submission
+// @Description Approval raises the user's KYC level to the one requested
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "Submission ID"
+// @Param body body object{approve=bool,note=string} true "Decision (note required when rejecting)"
+// @Success 200 {object} models.KYCSubmission
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/kyc/{id}/review [post]
+func AdminReviewKYC(c *gin.Context) {
+	var req struct {
+		Approve *bool  `json:"approve" binding:"required"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !*req.Approve && req.Note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note is required when rejecting"})
+		return
+	}
+	subID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission id"})
+		return
+	}
+
+	sub, err := services.ReviewKYC(uint(subID), *req.Approve, req.Note, c.GetUint("userID"))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+	case errors.Is(err, services.ErrKYCAlreadyReview):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Review failed"})
+	default:
+		audit(c, c.GetUint("userID"), services.AuditKYCReview, "kyc_submission", sub.ID,
+			gin.H{"status": models.KYCPending},
+			gin.H{"status": sub.Status, "level": sub.Level, "user_id": sub.UserID, "note": sub.ReviewNote})
+		c.JSON(http.StatusOK, sub)
+	}
+}
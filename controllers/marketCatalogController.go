@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// marketError maps market catalog errors to responses, reporting whether it wrote one
+func marketError(c *gin.Context, err error) bool {
+	var halted *services.TradingHaltedError
+	switch {
+	case errors.As(err, &halted):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error(), "symbol": halted.Symbol, "reason": halted.Reason})
+	case errors.Is(err, services.ErrMarketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Market not found"})
+	case errors.Is(err, services.ErrMarketExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMarketClosed),
+		errors.Is(err, services.ErrDurationInvalid),
+		errors.Is(err, services.ErrMarketInvalid),
+		errors.Is(err, services.ErrInvalidSymbol):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+// GetMarkets godoc
+// @Summary List markets
+// @Description Tradable and halted symbols with their metadata, in display order
+// @Tags Market
+// @Produce json
+// @Success 200 {array} models.Market
+// @Router /markets [get]
+func GetMarkets(c *gin.Context) {
+	markets, err := services.ListMarkets(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load markets"})
+		return
+	}
+	c.JSON(http.StatusOK, markets)
+}
+
+// GetMarket godoc
+// @Summary Get a market
+// @Tags Market
+// @Produce json
+// @Param symbol path string true "Symbol (e.g. BTCUSDT)"
+// @Success 200 {object} models.Market
+// @Failure 404 {object} map[string]string
+// @Router /markets/{symbol} [get]
+func GetMarket(c *gin.Context) {
+	market, err := services.GetMarket(c.Param("symbol"))
+	if err == nil && market.Status == models.MarketDelisted {
+		err = services.ErrMarketNotFound
+	}
+	if err != nil {
+		if !marketError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load market"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, market)
+}
+
+// GetTickers godoc
+// @Summary Get ticker snapshots
+// @Description Latest price and rolling 24h change, high, low and volume for every listed market. Symbols with no data yet are omitted.
+// @Tags Market
+// @Produce json
+// @Param symbols query string false "Comma-separated symbols to include (default all)"
+// @Success 200 {array} services.PriceUpdate
+// @Router /market/tickers [get]
+func GetTickers(c *gin.Context) {
+	symbols := services.ActiveMarketSymbols()
+	if v := c.Query("symbols"); v != "" {
+		listed := make(map[string]bool, len(symbols))
+		for _, s := range symbols {
+			listed[s] = true
+		}
+		symbols = symbols[:0]
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.ToUpper(strings.TrimSpace(s)); listed[s] {
+				symbols = append(symbols, s)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, services.Tickers(symbols))
+}
+
+type marketRequest struct {
+	Symbol           string   `json:"symbol"`
+	BaseAsset        *string  `json:"base_asset"`
+	QuoteAsset       *string  `json:"quote_asset"`
+	DisplayName      *string  `json:"display_name"`
+	IconURL          *string  `json:"icon_url"`
+	PricePrecision   *int     `json:"price_precision"`
+	Status           *string  `json:"status"`
+	AllowedDurations []int    `json:"allowed_durations"`
+	Payout           *float64 `json:"payout"`
+	SortOrder        *int     `json:"sort_order"`
+}
+
+// apply copies the fields present in the request onto m
+func (r *marketRequest) apply(m *models.Market) {
+	if r.BaseAsset != nil {
+		m.BaseAsset = strings.ToUpper(*r.BaseAsset)
+	}
+	if r.QuoteAsset != nil {
+		m.QuoteAsset = strings.ToUpper(*r.QuoteAsset)
+	}
+	if r.DisplayName != nil {
+		m.DisplayName = *r.DisplayName
+	}
+	if r.IconURL != nil {
+		m.IconURL = *r.IconURL
+	}
+	if r.PricePrecision != nil {
+		m.PricePrecision = *r.PricePrecision
+	}
+	if r.Status != nil {
+		m.Status = *r.Status
+	}
+	if r.AllowedDurations != nil {
+		m.AllowedDurations = r.AllowedDurations
+	}
+	if r.Payout != nil {
+		m.Payout = *r.Payout
+	}
+	if r.SortOrder != nil {
+		m.SortOrder = *r.SortOrder
+	}
+}
+
+// AdminGetMarkets godoc
+// @Summary List all markets
+// @Description Includes delisted markets
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.Market
+// @Security ApiKeyAuth
+// @Router /admin/markets [get]
+func AdminGetMarkets(c *gin.Context) {
+	markets, err := services.ListMarkets(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load markets"})
+		return
+	}
+	c.JSON(http.StatusOK, markets)
+}
+
+// AdminCreateMarket godoc
+// @Summary List a new market
+// @Description The symbol must be listed on Binance. Base/quote assets and display name are derived from the symbol when omitted; durations and payout default to the platform defaults.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param body body marketRequest true "Market"
+// @Success 201 {object} models.Market
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/markets [post]
+func AdminCreateMarket(c *gin.Context) {
+	var req marketRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol is required"})
+		return
+	}
+
+	market := models.Market{Symbol: req.Symbol, PricePrecision: 2}
+	req.apply(&market)
+	if err := services.CreateMarket(&market); err != nil {
+		if !marketError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create market"})
+		}
+		return
+	}
+
+	audit(c, c.GetUint("userID"), services.AuditAdminMarket, "market", market.ID, nil, market)
+	c.JSON(http.StatusCreated, market)
+}
+
+// AdminUpdateMarket godoc
+// @Summary Update a market
+// @Description Partial update. Setting status to halted stops new trades; delisted also hides the market.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param symbol path string true "Symbol"
+// @Param body body marketRequest true "Fields to change (symbol is ignored)"
+// @Success 200 {object} models.Market
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/markets/{symbol} [patch]
+func AdminUpdateMarket(c *gin.Context) {
+	var req marketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	market, err := services.GetMarket(c.Param("symbol"))
+	if err != nil {
+		if !marketError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load market"})
+		}
+		return
+	}
+	before := *market
+	req.apply(market)
+	if err := services.UpdateMarket(market); err != nil {
+		if !marketError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update market"})
+		}
+		return
+	}
+
+	audit(c, c.GetUint("userID"), services.AuditAdminMarket, "market", market.ID, before, market)
+	c.JSON(http.StatusOK, market)
+}
@@ -1,9 +1,14 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/indicators"
 	"github.com/solchef/crypto-options-backend/services"
 )
 
@@ -14,29 +19,192 @@ type PricePoint struct {
 }
 
 // GetPriceHistory godoc
-// @Summary      Get 24h price history
-// @Description  Returns the past 24h price history for a given symbol
+// @Summary      Get price history
+// @Description  Returns OHLCV candles for a symbol. Defaults to the last 24h of 5m candles; ranges beyond Binance's 1000-row cap are fetched in pages.
 // @Tags         Market
 // @Accept       json
 // @Produce      json
 // @Param        symbol query string true "Trading symbol (e.g. btcusdt)"
-// @Success      200 {object} interface{} "Price history data"
+// @Param        interval query string false "1s, 1m, 3m, 5m, 15m, 30m, 1h, 2h, 4h, 6h, 8h, 12h or 1d" default(5m)
+// @Param        start query string false "Range start (RFC3339, YYYY-MM-DD or Unix ms)"
+// @Param        end query string false "Range end (defaults to now)"
+// @Param        limit query int false "Max candles (max 5000)" default(288)
+// @Param        format query string false "ohlc for full candles, line for {time, value} close prices" default(ohlc)
+// @Success      200 {array} models.Candle "OHLCV candles"
+// @Success      200 {array} PricePoint "Line format"
 // @Failure      400 {object} map[string]string "Bad Request"
-// @Failure      500 {object} map[string]string "Internal Server Error"
+// @Failure      502 {object} map[string]string "Upstream error"
 // @Router       /market/history [get]
 func GetPriceHistory(c *gin.Context) {
+	q, ok := historyQuery(c, 288, services.MaxHistoryLimit)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "ohlc")
+	if format != "ohlc" && format != "line" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ohlc or line"})
+		return
+	}
+
+	history, err := services.GetPriceHistory(c.Request.Context(), q)
+	if err != nil {
+		historyError(c, err)
+		return
+	}
+
+	if format == "line" {
+		c.JSON(http.StatusOK, services.ToLine(history))
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// historyQuery reads symbol, interval, start, end and limit, writing a 400
+// and returning false when any is invalid.
+func historyQuery(c *gin.Context, defaultLimit, maxLimit int) (services.HistoryQuery, bool) {
 	symbol := c.Query("symbol")
 	if symbol == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol query param required"})
+		return services.HistoryQuery{}, false
+	}
+
+	q := services.HistoryQuery{
+		Symbol:   symbol,
+		Interval: c.DefaultQuery("interval", "5m"),
+		Limit:    defaultLimit,
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxLimit)})
+			return services.HistoryQuery{}, false
+		}
+		q.Limit = n
+	}
+	var err error
+	if q.Start, err = parseTimeQuery(c, "start"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return services.HistoryQuery{}, false
+	}
+	if q.End, err = parseTimeQuery(c, "end"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return services.HistoryQuery{}, false
+	}
+	return q, true
+}
+
+// historyError maps a candle history error to its response
+func historyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSymbol), errors.Is(err, services.ErrInvalidInterval), errors.Is(err, services.ErrInvalidRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+	}
+}
+
+// GetIndicators godoc
+// @Summary      Get technical indicator
+// @Description  Evaluates an indicator over candle history: sma, ema (period, default 20), rsi, atr (period, default 14), macd (fast, slow, signal; default 12,26,9) or bollinger (period, width; default 20,2). Points start once the indicator is defined; earlier history is loaded for warm-up. Live values are available on the /trading WebSocket with ?indicator=type:params.
+// @Tags         Market
+// @Produce      json
+// @Param        symbol query string true "Trading symbol (e.g. btcusdt)"
+// @Param        interval query string false "1s, 1m, 3m, 5m, 15m, 30m, 1h, 2h, 4h, 6h, 8h, 12h or 1d" default(5m)
+// @Param        type query string true "sma, ema, rsi, macd, bollinger or atr"
+// @Param        params query string false "Comma-separated parameters (e.g. 12,26,9)"
+// @Param        start query string false "Range start (RFC3339, YYYY-MM-DD or Unix ms)"
+// @Param        end query string false "Range end (defaults to now)"
+// @Param        limit query int false "Max points (max 1000)" default(288)
+// @Success      200 {object} services.IndicatorSeries
+// @Failure      400 {object} map[string]string "Bad Request"
+// @Failure      502 {object} map[string]string "Upstream error"
+// @Router       /market/indicators [get]
+func GetIndicators(c *gin.Context) {
+	spec, err := indicators.Parse(c.Query("type"), c.Query("params"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q, ok := historyQuery(c, 288, services.MaxIndicatorPoints)
+	if !ok {
 		return
 	}
 
-	history, err := services.GetPriceHistory(symbol)
+	series, err := services.GetIndicator(c.Request.Context(), q, spec)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		historyError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, series)
+}
 
-	// Try encoding directly (whether it's [][]interface{} or map[string]interface{})
-	c.JSON(http.StatusOK, history)
+// GetPriceIndex godoc
+// @Summary      Get composite index price
+// @Description  The price trades are entered and settled at: a volume-weighted average across exchanges after dropping stale quotes and outliers. Components show each source and whether it was used.
+// @Tags         Market
+// @Produce      json
+// @Param        symbol query string true "Symbol (e.g. BTCUSDT)"
+// @Success      200 {object} services.PriceIndex
+// @Failure      404 {object} map[string]string
+// @Failure      503 {object} services.PriceIndex "Too few usable sources"
+// @Router       /market/index [get]
+func GetPriceIndex(c *gin.Context) {
+	market, err := services.GetMarket(c.Query("symbol"))
+	if err != nil {
+		if !marketError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load market"})
+		}
+		return
+	}
+	index, err := services.IndexPrice(market.Symbol)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, index)
+		return
+	}
+	c.JSON(http.StatusOK, index)
+}
+
+// GetFeedStatus godoc
+// @Summary      Get price feed health
+// @Description  Per-symbol stream state, last tick age, reconnects and source divergence. New trades are rejected on halted symbols; changes are also pushed as "feed_status" WebSocket messages.
+// @Tags         Market
+// @Produce      json
+// @Success      200 {array} services.FeedHealth
+// @Router       /market/status [get]
+func GetFeedStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, services.FeedStatus())
+}
+
+// GetOrderBook godoc
+// @Summary      Get order book depth
+// @Description  Top levels of the locally maintained order book, each [price, quantity]. Live updates are available on the /trading WebSocket with ?depth=N.
+// @Tags         Market
+// @Produce      json
+// @Param        symbol query string true "Symbol (e.g. BTCUSDT)"
+// @Param        limit query int false "Levels per side (max 100)" default(20)
+// @Success      200 {object} services.DepthSnapshot
+// @Failure      400 {object} map[string]string
+// @Failure      503 {object} map[string]string "Book not in sync yet"
+// @Router       /market/depth [get]
+func GetOrderBook(c *gin.Context) {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol query param required"})
+		return
+	}
+	limit := 20
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > services.MaxDepthLevels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+	book, ok := services.OrderBookDepth(symbol, limit)
+	if !ok {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Order book not available for " + symbol})
+		return
+	}
+	c.JSON(http.StatusOK, book)
 }
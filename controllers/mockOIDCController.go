@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+// A minimal OpenID provider for local development and manual testing of the
+// social login flow. Enabled with APP_ENV=development and OIDC_MOCK=true; point
+// the "mock" provider at it with OIDC_PROVIDERS=mock, OIDC_MOCK_ISSUER and
+// OIDC_MOCK_CLIENT_ID. /authorize signs in whoever is named by ?login_hint=email
+// without prompting.
+
+const mockOIDCKid = "mock-1"
+
+type mockOIDCGrant struct {
+	email, nonce, challenge, clientID, redirectURI string
+	expires                                        time.Time
+}
+
+var mockOIDC = struct {
+	sync.Mutex
+	key    *rsa.PrivateKey
+	grants map[string]mockOIDCGrant
+}{grants: map[string]mockOIDCGrant{}}
+
+func mockOIDCIssuer() string {
+	if v := os.Getenv("OIDC_MOCK_ISSUER"); v != "" {
+		return v
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port + "/mock-oidc"
+}
+
+func mockOIDCKey() *rsa.PrivateKey {
+	mockOIDC.Lock()
+	defer mockOIDC.Unlock()
+	if mockOIDC.key == nil {
+		mockOIDC.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	return mockOIDC.key
+}
+
+// RegisterMockOIDC mounts the mock provider under /mock-oidc.
+func RegisterMockOIDC(r *gin.Engine) {
+	g := r.Group("/mock-oidc")
+
+	g.GET("/.well-known/openid-configuration", func(c *gin.Context) {
+		iss := mockOIDCIssuer()
+		c.JSON(http.StatusOK, gin.H{
+			"issuer":                 iss,
+			"authorization_endpoint": iss + "/authorize",
+			"token_endpoint":         iss + "/token",
+			"jwks_uri":               iss + "/jwks",
+		})
+	})
+
+	g.GET("/jwks", func(c *gin.Context) {
+		pub := mockOIDCKey().PublicKey
+		b64 := base64.RawURLEncoding
+		c.JSON(http.StatusOK, gin.H{"keys": []gin.H{{
+			"kty": "RSA", "kid": mockOIDCKid, "alg": "RS256", "use": "sig",
+			"n": b64.EncodeToString(pub.N.Bytes()),
+			"e": b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}}})
+	})
+
+	g.GET("/authorize", func(c *gin.Context) {
+		email := c.Query("login_hint")
+		if email == "" {
+			email = "mock.user@example.com"
+		}
+		code := uuid.NewString()
+		mockOIDC.Lock()
+		mockOIDC.grants[code] = mockOIDCGrant{
+			email:       email,
+			nonce:       c.Query("nonce"),
+			challenge:   c.Query("code_challenge"),
+			clientID:    c.Query("client_id"),
+			redirectURI: c.Query("redirect_uri"),
+			expires:     time.Now().Add(time.Minute),
+		}
+		mockOIDC.Unlock()
+
+		q := url.Values{}
+		q.Set("code", code)
+		q.Set("state", c.Query("state"))
+		c.Redirect(http.StatusFound, c.Query("redirect_uri")+"?"+q.Encode())
+	})
+
+	g.POST("/token", func(c *gin.Context) {
+		code := c.PostForm("code")
+		mockOIDC.Lock()
+		grant, ok := mockOIDC.grants[code]
+		delete(mockOIDC.grants, code)
+		mockOIDC.Unlock()
+
+		verifier := sha256.Sum256([]byte(c.PostForm("code_verifier")))
+		if !ok || time.Now().After(grant.expires) ||
+			grant.clientID != c.PostForm("client_id") ||
+			grant.redirectURI != c.PostForm("redirect_uri") ||
+			grant.challenge != base64.RawURLEncoding.EncodeToString(verifier[:]) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant"})
+			return
+		}
+
+		now := time.Now()
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            mockOIDCIssuer(),
+			"aud":            grant.clientID,
+			"sub":            "mock|" + grant.email,
+			"email":          grant.email,
+			"email_verified": true,
+			"nonce":          grant.nonce,
+			"iat":            now.Unix(),
+			"exp":            now.Add(5 * time.Minute).Unix(),
+		})
+		tok.Header["kid"] = mockOIDCKid
+		idToken, err := tok.SignedString(mockOIDCKey())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": uuid.NewString(),
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+			"expires_in":   300,
+		})
+	})
+}
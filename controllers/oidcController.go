@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+// GetOIDCProviders godoc
+// @Summary List social login providers
+// @Tags auth
+// @Produce json
+// @Success 200 {array} string
+// @Router /auth/oidc/providers [get]
+func GetOIDCProviders(c *gin.Context) {
+	names := []string{}
+	for name := range services.OIDCProviders() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, names)
+}
+
+// oidcStateCookie binds a login's state to the browser that started it, so a
+// callback carrying someone else's state (login CSRF) is refused
+const oidcStateCookie = "oidc_state"
+
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := os.Getenv("COOKIE_SECURE") == "true"
+	// Apple posts the callback cross-site (form_post), which Lax cookies skip
+	if secure {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(oidcStateCookie, state, maxAge, "/api/auth/oidc", "", secure, true)
+}
+
+// OIDCLogin godoc
+// @Summary Start social login
+// @Description Redirects to the provider (or returns {url} with ?mode=json for SPAs, which must
+// @Description send credentials so the state cookie set here is kept for the callback)
+// @Tags auth
+// @Produce json
+// @Param provider path string true "google, apple or a configured generic provider"
+// @Param mode query string false "json to get the URL instead of a redirect"
+// @Param login_hint query string false "Email to pre-fill at the provider"
+// @Success 302
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /auth/oidc/{provider}/login [get]
+func OIDCLogin(c *gin.Context) {
+	p, err := services.GetOIDCProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	authURL, state, err := services.OIDCAuthURL(p, c.Query("login_hint"))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "provider unavailable"})
+		return
+	}
+	setOIDCStateCookie(c, state, int(services.OIDCStateTTL.Seconds()))
+	if c.Query("mode") == "json" {
+		c.JSON(http.StatusOK, gin.H{"url": authURL})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback godoc
+// @Summary Finish social login
+// @Description Exchanges the authorization code, links or creates the account and returns our tokens
+// @Description (or {mfa_required, mfa_token} when 2FA is enabled). Accepts query params or a form post.
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/oidc/{provider}/callback [get]
+func OIDCCallback(c *gin.Context) {
+	p, err := services.GetOIDCProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Apple uses response_mode=form_post; others redirect with query params
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" {
+		code, state = c.PostForm("code"), c.PostForm("state")
+	}
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": e})
+		return
+	}
+	if code == "" || state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing code or state"})
+		return
+	}
+	bound, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if subtle.ConstantTimeCompare([]byte(bound), []byte(state)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrOIDCState.Error()})
+		return
+	}
+
+	claims, err := services.OIDCExchange(p, state, code)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, services.ErrOIDCState) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, created, err := services.FindOrCreateOIDCUser(p.Name, claims)
+	if errors.Is(err, services.ErrOIDCEmailUnverified) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrOIDCLinkUnverified) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve account"})
+		return
+	}
+	if created {
+		services.CreateDefaultWallets(user.ID)
+	}
+	if user.Frozen {
+		audit(c, 0, services.AuditLoginFailed, "user", user.ID, nil, gin.H{"reason": "frozen", "provider": p.Name})
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is frozen"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		challenge, exp, err := utils.NewMFAChallengeToken(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "challenge token error"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_at":   exp.Unix(),
+		})
+		return
+	}
+
+	issueSession(c, *user)
+}
@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+// memoryOIDCStates keeps login states in memory so the flow runs without a
+// database; tests edit stored states to stand in for a tampered callback
+type memoryOIDCStates struct {
+	sync.Mutex
+	m map[string]models.OIDCState
+}
+
+func (s *memoryOIDCStates) Save(st *models.OIDCState) error {
+	s.Lock()
+	defer s.Unlock()
+	s.m[st.Provider+"/"+st.State] = *st
+	return nil
+}
+
+func (s *memoryOIDCStates) Take(state, provider string) (*models.OIDCState, error) {
+	s.Lock()
+	defer s.Unlock()
+	st, ok := s.m[provider+"/"+state]
+	if !ok {
+		return nil, services.ErrOIDCState
+	}
+	delete(s.m, provider+"/"+state)
+	return &st, nil
+}
+
+func (s *memoryOIDCStates) edit(state string, f func(*models.OIDCState)) {
+	s.Lock()
+	defer s.Unlock()
+	st := s.m["mock/"+state]
+	f(&st)
+	s.m["mock/"+state] = st
+}
+
+// oidcTestServer serves the mock provider and our login/callback routes, with
+// the "mock" provider pointed at it
+func oidcTestServer(t *testing.T) (*httptest.Server, *memoryOIDCStates) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	RegisterMockOIDC(r)
+	r.GET("/api/auth/oidc/:provider/login", OIDCLogin)
+	r.GET("/api/auth/oidc/:provider/callback", OIDCCallback)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER", srv.URL+"/mock-oidc")
+	t.Setenv("OIDC_MOCK_CLIENT_ID", "test-client")
+	t.Setenv("OIDC_MOCK_REDIRECT_URL", srv.URL+"/api/auth/oidc/mock/callback")
+
+	states := &memoryOIDCStates{m: map[string]models.OIDCState{}}
+	services.SetOIDCStateStore(states)
+	t.Cleanup(func() { services.SetOIDCStateStore(services.DBOIDCStates{}) })
+	return srv, states
+}
+
+// oidcBrowser keeps cookies and stops at every redirect
+func oidcBrowser(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// startOIDCLogin follows the login and authorize redirects and returns the
+// callback URL the provider sends the browser back to
+func startOIDCLogin(t *testing.T, client *http.Client, srv *httptest.Server, email string) *url.URL {
+	next := srv.URL + "/api/auth/oidc/mock/login?login_hint=" + url.QueryEscape(email)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("GET %s: %s", next, resp.Status)
+		}
+		next = resp.Header.Get("Location")
+	}
+	u, err := url.Parse(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/api/auth/oidc/mock/callback" || u.Query().Get("code") == "" || u.Query().Get("state") == "" {
+		t.Fatalf("provider redirected to %s, want our callback with code and state", u)
+	}
+	return u
+}
+
+func getJSONBody(t *testing.T, client *http.Client, u string) (int, map[string]interface{}) {
+	resp, err := client.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, body
+}
+
+func TestOIDCLoginSetsStateCookie(t *testing.T) {
+	srv, _ := oidcTestServer(t)
+	client := oidcBrowser(t)
+	callback := startOIDCLogin(t, client, srv, "alice@example.com")
+
+	cookies := client.Jar.Cookies(callback)
+	if len(cookies) != 1 || cookies[0].Name != oidcStateCookie || cookies[0].Value != callback.Query().Get("state") {
+		t.Fatalf("cookies sent to the callback = %v, want %s bound to the login's state", cookies, oidcStateCookie)
+	}
+	// The cookie is scoped to the OIDC routes only
+	if got := client.Jar.Cookies(&url.URL{Scheme: "http", Host: callback.Host, Path: "/api/wallets"}); len(got) != 0 {
+		t.Errorf("state cookie sent outside /api/auth/oidc: %v", got)
+	}
+}
+
+func TestOIDCCallbackRequiresBoundState(t *testing.T) {
+	srv, states := oidcTestServer(t)
+
+	tests := []struct {
+		name     string
+		callback func(victim *url.URL) (*http.Client, string)
+	}{
+		{
+			// An attacker's callback link opened in a browser that never started a login
+			name: "no state cookie",
+			callback: func(victim *url.URL) (*http.Client, string) {
+				return oidcBrowser(t), victim.String()
+			},
+		},
+		{
+			// A browser mid-login of its own is handed someone else's code and state
+			name: "cookie for another login",
+			callback: func(victim *url.URL) (*http.Client, string) {
+				client := oidcBrowser(t)
+				startOIDCLogin(t, client, srv, "bob@example.com")
+				return client, victim.String()
+			},
+		},
+		{
+			name: "state missing",
+			callback: func(victim *url.URL) (*http.Client, string) {
+				q := victim.Query()
+				q.Del("state")
+				u := *victim
+				u.RawQuery = q.Encode()
+				return oidcBrowser(t), u.String()
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			victim := startOIDCLogin(t, oidcBrowser(t), srv, "mallory@example.com")
+			client, u := tt.callback(victim)
+			if status, body := getJSONBody(t, client, u); status != http.StatusBadRequest {
+				t.Fatalf("callback = %d %v, want 400", status, body)
+			}
+			// Refused before the state is consumed
+			states.Lock()
+			_, ok := states.m["mock/"+victim.Query().Get("state")]
+			states.Unlock()
+			if !ok {
+				t.Error("refused callback consumed the login state")
+			}
+		})
+	}
+}
+
+func TestOIDCExchangeWithMockProvider(t *testing.T) {
+	srv, states := oidcTestServer(t)
+	p, err := services.GetOIDCProvider("mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(*models.OIDCState)
+		// wantErr is a substring of the error, "" for success
+		wantErr string
+	}{
+		{name: "valid login"},
+		{
+			name:    "wrong PKCE verifier",
+			tamper:  func(st *models.OIDCState) { st.CodeVerifier = "not-the-verifier" },
+			wantErr: "token exchange failed",
+		},
+		{
+			name:    "redirect URI differs from the authorization request",
+			tamper:  func(st *models.OIDCState) { st.RedirectURL = srv.URL + "/elsewhere" },
+			wantErr: "token exchange failed",
+		},
+		{
+			// The provider echoes the nonce it was sent; a token minted for
+			// another login carries a different one
+			name:    "nonce mismatch",
+			tamper:  func(st *models.OIDCState) { st.Nonce = "another-login" },
+			wantErr: "nonce mismatch",
+		},
+		{
+			name:    "expired state",
+			tamper:  func(st *models.OIDCState) { st.ExpiresAt = time.Now().Add(-time.Second) },
+			wantErr: services.ErrOIDCState.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback := startOIDCLogin(t, oidcBrowser(t), srv, "carol@example.com")
+			state, code := callback.Query().Get("state"), callback.Query().Get("code")
+			if tt.tamper != nil {
+				states.edit(state, tt.tamper)
+			}
+
+			claims, err := services.OIDCExchange(p, state, code)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("exchange error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Email != "carol@example.com" || claims.Subject != "mock|carol@example.com" || claims.EmailVerified != true {
+				t.Errorf("claims = %+v", claims)
+			}
+
+			// A state completes one login only
+			if _, err := services.OIDCExchange(p, state, code); !errors.Is(err, services.ErrOIDCState) {
+				t.Errorf("replayed exchange error = %v, want %v", err, services.ErrOIDCState)
+			}
+		})
+	}
+}
+
+// TestOIDCCallbackLinking runs the whole callback against a scratch database
+// named by TEST_DATABASE_DSN, and is skipped without one.
+func TestOIDCCallbackLinking(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: dsn, PreferSimpleProtocol: true}), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}, &models.RefreshToken{}, &models.AuditLog{},
+		&models.Wallet{}, &models.WalletTransaction{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = prev })
+
+	t.Setenv("APP_ENV", "development")
+	t.Setenv("REFRESH_IN_COOKIE", "false")
+	if err := utils.InitJWTKeys(); err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := oidcTestServer(t)
+
+	run := time.Now().UnixNano()
+	tests := []struct {
+		name     string
+		existing *models.User // local account holding the email, if any
+		status   int
+	}{
+		{name: "new account", status: http.StatusOK},
+		{name: "links a verified account", existing: &models.User{EmailVerified: true}, status: http.StatusOK},
+		{
+			// Whoever registered the address may not own it
+			name:     "refuses an unverified account",
+			existing: &models.User{EmailVerified: false},
+			status:   http.StatusConflict,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := fmt.Sprintf("oidc-%d-%d@example.com", run, i)
+			if tt.existing != nil {
+				tt.existing.Username = fmt.Sprintf("oidc_%d_%d", run, i)
+				tt.existing.Email = email
+				tt.existing.Password = "x"
+				if err := db.Create(tt.existing).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := oidcBrowser(t)
+			callback := startOIDCLogin(t, client, srv, email)
+			status, body := getJSONBody(t, client, callback.String())
+			if status != tt.status {
+				t.Fatalf("callback = %d %v, want %d", status, body, tt.status)
+			}
+
+			var identities int64
+			db.Model(&models.UserIdentity{}).Where("provider = ? AND subject = ?", "mock", "mock|"+email).Count(&identities)
+			if linked := tt.status == http.StatusOK; (identities == 1) != linked {
+				t.Errorf("%d identities for %s, want linked=%v", identities, email, linked)
+			}
+			if tt.status == http.StatusOK && body["access_token"] == nil {
+				t.Errorf("no access token in %v", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+	dateLayout       = "2006-01-02"
+)
+
+// pageCursor is the opaque keyset position handed back to clients as next_cursor.
+type pageCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+type pageParams struct {
+	limit  int
+	sort   string // column name, already whitelisted
+	desc   bool
+	cursor *pageCursor
+}
+
+// pageResponse is the envelope returned by paginated list endpoints.
+type pageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// parsePageParams reads limit / cursor / sort / order from the query string.
+// sortable maps public sort names to column names.
+func parsePageParams(c *gin.Context, sortable map[string]string, defaultSort string) (pageParams, error) {
+	p := pageParams{limit: defaultPageLimit, desc: true}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return p, errors.New("invalid limit")
+		}
+		if n > maxPageLimit {
+			n = maxPageLimit
+		}
+		p.limit = n
+	}
+
+	sortKey := c.DefaultQuery("sort", defaultSort)
+	col, ok := sortable[sortKey]
+	if !ok {
+		return p, fmt.Errorf("invalid sort field %q", sortKey)
+	}
+	p.sort = col
+
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "desc":
+	case "asc":
+		p.desc = false
+	default:
+		return p, errors.New("order must be asc or desc")
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return p, errors.New("invalid cursor")
+		}
+		var cur pageCursor
+		if err := json.Unmarshal(raw, &cur); err != nil {
+			return p, errors.New("invalid cursor")
+		}
+		p.cursor = &cur
+	}
+
+	return p, nil
+}
+
+// parseTimeRange reads optional from/to query params (see parseTimeQuery) as
+// the half-open range [from, to). A date-only `to` covers that whole day, so
+// to=2024-03-05 ends at midnight on the 6th.
+func parseTimeRange(c *gin.Context) (from, to *time.Time, err error) {
+	if from, err = parseTimeQuery(c, "from"); err != nil {
+		return
+	}
+	if to, err = parseTimeQuery(c, "to"); err != nil || to == nil {
+		return
+	}
+	if _, dateErr := time.Parse(dateLayout, c.Query("to")); dateErr == nil {
+		end := to.AddDate(0, 0, 1)
+		to = &end
+	}
+	return
+}
+
+// parseTimeQuery reads an optional time query param given as RFC3339,
+// YYYY-MM-DD or Unix milliseconds.
+func parseTimeQuery(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(dateLayout, v); err == nil {
+		return &t, nil
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		t := time.UnixMilli(ms)
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid %s, expected RFC3339, YYYY-MM-DD or Unix milliseconds", name)
+}
+
+// paginate applies keyset ordering to q and loads one page into a slice of T.
+// key returns the sort column value and ID of a row, used to build next_cursor.
+func paginate[T any](q *gorm.DB, p pageParams, key func(T) (interface{}, uint)) ([]T, string, error) {
+	op, dir := ">", "ASC"
+	if p.desc {
+		op, dir = "<", "DESC"
+	}
+
+	if p.cursor != nil {
+		var v interface{} = p.cursor.Value
+		if p.sort == "created_at" {
+			t, err := time.Parse(time.RFC3339Nano, p.cursor.Value)
+			if err != nil {
+				return nil, "", errors.New("invalid cursor")
+			}
+			v = t
+		} else if f, err := strconv.ParseFloat(p.cursor.Value, 64); err == nil {
+			v = f
+		}
+		q = q.Where(
+			fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", p.sort, op),
+			v, v, p.cursor.ID,
+		)
+	}
+
+	var rows []T
+	if err := q.Order(p.sort + " " + dir).Order("id " + dir).Limit(p.limit + 1).Find(&rows).Error; err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(rows) > p.limit {
+		rows = rows[:p.limit]
+		next = encodeCursor(key(rows[len(rows)-1]))
+	}
+	return rows, next, nil
+}
+
+// encodeCursor builds the next_cursor for a row's sort value and ID
+func encodeCursor(v interface{}, id uint) string {
+	var s string
+	switch val := v.(type) {
+	case time.Time:
+		s = val.UTC().Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(val)
+	}
+	raw, _ := json.Marshal(pageCursor{Value: s, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
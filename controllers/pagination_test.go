@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func queryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/?"+query, nil)
+	return c
+}
+
+var testSortable = map[string]string{"created_at": "created_at", "amount": "amount"}
+
+func TestParsePageParams(t *testing.T) {
+	tests := []struct {
+		query   string
+		limit   int
+		sort    string
+		desc    bool
+		wantErr bool
+	}{
+		{query: "", limit: defaultPageLimit, sort: "created_at", desc: true},
+		{query: "limit=10&sort=amount&order=ASC", limit: 10, sort: "amount"},
+		{query: "limit=1000", limit: maxPageLimit, sort: "created_at", desc: true},
+		{query: "limit=0", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+		{query: "sort=password", wantErr: true},
+		{query: "order=sideways", wantErr: true},
+		{query: "cursor=!!", wantErr: true},
+		{query: "cursor=bm90LWpzb24", wantErr: true}, // "not-json"
+	}
+	for _, tt := range tests {
+		p, err := parsePageParams(queryContext(tt.query), testSortable, "created_at")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: no error", tt.query)
+			}
+			continue
+		}
+		if err != nil || p.limit != tt.limit || p.sort != tt.sort || p.desc != tt.desc {
+			t.Errorf("%q = %+v, %v; want limit %d sort %s desc %v", tt.query, p, err, tt.limit, tt.sort, tt.desc)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2024, 3, 5, 14, 30, 0, 123456000, time.FixedZone("CET", 3600))
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{at, "2024-03-05T13:30:00.123456Z"},
+		{12.5, "12.5"},
+	}
+	for _, tt := range tests {
+		p, err := parsePageParams(queryContext("cursor="+encodeCursor(tt.value, 42)), testSortable, "created_at")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.cursor == nil || p.cursor.Value != tt.want || p.cursor.ID != 42 {
+			t.Errorf("cursor for %v = %+v, want %s/42", tt.value, p.cursor, tt.want)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		query    string
+		from, to time.Time // zero when absent
+		wantErr  bool
+	}{
+		{query: ""},
+		{
+			// A date-only end covers the whole day
+			query: "from=2024-03-01&to=2024-03-05",
+			from:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:    time.Date(2024, 3, 6, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			query: "to=2024-03-05T12:00:00Z",
+			to:    time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			query: "from=1709640000000",
+			from:  time.UnixMilli(1709640000000),
+		},
+		{query: "to=yesterday", wantErr: true},
+		{query: "from=2024-13-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		from, to, err := parseTimeRange(queryContext(tt.query))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: no error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if (from == nil) != tt.from.IsZero() || (from != nil && !from.Equal(tt.from)) {
+			t.Errorf("%q: from = %v, want %v", tt.query, from, tt.from)
+		}
+		if (to == nil) != tt.to.IsZero() || (to != nil && !to.Equal(tt.to)) {
+			t.Errorf("%q: to = %v, want %v", tt.query, to, tt.to)
+		}
+	}
+}
+
+type pageRow struct {
+	ID        uint
+	Amount    float64
+	CreatedAt time.Time
+}
+
+func TestPaginateQuery(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sql string
+	var vars []interface{}
+	db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		sql, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+
+	at := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		query string
+		sql   string
+		first interface{} // the cursor value bound to the query
+	}{
+		{
+			name:  "first page",
+			query: "limit=2",
+			sql:   `SELECT * FROM "page_rows" ORDER BY created_at DESC,id DESC LIMIT $1`,
+		},
+		{
+			name:  "newest first after a cursor",
+			query: "cursor=" + encodeCursor(at, 7),
+			sql:   `SELECT * FROM "page_rows" WHERE (created_at < $1 OR (created_at = $2 AND id < $3)) ORDER BY created_at DESC,id DESC LIMIT $4`,
+			first: at,
+		},
+		{
+			name:  "ascending amount after a cursor",
+			query: "sort=amount&order=asc&cursor=" + encodeCursor(12.5, 7),
+			sql:   `SELECT * FROM "page_rows" WHERE (amount > $1 OR (amount = $2 AND id > $3)) ORDER BY amount ASC,id ASC LIMIT $4`,
+			first: 12.5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parsePageParams(queryContext(tt.query), testSortable, "created_at")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := paginate(db.Model(&pageRow{}), p, func(r pageRow) (interface{}, uint) { return r.CreatedAt, r.ID }); err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(sql) != tt.sql {
+				t.Errorf("SQL = %s\nwant  %s", sql, tt.sql)
+			}
+			if tt.first == nil {
+				return
+			}
+			if len(vars) < 3 || vars[2] != uint(7) {
+				t.Fatalf("vars = %v, want the cursor ID third", vars)
+			}
+			switch want := tt.first.(type) {
+			case time.Time:
+				if got, ok := vars[0].(time.Time); !ok || !got.Equal(want) {
+					t.Errorf("cursor value = %v, want %v", vars[0], want)
+				}
+			default:
+				if vars[0] != want {
+					t.Errorf("cursor value = %v, want %v", vars[0], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// alertError writes the response for a price alert error and reports whether it did
+func alertError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrAlertNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price alert not found"})
+	case errors.Is(err, services.ErrAlertInvalid), errors.Is(err, services.ErrAlertLimit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return marketError(c, err)
+	}
+	return true
+}
+
+type alertRequest struct {
+	Symbol        string   `json:"symbol"`
+	Condition     *string  `json:"condition"`
+	Price         *float64 `json:"price"`
+	Percent       *float64 `json:"percent"`
+	WindowSeconds *int     `json:"window_seconds"`
+	Recurring     *bool    `json:"recurring"`
+	Note          *string  `json:"note"`
+	Active        *bool    `json:"active"`
+}
+
+// apply copies the fields present in the request onto a
+func (r *alertRequest) apply(a *models.PriceAlert) {
+	if r.Condition != nil {
+		a.Condition = *r.Condition
+	}
+	if r.Price != nil {
+		a.Price = *r.Price
+	}
+	if r.Percent != nil {
+		a.Percent = *r.Percent
+	}
+	if r.WindowSeconds != nil {
+		a.WindowSeconds = *r.WindowSeconds
+	}
+	if r.Recurring != nil {
+		a.Recurring = *r.Recurring
+	}
+	if r.Note != nil {
+		a.Note = *r.Note
+	}
+	if r.Active != nil {
+		a.Active = *r.Active
+	}
+}
+
+// GetAlerts godoc
+// @Summary List price alerts
+// @Tags alerts
+// @Produce json
+// @Success 200 {array} models.PriceAlert
+// @Security ApiKeyAuth
+// @Router /alerts [get]
+func GetAlerts(c *gin.Context) {
+	alerts, err := services.ListAlerts(c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load price alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetAlert godoc
+// @Summary Get a price alert
+// @Tags alerts
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Success 200 {object} models.PriceAlert
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /alerts/{id} [get]
+func GetAlert(c *gin.Context) {
+	alert, err := services.GetAlert(c.GetUint("userID"), c.Param("id"))
+	if err != nil {
+		if !alertError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load price alert"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// CreateAlert godoc
+// @Summary Create a price alert
+// @Description condition above/below fires when the price reaches price; move fires when it moves percent or more either way within window_seconds (60-86400). One-shot alerts switch off after firing; recurring ones fire each time the condition is met again, at most once a minute. Alerts arrive as "price_alert" WebSocket messages and, with price alert emails enabled, by email. Up to 50 active alerts.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param body body alertRequest true "Alert (active is ignored)"
+// @Success 201 {object} models.PriceAlert
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /alerts [post]
+func CreateAlert(c *gin.Context) {
+	var req alertRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Symbol == "" || req.Condition == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol and condition are required"})
+		return
+	}
+
+	alert := models.PriceAlert{UserID: c.GetUint("userID"), Symbol: req.Symbol}
+	req.Active = nil
+	req.apply(&alert)
+	if err := services.CreateAlert(&alert); err != nil {
+		if !alertError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create price alert"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, alert)
+}
+
+// UpdateAlert godoc
+// @Summary Update a price alert
+// @Description Partial update; set active to pause or resume. The symbol can't be changed.
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Param body body alertRequest true "Fields to change (symbol is ignored)"
+// @Success 200 {object} models.PriceAlert
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /alerts/{id} [patch]
+func UpdateAlert(c *gin.Context) {
+	var req alertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alert, err := services.GetAlert(c.GetUint("userID"), c.Param("id"))
+	if err != nil {
+		if !alertError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load price alert"})
+		}
+		return
+	}
+	req.apply(alert)
+	if err := services.UpdateAlert(alert); err != nil {
+		if !alertError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price alert"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// DeleteAlert godoc
+// @Summary Delete a price alert
+// @Tags alerts
+// @Produce json
+// @Param id path int true "Alert ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /alerts/{id} [delete]
+func DeleteAlert(c *gin.Context) {
+	if err := services.DeleteAlert(c.GetUint("userID"), c.Param("id")); err != nil {
+		if !alertError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete price alert"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Price alert deleted"})
+}
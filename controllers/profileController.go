@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezone validation must not depend on the host's zoneinfo
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+const maxAvatarSize = 2 << 20
+
+var avatarTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// profileResponse is the user plus derived fields only shown to the owner
+type profileResponse struct {
+	models.User
+	AvatarURL         string                      `json:"avatar_url,omitempty"`
+	ResponsibleGaming *services.ResponsibleGaming `json:"responsible_gaming"`
+}
+
+func avatarURL(user *models.User) string {
+	if user.AvatarPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("/api/users/%d/avatar?v=%d", user.ID, user.UpdatedAt.Unix())
+}
+
+// writeProfile responds with the full profile of user
+func writeProfile(c *gin.Context, user *models.User) {
+	rg, err := services.ResponsibleGamingFor(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load profile"})
+		return
+	}
+	c.JSON(http.StatusOK, profileResponse{User: *user, AvatarURL: avatarURL(user), ResponsibleGaming: rg})
+}
+
+// currentUser loads the authenticated user, writing a 404 if it's gone
+func currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// Profile godoc
+// @Summary Get user profile
+// @Description Profile, preferences, KYC level and responsible-gaming settings of the authenticated user
+// @Tags profile
+// @Produce json
+// @Success 200 {object} profileResponse
+// @Security ApiKeyAuth
+// @Router /profile [get]
+func Profile(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	writeProfile(c, user)
+}
+
+// UpdateProfile godoc
+// @Summary Update profile and preferences
+// @Description Partial update; omitted fields are left unchanged. Email changes go through /profile/email.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param body body object{display_name=string,country=string,timezone=string,quote_currency=string,default_trade_amount=number,default_trade_duration=int,notifications=models.NotificationPrefs} true "Fields to change"
+// @Success 200 {object} profileResponse
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /profile [patch]
+func UpdateProfile(c *gin.Context) {
+	var req struct {
+		DisplayName          *string  `json:"display_name"`
+		Country              *string  `json:"country"`
+		Timezone             *string  `json:"timezone"`
+		QuoteCurrency        *string  `json:"quote_currency"`
+		DefaultTradeAmount   *float64 `json:"default_trade_amount"`
+		DefaultTradeDuration *int     `json:"default_trade_duration"`
+		Notifications        *struct {
+			TradeResults *bool `json:"trade_results"`
+			Deposits     *bool `json:"deposits"`
+			PriceAlerts  *bool `json:"price_alerts"`
+			Marketing    *bool `json:"marketing"`
+		} `json:"notifications"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	invalid := func(msg string) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	}
+
+	if req.DisplayName != nil {
+		name := strings.TrimSpace(*req.DisplayName)
+		if len([]rune(name)) > 64 {
+			invalid("display_name must be at most 64 characters")
+			return
+		}
+		updates["display_name"] = name
+	}
+	if req.Country != nil {
+		country := strings.ToUpper(*req.Country)
+		if country != "" && !countryCode.MatchString(country) {
+			invalid("country must be a 2-letter ISO code")
+			return
+		}
+		updates["country"] = country
+	}
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			invalid("timezone must be an IANA zone such as Europe/London")
+			return
+		}
+		updates["timezone"] = *req.Timezone
+	}
+	if req.QuoteCurrency != nil {
+		currency := strings.ToUpper(*req.QuoteCurrency)
+		if !models.QuoteCurrencies[currency] {
+			invalid("unsupported quote_currency")
+			return
+		}
+		updates["quote_currency"] = currency
+	}
+	if req.DefaultTradeAmount != nil {
+		if *req.DefaultTradeAmount <= 0 {
+			invalid("default_trade_amount must be positive")
+			return
+		}
+		updates["default_trade_amount"] = *req.DefaultTradeAmount
+	}
+	if req.DefaultTradeDuration != nil {
+		if *req.DefaultTradeDuration < 5 || *req.DefaultTradeDuration > 86400 {
+			invalid("default_trade_duration must be between 5 and 86400 seconds")
+			return
+		}
+		updates["default_trade_duration"] = *req.DefaultTradeDuration
+	}
+	if n := req.Notifications; n != nil {
+		for col, v := range map[string]*bool{
+			"notify_trade_results": n.TradeResults,
+			"notify_deposits":      n.Deposits,
+			"notify_price_alerts":  n.PriceAlerts,
+			"notify_marketing":     n.Marketing,
+		} {
+			if v != nil {
+				updates[col] = *v
+			}
+		}
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if len(updates) > 0 {
+		if err := config.DB.Model(user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		config.DB.First(user, user.ID)
+	}
+	writeProfile(c, user)
+}
+
+// ChangeEmail godoc
+// @Summary Change email address
+// @Description Sends a confirmation link to the new address; the change applies once it is confirmed via /auth/email/confirm. Requires a 2FA code when enabled.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param body body object{email=string,password=string,otp=string} true "New email, current password and 2FA code"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /profile/email [post]
+func ChangeEmail(c *gin.Context) {
+	var req struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		OTP      string `json:"otp"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !emailAcceptable(c, req.Email) {
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if !requireStepUp(c, user.ID, req.OTP) {
+		return
+	}
+	if strings.EqualFold(req.Email, user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "That is already your email address"})
+		return
+	}
+
+	err := services.RequestEmailChange(user, req.Email)
+	if errors.Is(err, services.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation email"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Confirmation sent to the new address", "pending_email": req.Email})
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm a new email address
+// @Description Consume the token from the confirmation email sent to the new address
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body object{token=string} true "Confirmation token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/email/confirm [post]
+func ConfirmEmailChange(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, oldEmail, err := services.ConfirmEmailChange(req.Token)
+	switch {
+	case errors.Is(err, services.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidUserToken.Error()})
+		return
+	}
+
+	audit(c, user.ID, services.AuditEmailChange, "user", user.ID,
+		gin.H{"email": oldEmail}, gin.H{"email": user.Email})
+	c.JSON(http.StatusOK, gin.H{"message": "Email address updated", "email": user.Email})
+}
+
+// UploadAvatar godoc
+// @Summary Upload avatar
+// @Description JPEG, PNG, GIF or WebP, max 2 MB; replaces the current avatar
+// @Tags profile
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "Avatar image"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /profile/avatar [post]
+func UploadAvatar(c *gin.Context) {
+	data, ext, err := readUpload(c, "avatar", maxAvatarSize, avatarTypes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := services.SaveAvatar(user, data, ext); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save avatar"})
+		return
+	}
+	config.DB.First(user, user.ID)
+	c.JSON(http.StatusOK, gin.H{"avatar_url": avatarURL(user)})
+}
+
+// DeleteAvatar godoc
+// @Summary Remove avatar
+// @Tags profile
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /profile/avatar [delete]
+func DeleteAvatar(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := services.DeleteAvatar(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove avatar"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Avatar removed"})
+}
+
+// GetAvatar godoc
+// @Summary Get a user's avatar
+// @Tags profile
+// @Produce image/jpeg
+// @Produce image/png
+// @Param id path int true "User ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/avatar [get]
+func GetAvatar(c *gin.Context) {
+	var user models.User
+	if err := config.DB.Select("id", "avatar_path").First(&user, c.Param("id")).Error; err != nil || user.AvatarPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.File(user.AvatarPath)
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// gamingBlocked writes a 403 for self-exclusion or a user-set limit and reports whether it did
+func gamingBlocked(c *gin.Context, err error) bool {
+	var excluded *services.SelfExcludedError
+	var limitErr *services.GamingLimitError
+	switch {
+	case errors.As(err, &excluded):
+		c.JSON(http.StatusForbidden, gin.H{"error": excluded.Error(), "self_excluded_until": excluded.Until})
+	case errors.As(err, &limitErr):
+		c.JSON(http.StatusForbidden, gin.H{"error": limitErr.Error(), "remaining": limitErr.Remaining})
+	case errors.Is(err, services.ErrPriceUnavailable):
+		// Limits are in USD; a crypto amount can't be checked without a price
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Price unavailable, try again shortly"})
+	default:
+		return false
+	}
+	return true
+}
+
+// GetResponsibleGaming godoc
+// @Summary Get responsible-gaming settings
+// @Description Deposit and loss limits with current usage, pending changes, self-exclusion and session reminder
+// @Tags responsible-gaming
+// @Produce json
+// @Success 200 {object} services.ResponsibleGaming
+// @Security ApiKeyAuth
+// @Router /responsible-gaming [get]
+func GetResponsibleGaming(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	rg, err := services.ResponsibleGamingFor(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load limits"})
+		return
+	}
+	c.JSON(http.StatusOK, rg)
+}
+
+// SetGamingLimit godoc
+// @Summary Set a deposit or loss limit
+// @Description Lowering a limit applies immediately; raising or removing it (amount null) waits out the cooling-off period
+// @Tags responsible-gaming
+// @Accept json
+// @Produce json
+// @Param body body object{kind=string,period=string,amount=number} true "kind: deposit|loss, period: daily|weekly|monthly"
+// @Success 200 {object} models.GamingLimit
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /responsible-gaming/limits [put]
+func SetGamingLimit(c *gin.Context) {
+	var req struct {
+		Kind   string   `json:"kind" binding:"required"`
+		Period string   `json:"period" binding:"required"`
+		Amount *float64 `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	limit, err := services.SetGamingLimit(userID, req.Kind, req.Period, req.Amount)
+	if errors.Is(err, services.ErrInvalidLimit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set limit"})
+		return
+	}
+
+	audit(c, userID, services.AuditGamingLimit, "user", userID, nil, limit)
+	c.JSON(http.StatusOK, limit)
+}
+
+// SetSessionReminder godoc
+// @Summary Set session time reminder
+// @Description Get a session_reminder WebSocket message every N minutes of a session (0 disables)
+// @Tags responsible-gaming
+// @Accept json
+// @Produce json
+// @Param body body object{minutes=int} true "Reminder interval"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /responsible-gaming/session-reminder [put]
+func SetSessionReminder(c *gin.Context) {
+	var req struct {
+		Minutes *int `json:"minutes" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.SetSessionReminder(c.GetUint("userID"), *req.Minutes)
+	if errors.Is(err, services.ErrSessionReminderLength) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set reminder"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session_reminder_minutes": *req.Minutes})
+}
+
+// SelfExclude godoc
+// @Summary Self-exclude
+// @Description Block deposits and trading for a period (24h, 7d, 30d, 90d, 180d, 365d or permanent). Cannot be shortened or undone; withdrawals stay available.
+// @Tags responsible-gaming
+// @Accept json
+// @Produce json
+// @Param body body object{period=string} true "Exclusion period"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /responsible-gaming/self-exclusion [post]
+func SelfExclude(c *gin.Context) {
+	var req struct {
+		Period string `json:"period" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	until, err := services.SelfExclude(userID, req.Period)
+	switch {
+	case errors.Is(err, services.ErrSelfExclusionPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSelfExclusionShorter):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Self-exclusion failed"})
+	default:
+		audit(c, userID, services.AuditSelfExclude, "user", userID,
+			nil, gin.H{"period": req.Period, "until": until})
+		c.JSON(http.StatusOK, gin.H{"self_excluded_until": until})
+	}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+// currentJTI returns the JTI of the refresh cookie sent with the request, if any.
+func currentJTI(c *gin.Context) string {
+	token, err := c.Cookie("refresh_token")
+	if err != nil || token == "" {
+		return ""
+	}
+	claims, err := utils.ParseRefreshToken(token)
+	if err != nil {
+		return ""
+	}
+	return claims.JTI
+}
+
+// GetSessions godoc
+// @Summary List active sessions
+// @Description Devices currently holding a valid refresh token
+// @Tags sessions
+// @Produce json
+// @Success 200 {array} services.Session
+// @Security ApiKeyAuth
+// @Router /sessions [get]
+func GetSessions(c *gin.Context) {
+	sessions, err := services.ListSessions(c.GetUint("userID"), currentJTI(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Revoke a session
+// @Description Signs the device out; its access token stays valid until expiry
+// @Tags sessions
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var rt models.RefreshToken
+	if err := config.DB.First(&rt, "id = ? AND user_id = ?", c.Param("id"), userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	if err := services.RevokeTokenFamily(rt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	audit(c, userID, services.AuditSessionRevoke, "refresh_token", rt.JTI, nil, gin.H{"family_id": rt.FamilyID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeAllSessions godoc
+// @Summary Log out everywhere
+// @Description Revokes every refresh token for the account, including this one
+// @Tags sessions
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /sessions/revoke-all [post]
+func RevokeAllSessions(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if err := services.RevokeAllRefreshTokens(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+	clearRefreshCookie(c)
+	audit(c, userID, services.AuditSessionRevoke, "user", userID, nil, gin.H{"all": true})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
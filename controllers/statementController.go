@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// Ranges longer than this must go through the async export endpoints
+const maxSyncStatementRange = 92 * 24 * time.Hour
+
+var statementContentTypes = map[string]string{
+	"csv": "text/csv",
+	"pdf": "application/pdf",
+}
+
+// statementRange resolves from/to for a statement, defaulting `to` to now.
+func statementRange(from, to *time.Time) (time.Time, time.Time, error) {
+	if from == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("from is required")
+	}
+	end := time.Now()
+	if to != nil {
+		end = *to
+	}
+	if !from.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	return *from, end, nil
+}
+
+// DownloadStatement godoc
+// @Summary Download account statement
+// @Description Generate a statement (opening balance, line items, closing balance per wallet) as CSV or PDF
+// @Tags statement
+// @Produce text/csv
+// @Produce application/pdf
+// @Param from query string true "Start of period (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "End of period, exclusive (defaults to now; YYYY-MM-DD includes that day)"
+// @Param format query string false "csv or pdf" default(csv)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /statements [get]
+func DownloadStatement(c *gin.Context) {
+	serveStatement(c, c.GetUint("userID"))
+}
+
+// serveStatement streams the statement for userID using the request's query params.
+func serveStatement(c *gin.Context, userID uint) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := statementContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or pdf"})
+		return
+	}
+
+	qFrom, qTo, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := statementRange(qFrom, qTo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if to.Sub(from) > maxSyncStatementRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range too large, use POST /statements/exports"})
+		return
+	}
+
+	st, err := services.BuildStatement(userID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build statement"})
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%s-%s.%s", from.Format("20060102"), to.Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	if err := services.WriteStatement(c.Writer, st, format); err != nil {
+		c.Error(err)
+	}
+}
+
+// CreateStatementExport godoc
+// @Summary Request an async statement export
+// @Description Queue statement generation for large ranges; poll the export and download when READY
+// @Tags statement
+// @Accept json
+// @Produce json
+// @Param export body object{from=string,to=string,format=string} true "Export request (RFC3339 times)"
+// @Success 202 {object} models.StatementExport
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /statements/exports [post]
+func CreateStatementExport(c *gin.Context) {
+	var req struct {
+		From   *time.Time `json:"from" binding:"required"`
+		To     *time.Time `json:"to"`
+		Format string     `json:"format"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	if _, ok := statementContentTypes[req.Format]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or pdf"})
+		return
+	}
+	from, to, err := statementRange(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	export := models.StatementExport{
+		UserID: c.GetUint("userID"),
+		From:   from,
+		To:     to,
+		Format: req.Format,
+		Status: "PENDING",
+	}
+	if err := config.DB.Create(&export).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue export"})
+		return
+	}
+
+	go services.GenerateStatementExport(export.ID)
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// GetStatementExports godoc
+// @Summary List statement exports
+// @Tags statement
+// @Produce json
+// @Success 200 {array} models.StatementExport
+// @Security ApiKeyAuth
+// @Router /statements/exports [get]
+func GetStatementExports(c *gin.Context) {
+	var exports []models.StatementExport
+	config.DB.Where("user_id = ?", c.GetUint("userID")).Order("created_at DESC").Find(&exports)
+	c.JSON(http.StatusOK, exports)
+}
+
+// GetStatementExport godoc
+// @Summary Get statement export status
+// @Tags statement
+// @Produce json
+// @Param id path int true "Export ID"
+// @Success 200 {object} models.StatementExport
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /statements/exports/{id} [get]
+func GetStatementExport(c *gin.Context) {
+	var export models.StatementExport
+	if err := config.DB.First(&export, "id = ? AND user_id = ?", c.Param("id"), c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// DownloadStatementExport godoc
+// @Summary Download a generated statement
+// @Tags statement
+// @Produce text/csv
+// @Produce application/pdf
+// @Param id path int true "Export ID"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /statements/exports/{id}/download [get]
+func DownloadStatementExport(c *gin.Context) {
+	var export models.StatementExport
+	if err := config.DB.First(&export, "id = ? AND user_id = ?", c.Param("id"), c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+	if export.Status != "READY" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Export not ready", "status": export.Status})
+		return
+	}
+
+	c.Header("Content-Type", statementContentTypes[export.Format])
+	c.FileAttachment(export.FilePath, filepath.Base(export.FilePath))
+}
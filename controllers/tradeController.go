@@ -1,28 +1,35 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/solchef/crypto-options-backend/config"
 	"github.com/solchef/crypto-options-backend/models"
 	"github.com/solchef/crypto-options-backend/services"
+	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
 )
 
+var errInsufficientBalance = errors.New("insufficient balance")
+
 // PlaceTrade godoc
 // @Summary Place a trade
-// @Description Place a new trade with immediate debit from wallet
+// @Description Place a new trade with immediate debit from wallet. asset must be a trading market and duration one of its allowed_durations; a win pays the market's payout rate.
 // @Tags trade
 // @Accept json
 // @Produce json
 // @Param trade body object{wallet_id=uint,asset=string,amount=number,direction=string,duration=int} true "Trade request"
 // @Success 200 {object} models.Trade
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]interface{} "KYC stake limit or loss limit exceeded, or self-excluded"
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string "Trading halted on the symbol or no reliable index price"
 // @Security ApiKeyAuth
 // @Router /trades/place [post]
 func PlaceTrade(c *gin.Context) {
@@ -37,6 +44,22 @@ func PlaceTrade(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+		return
+	}
+	if req.Direction = strings.ToUpper(req.Direction); req.Direction != "UP" && req.Direction != "DOWN" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be UP or DOWN"})
+		return
+	}
+
+	market, err := services.TradableMarket(req.Asset, req.Duration)
+	if err != nil {
+		if !marketError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place trade"})
+		}
+		return
+	}
 
 	userID := c.GetUint("userID")
 
@@ -53,39 +76,78 @@ func PlaceTrade(c *gin.Context) {
 		return
 	}
 
-	// Get current price from Binance
-	price, err := services.GetCurrentPriceByAPI(req.Asset)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
+	if err := services.CheckTradeAllowed(userID, wallet.Currency, req.Amount, wallet.IsDemo); err != nil {
+		if !gamingBlocked(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place trade"})
+		}
 		return
 	}
 
-	// Immediate debit
-	wallet.Balance -= req.Amount
-	config.DB.Save(&wallet)
+	// Stake limits only apply to real money
+	if !wallet.IsDemo {
+		if err := services.CheckStakeLimit(userID, wallet.Currency, req.Amount); err != nil {
+			if !kycLimitExceeded(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place trade"})
+			}
+			return
+		}
+	}
+
+	// Entry at the composite index price
+	index, err := services.IndexPrice(market.Symbol)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Price unavailable, try again shortly"})
+		return
+	}
 
-	// Save trade
+	// Debit, trade and ledger entry commit together. The debit is conditional
+	// so concurrent trades can't spend the same balance twice.
 	trade := models.Trade{
 		UserID:     userID,
 		WalletID:   wallet.ID,
-		Asset:      req.Asset,
+		Asset:      market.Symbol,
 		Amount:     req.Amount,
 		Direction:  req.Direction,
-		EntryPrice: price,
+		EntryPrice: index.Price,
+		Payout:     market.Payout,
 		Duration:   req.Duration,
 		CreatedAt:  time.Now(),
 		ExpiredAt:  time.Now().Add(time.Duration(req.Duration) * time.Second),
 		Status:     "OPEN",
+		IsDemo:     wallet.IsDemo,
 	}
-	config.DB.Create(&trade)
-
-	config.DB.Create(&models.WalletTransaction{
-		WalletID:  trade.WalletID,
-		Amount:    -req.Amount,
-		Type:      "trade",
-		Reference: fmt.Sprintf("Trade #%d", trade.ID),
-		CreatedAt: time.Now(),
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Wallet{}).
+			Where("id = ? AND balance >= ?", wallet.ID, req.Amount).
+			Update("balance", gorm.Expr("balance - ?", req.Amount))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInsufficientBalance
+		}
+		if err := tx.Create(&trade).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WalletTransaction{
+			WalletID:  trade.WalletID,
+			Amount:    -req.Amount,
+			Type:      "trade",
+			Reference: fmt.Sprintf("Trade #%d", trade.ID),
+			TradeID:   &trade.ID,
+			CreatedAt: time.Now(),
+		}).Error
 	})
+	if errors.Is(err, errInsufficientBalance) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to place trade"})
+		return
+	}
+
+	audit(c, userID, services.AuditTradePlace, "trade", trade.ID, nil, trade)
 
 	// 🔹 Launch goroutine to settle after expiry
 	go services.SettleTrade(trade.ID)
@@ -98,29 +160,104 @@ func PlaceTrade(c *gin.Context) {
 // @Description Retrieve all open trades for the logged-in user
 // @Tags trade
 // @Produce json
+// @Param demo query bool false "Show demo trades instead of real ones"
 // @Success 200 {array} models.Trade
 // @Failure 401 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /trades/open [get]
 func GetOpenTrades(c *gin.Context) {
 	var trades []models.Trade
-	config.DB.Where("user_id = ? AND status = ?", c.GetUint("userID"), "OPEN").Find(&trades)
+	config.DB.Where("user_id = ? AND status = ? AND is_demo = ?", c.GetUint("userID"), "OPEN", c.Query("demo") == "true").Find(&trades)
 	c.JSON(http.StatusOK, trades)
 }
 
 // GetTradeHistory godoc
 // @Summary Get trade history
-// @Description Retrieve all trades (open and closed) for the logged-in user
+// @Description Retrieve a page of trades (open and closed) for the logged-in user (cursor-based)
 // @Tags trade
 // @Produce json
-// @Success 200 {array} models.Trade
+// @Param demo query bool false "Show demo trades instead of real ones"
+// @Param asset query string false "Filter by asset (e.g. BTCUSDT)"
+// @Param status query string false "Filter by status (OPEN, WON, LOST)"
+// @Param direction query string false "Filter by direction (UP, DOWN)"
+// @Param from query string false "Start of date range (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "End of date range, exclusive (RFC3339, or YYYY-MM-DD to include that day)"
+// @Param sort query string false "Sort field (created_at, amount)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
+// @Param limit query int false "Page size (max 200)" default(50)
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /trades/history [get]
 func GetTradeHistory(c *gin.Context) {
-	var trades []models.Trade
-	config.DB.Where("user_id = ?", c.GetUint("userID")).Find(&trades)
-	c.JSON(http.StatusOK, trades)
+	page, err := parsePageParams(c, map[string]string{
+		"created_at": "created_at",
+		"amount":     "amount",
+	}, "created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := config.DB.Where("user_id = ? AND is_demo = ?", c.GetUint("userID"), c.Query("demo") == "true")
+	if asset := c.Query("asset"); asset != "" {
+		query = query.Where("asset = ?", strings.ToUpper(asset))
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", strings.ToUpper(status))
+	}
+	if direction := c.Query("direction"); direction != "" {
+		query = query.Where("direction = ?", strings.ToUpper(direction))
+	}
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	trades, next, err := paginate(query, page, func(t models.Trade) (interface{}, uint) {
+		if page.sort == "amount" {
+			return t.Amount, t.ID
+		}
+		return t.CreatedAt, t.ID
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trades"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pageResponse{Data: trades, NextCursor: next})
+}
+
+// GetTrade godoc
+// @Summary Get a trade
+// @Description Retrieve a single trade with its wallet transactions
+// @Tags trade
+// @Produce json
+// @Param id path int true "Trade ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /trades/{id} [get]
+func GetTrade(c *gin.Context) {
+	var trade models.Trade
+	if err := config.DB.First(&trade, "id = ? AND user_id = ?", c.Param("id"), c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Trade not found"})
+		return
+	}
+
+	var transactions []models.WalletTransaction
+	config.DB.Where("trade_id = ?", trade.ID).Order("created_at ASC").Find(&transactions)
+
+	c.JSON(http.StatusOK, gin.H{"trade": trade, "transactions": transactions})
 }
 
 // CloseTrade godoc
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+func totpIssuer() string {
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		return v
+	}
+	return "CryptoOptions"
+}
+
+// requireStepUp enforces a fresh second factor for sensitive actions when the
+// user has 2FA enabled. It writes the error response and returns false on failure.
+// API-key requests are no exception: a leaked key secret alone must not be
+// enough to withdraw, so signed requests carry the code in their body too.
+func requireStepUp(c *gin.Context, userID uint, code string) bool {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return false
+	}
+	if !user.TOTPEnabled {
+		return true
+	}
+	if code == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "2FA code required", "mfa_required": true})
+		return false
+	}
+	if !services.VerifySecondFactor(&user, code) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid 2FA code", "mfa_required": true})
+		return false
+	}
+	return true
+}
+
+// LoginVerify2FA godoc
+// @Summary Complete login with a 2FA code
+// @Description Exchange the mfa_token from /auth/login plus a TOTP or recovery code for access/refresh tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body object{mfa_token=string,code=string} true "Challenge and code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/login/2fa [post]
+func LoginVerify2FA(c *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	claims, err := utils.ParseMFAChallengeToken(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired challenge"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, claims.Sub).Error; err != nil || user.Frozen {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+		return
+	}
+	if lockedFor := services.LoginLockedFor(&user); lockedFor > 0 {
+		accountLocked(c, lockedFor)
+		return
+	}
+	if !services.VerifySecondFactor(&user, req.Code) {
+		audit(c, 0, services.AuditLoginFailed, "user", user.ID, nil, gin.H{"reason": "bad 2fa code"})
+		if lockedFor := services.RecordFailedLogin(&user); lockedFor > 0 {
+			accountLocked(c, lockedFor)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid 2FA code"})
+		return
+	}
+
+	services.ResetFailedLogins(&user)
+	issueSession(c, user)
+}
+
+// Setup2FA godoc
+// @Summary Start 2FA enrollment
+// @Description Generate a TOTP secret and otpauth:// provisioning URI (render it as a QR code). Confirm with /2fa/enable
+// @Tags 2fa
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /2fa/setup [post]
+func Setup2FA(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA already enabled"})
+		return
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := services.SetTOTPSecret(user.ID, secret); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(totpIssuer(), user.Username, secret),
+	})
+}
+
+// Enable2FA godoc
+// @Summary Confirm 2FA enrollment
+// @Description Verify a code from the authenticator app, enable 2FA and return one-time recovery codes
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Param body body object{code=string} true "TOTP code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /2fa/enable [post]
+func Enable2FA(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Call /2fa/setup first"})
+		return
+	}
+	if !services.VerifyTOTP(&user, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid code"})
+		return
+	}
+
+	codes, err := services.ReplaceRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	config.DB.Model(&user).Update("totp_enabled", true)
+	audit(c, user.ID, services.Audit2FAEnable, "user", user.ID, gin.H{"totp_enabled": false}, gin.H{"totp_enabled": true})
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA enabled", "recovery_codes": codes})
+}
+
+// Disable2FA godoc
+// @Summary Disable 2FA
+// @Description Requires a current TOTP or recovery code
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Param body body object{code=string} true "TOTP or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /2fa/disable [post]
+func Disable2FA(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.GetUint("userID")
+	if !requireStepUp(c, userID, req.Code) {
+		return
+	}
+
+	config.DB.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": "", "totp_last_step": 0})
+	config.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{})
+	audit(c, userID, services.Audit2FADisable, "user", userID, gin.H{"totp_enabled": true}, gin.H{"totp_enabled": false})
+
+	c.JSON(http.StatusOK, gin.H{"message": "2FA disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate 2FA recovery codes
+// @Description Invalidates all previous recovery codes. Requires a current TOTP or recovery code
+// @Tags 2fa
+// @Accept json
+// @Produce json
+// @Param body body object{code=string} true "TOTP or recovery code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /2fa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.GetUint("userID")
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil || !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA is not enabled"})
+		return
+	}
+	if !requireStepUp(c, userID, req.Code) {
+		return
+	}
+
+	codes, err := services.ReplaceRecoveryCodes(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recovery codes"})
+		return
+	}
+	audit(c, userID, services.Audit2FARecoveryCodes, "user", userID, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
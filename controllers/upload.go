@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readUpload reads a multipart file field, capped at maxBytes, and sniffs its
+// content type against allowed (content type -> file extension). The client's
+// file name and Content-Type header are ignored.
+func readUpload(c *gin.Context, field string, maxBytes int64, allowed map[string]string) ([]byte, string, error) {
+	fh, err := c.FormFile(field)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s file is required", field)
+	}
+	if fh.Size > maxBytes {
+		return nil, "", fmt.Errorf("%s must be at most %d MB", field, maxBytes>>20)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("%s must be at most %d MB", field, maxBytes>>20)
+	}
+	ext, ok := allowed[http.DetectContentType(data)]
+	if !ok {
+		return nil, "", errors.New("unsupported file type")
+	}
+	return data, ext, nil
+}
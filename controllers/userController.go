@@ -6,20 +6,75 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/solchef/crypto-options-backend/config"
 	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
 )
 
+// GetUsers godoc
+// @Summary List users
+// @Description Admin user lookup by username or email
+// @Tags admin
+// @Produce json
+// @Param q query string false "Username or email substring"
+// @Success 200 {array} models.User
+// @Security ApiKeyAuth
+// @Router /admin/users [get]
 func GetUsers(c *gin.Context) {
 	var users []models.User
-	config.DB.Find(&users)
+	query := config.DB.Order("id ASC").Limit(100)
+	if q := c.Query("q"); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("username ILIKE ? OR email ILIKE ?", like, like)
+	}
+	query.Find(&users)
 	c.JSON(http.StatusOK, users)
 }
 
+// CreateUser godoc
+// @Summary Create a user
+// @Description Admin-only user creation with an explicit role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param user body object{username=string,email=string,password=string,role=string} true "User info"
+// @Success 201 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /admin/users [post]
 func CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	config.DB.Create(&user)
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	hashed, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+	user := models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: hashed,
+		Role:     req.Role,
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Failed to create user"})
+		return
+	}
+	audit(c, c.GetUint("userID"), services.AuditAdminCreate, "user", user.ID, nil, user)
 	c.JSON(http.StatusCreated, user)
 }
@@ -1,12 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/solchef/crypto-options-backend/config"
 	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/services"
 	"gorm.io/gorm"
 )
 
@@ -24,6 +26,9 @@ func GetWallets(c *gin.Context) {
 
 	fmt.Println("userID from context:", userID)
 
+	// Users created before demo mode get their practice wallet lazily
+	services.EnsureDemoWallet(userID)
+
 	var wallets []models.Wallet
 	if err := config.DB.Where("user_id = ?", userID).Find(&wallets).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wallets not found"})
@@ -42,6 +47,7 @@ func GetWallets(c *gin.Context) {
 // @Param deposit body object{currency=string,amount=number} true "Deposit request"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]interface{} "KYC or self-set deposit limit exceeded, or self-excluded"
 // @Failure 404 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /wallets/deposit [post]
@@ -58,22 +64,41 @@ func Deposit(c *gin.Context) {
 	}
 
 	var wallet models.Wallet
-	if err := config.DB.Where("user_id = ? AND currency = ?", userID, req.Currency).First(&wallet).Error; err != nil {
+	if err := config.DB.Where("user_id = ? AND currency = ? AND is_demo = ?", userID, req.Currency, false).First(&wallet).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
 		return
 	}
 
+	if err := services.CheckDepositLimit(userID, wallet.Currency, req.Amount); err != nil {
+		if !kycLimitExceeded(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Deposit failed"})
+		}
+		return
+	}
+	if err := services.CheckDepositAllowed(userID, wallet.Currency, req.Amount); err != nil {
+		if !gamingBlocked(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Deposit failed"})
+		}
+		return
+	}
+
+	before := wallet.Balance
+	deposit := models.Deposit{UserID: userID, WalletID: wallet.ID, Amount: req.Amount, Reference: "manual_deposit"}
 	err := config.DB.Transaction(func(tx *gorm.DB) error {
 		wallet.Balance += req.Amount
 		if err := tx.Save(&wallet).Error; err != nil {
 			return err
 		}
+		if err := tx.Create(&deposit).Error; err != nil {
+			return err
+		}
 
 		return tx.Create(&models.WalletTransaction{
 			WalletID:  wallet.ID,
 			Amount:    req.Amount,
 			Type:      "deposit",
-			Reference: "manual_deposit",
+			Reference: deposit.Reference,
+			DepositID: &deposit.ID,
 		}).Error
 	})
 
@@ -82,7 +107,10 @@ func Deposit(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Deposit successful", "balance": wallet.Balance})
+	audit(c, userID, services.AuditDeposit, "wallet", wallet.ID,
+		gin.H{"balance": before}, gin.H{"balance": wallet.Balance, "amount": req.Amount, "deposit_id": deposit.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Deposit successful", "balance": wallet.Balance, "deposit_id": deposit.ID})
 }
 
 // Withdraw godoc
@@ -91,9 +119,10 @@ func Deposit(c *gin.Context) {
 // @Tags wallet
 // @Accept json
 // @Produce json
-// @Param withdraw body object{amount=number} true "Withdraw request"
+// @Param withdraw body object{amount=number,otp=string} true "Withdraw request (otp required when 2FA is enabled, API-key requests included)"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]interface{} "KYC withdrawal limit exceeded"
 // @Failure 404 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /wallets/withdraw [post]
@@ -102,14 +131,18 @@ func Withdraw(c *gin.Context) {
 
 	var req struct {
 		Amount float64 `json:"amount"`
+		OTP    string  `json:"otp"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil || req.Amount <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
 		return
 	}
+	if !requireStepUp(c, userID, req.OTP) {
+		return
+	}
 
 	var wallet models.Wallet
-	if err := config.DB.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := config.DB.Where("user_id = ? AND is_demo = ?", userID, false).First(&wallet).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Wallet not found"})
 		return
 	}
@@ -119,41 +152,94 @@ func Withdraw(c *gin.Context) {
 		return
 	}
 
-	config.DB.Transaction(func(tx *gorm.DB) error {
-		wallet.Balance -= req.Amount
-		if err := tx.Save(&wallet).Error; err != nil {
-			return err
+	if err := services.CheckWithdrawLimit(userID, wallet.Currency, req.Amount); err != nil {
+		if !kycLimitExceeded(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Withdrawal failed"})
 		}
+		return
+	}
 
-		tx.Create(&models.WalletTransaction{
-			WalletID:  wallet.ID,
-			Amount:    -req.Amount,
-			Type:      "withdraw",
-			Reference: "manual_withdrawal",
-		})
+	before := wallet.Balance
+	withdrawal := models.Withdrawal{UserID: userID, WalletID: wallet.ID, Amount: req.Amount, Reference: "manual_withdrawal"}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Conditional so concurrent withdrawals can't overdraw the wallet
+		res := tx.Model(&models.Wallet{}).Where("id = ? AND balance >= ?", wallet.ID, req.Amount).
+			Update("balance", gorm.Expr("balance - ?", req.Amount))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInsufficientBalance
+		}
+		if err := tx.Create(&withdrawal).Error; err != nil {
+			return err
+		}
 
-		return nil
+		return tx.Create(&models.WalletTransaction{
+			WalletID:     wallet.ID,
+			Amount:       -req.Amount,
+			Type:         "withdraw",
+			Reference:    withdrawal.Reference,
+			WithdrawalID: &withdrawal.ID,
+		}).Error
 	})
+	if errors.Is(err, errInsufficientBalance) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Withdrawal failed"})
+		return
+	}
+	wallet.Balance = before - req.Amount
 
-	c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful", "balance": wallet.Balance})
+	audit(c, userID, services.AuditWithdraw, "wallet", wallet.ID,
+		gin.H{"balance": before}, gin.H{"balance": wallet.Balance, "amount": req.Amount, "withdrawal_id": withdrawal.ID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Withdrawal successful", "balance": wallet.Balance, "withdrawal_id": withdrawal.ID})
 }
 
 // GetWalletTransactions godoc
 // @Summary Get wallet transactions
-// @Description Retrieve transactions for user's wallets
+// @Description Retrieve a page of transactions for user's wallets (cursor-based)
 // @Tags wallet
 // @Produce json
 // @Param currency query string false "Filter by currency"
-// @Success 200 {array} models.WalletTransaction
-// @Failure 404 {object} map[string]string
+// @Param demo query bool false "Show demo wallet transactions instead of real ones"
+// @Param type query string false "Filter by type (deposit, withdraw, trade, trade_win)"
+// @Param trade_id query int false "Filter by originating trade"
+// @Param deposit_id query int false "Filter by originating deposit"
+// @Param withdrawal_id query int false "Filter by originating withdrawal"
+// @Param from query string false "Start of date range (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "End of date range, exclusive (RFC3339, or YYYY-MM-DD to include that day)"
+// @Param sort query string false "Sort field (created_at, amount)" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
+// @Param limit query int false "Page size (max 200)" default(50)
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /wallets/transactions [get]
 func GetWalletTransactions(c *gin.Context) {
 	userID := c.GetUint("userID")
 	currency := c.Query("currency")
 
+	page, err := parsePageParams(c, map[string]string{
+		"created_at": "created_at",
+		"amount":     "amount",
+	}, "created_at")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	var walletIDs []uint
-	query := config.DB.Model(&models.Wallet{}).Where("user_id = ?", userID)
+	query := config.DB.Model(&models.Wallet{}).Where("user_id = ? AND is_demo = ?", userID, c.Query("demo") == "true")
 	if currency != "" {
 		query = query.Where("currency = ?", currency)
 	}
@@ -164,19 +250,59 @@ func GetWalletTransactions(c *gin.Context) {
 	}
 
 	if len(walletIDs) == 0 {
-		c.JSON(http.StatusOK, []models.WalletTransaction{})
+		c.JSON(http.StatusOK, pageResponse{Data: []models.WalletTransaction{}})
 		return
 	}
 
 	// Step 2: Get transactions for these wallets
-	var transactions []models.WalletTransaction
-	if err := config.DB.
-		Where("wallet_id IN ?", walletIDs).
-		Order("created_at DESC").
-		Find(&transactions).Error; err != nil {
+	txQuery := config.DB.Where("wallet_id IN ?", walletIDs)
+	if t := c.Query("type"); t != "" {
+		txQuery = txQuery.Where("type = ?", t)
+	}
+	for _, f := range []string{"trade_id", "deposit_id", "withdrawal_id"} {
+		if id := c.Query(f); id != "" {
+			txQuery = txQuery.Where(f+" = ?", id)
+		}
+	}
+	if from != nil {
+		txQuery = txQuery.Where("created_at >= ?", *from)
+	}
+	if to != nil {
+		txQuery = txQuery.Where("created_at < ?", *to)
+	}
+
+	transactions, next, err := paginate(txQuery, page, func(t models.WalletTransaction) (interface{}, uint) {
+		if page.sort == "amount" {
+			return t.Amount, t.ID
+		}
+		return t.CreatedAt, t.ID
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
 		return
 	}
 
-	c.JSON(http.StatusOK, transactions)
+	c.JSON(http.StatusOK, pageResponse{Data: transactions, NextCursor: next})
+}
+
+// ResetDemoWallet godoc
+// @Summary Reset demo wallet
+// @Description Restore the virtual demo wallet to its starting balance
+// @Tags wallet
+// @Produce json
+// @Success 200 {object} models.Wallet
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /wallets/demo/reset [post]
+func ResetDemoWallet(c *gin.Context) {
+	wallet, err := services.ResetDemoWallet(c.GetUint("userID"))
+	if errors.Is(err, services.ErrDemoTradesOpen) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Close or wait for open demo trades before resetting"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Demo reset failed"})
+		return
+	}
+	c.JSON(http.StatusOK, wallet)
 }
@@ -0,0 +1,322 @@
+// Package indicators computes technical indicators over candle series.
+package indicators
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+var (
+	ErrUnknownType   = errors.New("type must be one of sma, ema, rsi, macd, bollinger, atr")
+	ErrInvalidParams = errors.New("invalid indicator params")
+)
+
+// Point is an indicator's output at one candle. Candles still inside the
+// warm-up period produce no point.
+type Point struct {
+	Time   int64              `json:"time"`   // candle open time, Unix ms
+	Values map[string]float64 `json:"values"` // "value", or the named lines of multi-line indicators
+}
+
+type definition struct {
+	defaults []float64
+	names    []string // output lines
+	compute  func(c []models.Candle, p []float64) [][]float64
+	// candles needed before the first output (period-1 for a plain window),
+	// plus extra history so smoothed values settle
+	lookback func(p []float64) int
+}
+
+var definitions = map[string]definition{
+	"sma": {
+		defaults: []float64{20},
+		names:    []string{"value"},
+		compute:  func(c []models.Candle, p []float64) [][]float64 { return [][]float64{sma(closes(c), int(p[0]))} },
+		lookback: func(p []float64) int { return int(p[0]) - 1 },
+	},
+	"ema": {
+		defaults: []float64{20},
+		names:    []string{"value"},
+		compute:  func(c []models.Candle, p []float64) [][]float64 { return [][]float64{ema(closes(c), int(p[0]))} },
+		lookback: func(p []float64) int { return 4 * int(p[0]) },
+	},
+	"rsi": {
+		defaults: []float64{14},
+		names:    []string{"value"},
+		compute:  func(c []models.Candle, p []float64) [][]float64 { return [][]float64{rsi(closes(c), int(p[0]))} },
+		lookback: func(p []float64) int { return 4*int(p[0]) + 1 },
+	},
+	"macd": {
+		defaults: []float64{12, 26, 9},
+		names:    []string{"macd", "signal", "histogram"},
+		compute: func(c []models.Candle, p []float64) [][]float64 {
+			m, s, h := macd(closes(c), int(p[0]), int(p[1]), int(p[2]))
+			return [][]float64{m, s, h}
+		},
+		lookback: func(p []float64) int { return 4*int(p[1]) + int(p[2]) },
+	},
+	"bollinger": {
+		defaults: []float64{20, 2},
+		names:    []string{"middle", "upper", "lower"},
+		compute: func(c []models.Candle, p []float64) [][]float64 {
+			m, u, l := bollinger(closes(c), int(p[0]), p[1])
+			return [][]float64{m, u, l}
+		},
+		lookback: func(p []float64) int { return int(p[0]) - 1 },
+	},
+	"atr": {
+		defaults: []float64{14},
+		names:    []string{"value"},
+		compute:  func(c []models.Candle, p []float64) [][]float64 { return [][]float64{atr(c, int(p[0]))} },
+		lookback: func(p []float64) int { return 4*int(p[0]) + 1 },
+	},
+}
+
+// Spec is an indicator type with its parameters, e.g. macd 12,26,9
+type Spec struct {
+	Type   string    `json:"type"`
+	Params []float64 `json:"params"`
+}
+
+// Parse builds a Spec from a type name and comma-separated params; omitted
+// params take the usual defaults (sma/ema 20, rsi/atr 14, macd 12,26,9,
+// bollinger 20,2).
+func Parse(typ, params string) (Spec, error) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	def, ok := definitions[typ]
+	if !ok {
+		return Spec{}, ErrUnknownType
+	}
+	s := Spec{Type: typ, Params: append([]float64(nil), def.defaults...)}
+	if params = strings.TrimSpace(params); params != "" {
+		parts := strings.Split(params, ",")
+		if len(parts) > len(def.defaults) {
+			return Spec{}, fmt.Errorf("%w: %s takes at most %d", ErrInvalidParams, typ, len(def.defaults))
+		}
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return Spec{}, fmt.Errorf("%w: %q is not a number", ErrInvalidParams, part)
+			}
+			s.Params[i] = v
+		}
+	}
+	if err := s.validate(); err != nil {
+		return Spec{}, err
+	}
+	return s, nil
+}
+
+func (s Spec) validate() error {
+	for i, p := range s.Params {
+		// Periods are whole numbers; bollinger's second param is a width multiplier
+		if s.Type == "bollinger" && i == 1 {
+			if p <= 0 || p > 10 {
+				return fmt.Errorf("%w: width must be between 0 and 10", ErrInvalidParams)
+			}
+			continue
+		}
+		if p != math.Trunc(p) || p < 1 || p > 500 {
+			return fmt.Errorf("%w: periods must be whole numbers from 1 to 500", ErrInvalidParams)
+		}
+	}
+	if s.Type == "macd" && s.Params[0] >= s.Params[1] {
+		return fmt.Errorf("%w: macd fast period must be below slow", ErrInvalidParams)
+	}
+	return nil
+}
+
+// String formats the spec as type(params), e.g. macd(12,26,9)
+func (s Spec) String() string {
+	parts := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		parts[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return s.Type + "(" + strings.Join(parts, ",") + ")"
+}
+
+// Lookback is how many candles to load before the first point wanted, so the
+// indicator is both defined and settled there.
+func (s Spec) Lookback() int {
+	return definitions[s.Type].lookback(s.Params)
+}
+
+// Compute evaluates the indicator over candles, oldest first. It returns one
+// point per candle past the warm-up period.
+func (s Spec) Compute(candles []models.Candle) []Point {
+	def := definitions[s.Type]
+	lines := def.compute(candles, s.Params)
+
+	points := make([]Point, 0, len(candles))
+	for i, c := range candles {
+		values := make(map[string]float64, len(lines))
+		for n, line := range lines {
+			if !math.IsNaN(line[i]) {
+				values[def.names[n]] = line[i]
+			}
+		}
+		if len(values) == len(lines) {
+			points = append(points, Point{Time: c.OpenTime, Values: values})
+		}
+	}
+	return points
+}
+
+func closes(candles []models.Candle) []float64 {
+	out := make([]float64, len(candles))
+	for i, c := range candles {
+		out[i] = c.Close
+	}
+	return out
+}
+
+func nans(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
+
+// sma is the simple moving average; NaN until period values are available
+func sma(values []float64, period int) []float64 {
+	out := nans(len(values))
+	var sum float64
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// ema is the exponential moving average, seeded with the SMA of the first
+// period values. Leading NaNs in values are skipped.
+func ema(values []float64, period int) []float64 {
+	out := nans(len(values))
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if len(values)-start < period {
+		return out
+	}
+	k := 2 / float64(period+1)
+	var sum float64
+	for i := start; i < start+period; i++ {
+		sum += values[i]
+	}
+	prev := sum / float64(period)
+	out[start+period-1] = prev
+	for i := start + period; i < len(values); i++ {
+		prev = values[i]*k + prev*(1-k)
+		out[i] = prev
+	}
+	return out
+}
+
+// rsi is Wilder's relative strength index
+func rsi(values []float64, period int) []float64 {
+	out := nans(len(values))
+	if len(values) <= period {
+		return out
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		d := values[i] - values[i-1]
+		gain += math.Max(d, 0)
+		loss += math.Max(-d, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsiValue(gain, loss)
+	for i := period + 1; i < len(values); i++ {
+		d := values[i] - values[i-1]
+		gain = (gain*float64(period-1) + math.Max(d, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-d, 0)) / float64(period)
+		out[i] = rsiValue(gain, loss)
+	}
+	return out
+}
+
+func rsiValue(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// macd is the fast EMA minus the slow EMA, its signal EMA, and their difference
+func macd(values []float64, fast, slow, signal int) (line, sig, hist []float64) {
+	f, s := ema(values, fast), ema(values, slow)
+	line = nans(len(values))
+	for i := range values {
+		if !math.IsNaN(s[i]) {
+			line[i] = f[i] - s[i]
+		}
+	}
+	sig = ema(line, signal)
+	hist = nans(len(values))
+	for i := range values {
+		if !math.IsNaN(sig[i]) {
+			hist[i] = line[i] - sig[i]
+		}
+	}
+	return line, sig, hist
+}
+
+// bollinger is the SMA with bands width population standard deviations away
+func bollinger(values []float64, period int, width float64) (middle, upper, lower []float64) {
+	middle = sma(values, period)
+	upper, lower = nans(len(values)), nans(len(values))
+	for i := period - 1; i < len(values); i++ {
+		var variance float64
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + width*sd
+		lower[i] = middle[i] - width*sd
+	}
+	return middle, upper, lower
+}
+
+// atr is Wilder's average true range
+func atr(candles []models.Candle, period int) []float64 {
+	out := nans(len(candles))
+	if len(candles) <= period {
+		return out
+	}
+	tr := make([]float64, len(candles))
+	for i, c := range candles {
+		tr[i] = c.High - c.Low
+		if i > 0 {
+			prev := candles[i-1].Close
+			tr[i] = math.Max(tr[i], math.Max(math.Abs(c.High-prev), math.Abs(c.Low-prev)))
+		}
+	}
+	// The first range has no previous close, so start averaging from the second
+	var sum float64
+	for i := 1; i <= period; i++ {
+		sum += tr[i]
+	}
+	prev := sum / float64(period)
+	out[period] = prev
+	for i := period + 1; i < len(candles); i++ {
+		prev = (prev*float64(period-1) + tr[i]) / float64(period)
+		out[i] = prev
+	}
+	return out
+}
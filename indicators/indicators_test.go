@@ -0,0 +1,266 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+// Closes from the StockCharts moving-average and RSI worked examples
+var (
+	emaCloses = []float64{22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+		22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+		23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17}
+	rsiCloses = []float64{44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+		45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+		46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57,
+		43.42, 42.66, 43.13}
+)
+
+func candles(closes ...float64) []models.Candle {
+	out := make([]models.Candle, len(closes))
+	for i, c := range closes {
+		out[i] = models.Candle{OpenTime: int64(i) * 60000, Open: c, High: c, Low: c, Close: c}
+	}
+	return out
+}
+
+func linear(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = float64(i)
+	}
+	return out
+}
+
+func repeat(v float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = v
+	}
+	return out
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		params  string
+		candles []models.Candle
+		first   int // index of the first candle past the warm-up
+		want    map[string][]float64
+		tol     float64
+	}{
+		{
+			name:    "sma reference",
+			typ:     "sma",
+			params:  "10",
+			candles: candles(emaCloses...),
+			first:   9,
+			want: map[string][]float64{"value": {22.22, 22.21, 22.23, 22.26, 22.30, 22.42, 22.61, 22.77, 22.91, 23.08,
+				23.21, 23.38, 23.52, 23.65, 23.71, 23.68, 23.61, 23.51, 23.43, 23.28, 23.13}},
+			tol: 0.006, // reference values are rounded to two places
+		},
+		{
+			name:    "ema reference",
+			typ:     "ema",
+			params:  "10",
+			candles: candles(emaCloses...),
+			first:   9,
+			want: map[string][]float64{"value": {22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28,
+				23.34, 23.43, 23.51, 23.53, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92}},
+			tol: 0.006, // reference values are rounded to two places
+		},
+		{
+			name:    "wilder rsi reference",
+			typ:     "rsi",
+			params:  "14",
+			candles: candles(rsiCloses...),
+			first:   14,
+			want: map[string][]float64{"value": {70.46, 66.25, 66.48, 69.35, 66.29, 57.92, 62.88, 63.21, 56.01, 62.34,
+				54.67, 50.39, 40.02, 41.49, 41.90, 45.50, 37.32, 33.09, 37.79}},
+			tol: 0.006, // reference values are rounded to two places
+		},
+		{
+			name:    "rsi only rising",
+			typ:     "rsi",
+			params:  "3",
+			candles: candles(1, 2, 3, 4, 5),
+			first:   3,
+			want:    map[string][]float64{"value": {100, 100}},
+			tol:     1e-9,
+		},
+		{
+			name:    "rsi flat",
+			typ:     "rsi",
+			params:  "3",
+			candles: candles(5, 5, 5, 5),
+			first:   3,
+			want:    map[string][]float64{"value": {50}},
+			tol:     1e-9,
+		},
+		{
+			// An SMA-seeded EMA(p) of a straight line lags it by exactly (p-1)/2,
+			// so the fast and slow EMAs stay 7 apart
+			name:    "macd on a straight line",
+			typ:     "macd",
+			params:  "",
+			candles: candles(linear(36)...),
+			first:   33,
+			want: map[string][]float64{
+				"macd":      {7, 7, 7},
+				"signal":    {7, 7, 7},
+				"histogram": {0, 0, 0},
+			},
+			tol: 1e-9,
+		},
+		{
+			name:    "bollinger",
+			typ:     "bollinger",
+			params:  "5,2",
+			candles: candles(1, 2, 3, 4, 5, 6),
+			first:   4,
+			want: map[string][]float64{
+				"middle": {3, 4},
+				"upper":  {3 + 2*math.Sqrt2, 4 + 2*math.Sqrt2},
+				"lower":  {3 - 2*math.Sqrt2, 4 - 2*math.Sqrt2},
+			},
+			tol: 1e-9,
+		},
+		{
+			name:    "bollinger on a flat series",
+			typ:     "bollinger",
+			params:  "3,2",
+			candles: candles(repeat(7, 3)...),
+			first:   2,
+			want:    map[string][]float64{"middle": {7}, "upper": {7}, "lower": {7}},
+			tol:     1e-9,
+		},
+		{
+			// True ranges from the second candle: 2, 4 (gap up), 2, 0.5
+			name:   "atr with a gap",
+			typ:    "atr",
+			params: "2",
+			candles: []models.Candle{
+				{OpenTime: 0, High: 10, Low: 8, Close: 9},
+				{OpenTime: 1, High: 11, Low: 9, Close: 10},
+				{OpenTime: 2, High: 14, Low: 12, Close: 13},
+				{OpenTime: 3, High: 13, Low: 11, Close: 12},
+				{OpenTime: 4, High: 12.5, Low: 12, Close: 12.2},
+			},
+			first: 2,
+			want:  map[string][]float64{"value": {3, 2.5, 1.5}},
+			tol:   1e-9,
+		},
+		{
+			name:    "too few candles",
+			typ:     "ema",
+			params:  "5",
+			candles: candles(1, 2, 3, 4),
+			first:   4,
+			want:    map[string][]float64{"value": {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := Parse(tt.typ, tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			points := spec.Compute(tt.candles)
+			if want := len(tt.candles) - tt.first; len(points) != want {
+				t.Fatalf("%d points, want %d", len(points), want)
+			}
+			for i, p := range points {
+				if p.Time != tt.candles[tt.first+i].OpenTime {
+					t.Fatalf("point %d at %d, want candle %d", i, p.Time, tt.first+i)
+				}
+				if len(p.Values) != len(tt.want) {
+					t.Fatalf("point %d has values %v, want lines %v", i, p.Values, tt.want)
+				}
+				for name, line := range tt.want {
+					if got := p.Values[name]; math.Abs(got-line[i]) > tt.tol {
+						t.Errorf("point %d %s = %.4f, want %.4f", i, name, got, line[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEMASkipsLeadingNaN(t *testing.T) {
+	nan := math.NaN()
+	got := ema([]float64{nan, nan, 1, 2, 3}, 2)
+	want := []float64{nan, nan, nan, 1.5, 2.5}
+	for i := range want {
+		if math.IsNaN(want[i]) != math.IsNaN(got[i]) || (!math.IsNaN(want[i]) && math.Abs(got[i]-want[i]) > 1e-9) {
+			t.Fatalf("ema = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestLookback(t *testing.T) {
+	// Loading Lookback candles before the wanted one must leave that candle
+	// past the warm-up
+	tests := []struct {
+		typ, params string
+		want        int
+	}{
+		{"sma", "20", 19},
+		{"sma", "1", 0},
+		{"bollinger", "20,2", 19},
+		{"ema", "20", 80},
+		{"rsi", "14", 57},
+		{"macd", "12,26,9", 113},
+		{"atr", "14", 57},
+	}
+	for _, tt := range tests {
+		spec, err := Parse(tt.typ, tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := spec.Lookback(); got != tt.want {
+			t.Errorf("%s lookback %d, want %d", spec, got, tt.want)
+		}
+		n := spec.Lookback() + 1
+		series := candles(linear(n)...)
+		if tt.typ == "atr" {
+			for i := range series {
+				series[i].High++
+			}
+		}
+		if points := spec.Compute(series); len(points) == 0 || points[len(points)-1].Time != series[n-1].OpenTime {
+			t.Errorf("%s undefined at the last of %d candles", spec, n)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		typ, params string
+		want        string // String of the spec, "" for an error
+	}{
+		{"SMA", "", "sma(20)"},
+		{"macd", "5, 35", "macd(5,35,9)"},
+		{"bollinger", "20,2.5", "bollinger(20,2.5)"},
+		{"vwap", "", ""},
+		{"ema", "1.5", ""},
+		{"ema", "0", ""},
+		{"rsi", "14,2", ""},
+		{"macd", "26,12,9", ""},
+		{"bollinger", "20,11", ""},
+	}
+	for _, tt := range tests {
+		spec, err := Parse(tt.typ, tt.params)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Parse(%q, %q) = %s, want error", tt.typ, tt.params, spec)
+			}
+			continue
+		}
+		if err != nil || spec.String() != tt.want {
+			t.Errorf("Parse(%q, %q) = %s, %v; want %s", tt.typ, tt.params, spec, err, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package main
 import (
 	"log"
 	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -17,6 +18,7 @@ import (
 	"github.com/solchef/crypto-options-backend/models"
 	"github.com/solchef/crypto-options-backend/routes"
 	"github.com/solchef/crypto-options-backend/services"
+	"github.com/solchef/crypto-options-backend/utils"
 )
 
 // @title Crypto Options API
@@ -38,26 +40,70 @@ func main() {
 		log.Println("⚠️ No .env file found")
 	}
 
+	// Signing keys; refuses to start without them outside dev mode
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatal("JWT keys: ", err)
+	}
+
 	// Connect DB
 	config.ConnectDB()
+	if err := services.InitMailer(); err != nil {
+		log.Fatal("Mailer: ", err)
+	}
+	services.InitRateLimiter()
+	services.InitKYCProvider()
+	services.StartNonceJanitor()
+	services.StartSessionReminders()
 
 	// Auto Migrate
 	config.DB.AutoMigrate(
 		&models.User{},
 		&models.RefreshToken{},
 		&models.Wallet{},
+		&models.Deposit{},
+		&models.Withdrawal{},
 		&models.WalletTransaction{},
 		&models.Trade{},
+		&models.StatementExport{},
+		&models.AuditLog{},
+		&models.RecoveryCode{},
+		&models.UserToken{},
+		&models.RateLimitBucket{},
+		&models.APIKey{},
+		&models.APINonce{},
+		&models.UserIdentity{},
+		&models.OIDCState{},
+		&models.KYCSubmission{},
+		&models.GamingLimit{},
+		&models.StoredCandle{},
+		&models.Tick{},
+		&models.Market{},
+		&models.PriceAlert{},
 	)
+	services.EnsureAuditImmutable()
+	services.EnsureDemoWalletIndex()
+	services.EncryptTOTPSecrets()
+
+	// Promote the configured bootstrap admin (if any)
+	services.BootstrapAdmin(os.Getenv("ADMIN_USERNAME"))
 
-	// Start price streams
-	// go services.ListenPriceStream("btcusdt")
-	// go services.ListenPriceStream("ethusdt")
-	// go services.ListenTickerStream("btcusdt")
-	// go services.ListenTickerStream("ethusdt")
+	// Start price streams; the candle store subscribes first so it sees every tick
+	services.StartCandleStore()
+	services.StartMarketRecorder()
+	services.InitPriceIndex()
+	services.SeedMarkets(services.MarketSymbols())
+	services.StartFeedMonitor()
+	services.StartOrderBooks()
+	services.StartPriceAlerts()
+	services.StartMarketStreams(services.ActiveMarketSymbols())
 
 	// Setup Gin
 	r := gin.Default()
+	// Only honour X-Forwarded-For from configured proxies, or client IPs (and
+	// every per-IP limit) could be spoofed
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("TRUSTED_PROXIES: ", err)
+	}
 	r.Use(middleware.CORSMiddleware())
 	routes.RegisterRoutes(r)
 
@@ -80,3 +126,15 @@ func main() {
 	}
 	r.Run(":" + port)
 }
+
+// trustedProxies reads the comma-separated IPs/CIDRs in TRUSTED_PROXIES;
+// none by default.
+func trustedProxies() []string {
+	var out []string
+	for _, p := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -4,14 +4,20 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v4"
 	"github.com/solchef/crypto-options-backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// AuthMiddleware accepts either a bearer access token or an HMAC-signed API key
+// request (X-API-Key, X-API-Timestamp, X-API-Nonce, X-API-Signature).
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if c.GetHeader("X-API-Key") != "" {
+			authenticateAPIKey(c)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
@@ -21,26 +27,17 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := utils.ValidateJWT(tokenString)
-		if err != nil || !token.Valid {
+		// Only access tokens are accepted; refresh and MFA tokens fail the typ/aud check
+		claims, err := utils.ParseAccessToken(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid claims"})
-			c.Abort()
-			return
-		}
-
-		if sub, ok := claims["sub"].(float64); ok {
-			c.Set("userID", uint(sub))
-		}
-		if username, ok := claims["username"].(string); ok {
-			c.Set("username", username)
-		}
+		c.Set("userID", claims.Sub)
+		c.Set("username", claims.Username)
+		c.Set("authMethod", "jwt")
 
 		c.Next()
 	}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/services"
+)
+
+// Limit is a token bucket: Rate tokens per second, up to Burst at once.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests per minute with bursts of up to burst.
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// KeyFunc picks the identity a limit is counted against.
+type KeyFunc func(c *gin.Context) string
+
+// ByIP keys on the client IP.
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser keys on the authenticated user, falling back to IP when anonymous.
+func ByUser(c *gin.Context) string {
+	if id := c.GetUint("userID"); id != 0 {
+		return "user:" + strconv.FormatUint(uint64(id), 10)
+	}
+	return ByIP(c)
+}
+
+// RateLimit rejects requests over limit with 429 and a Retry-After header.
+// name scopes the bucket to a route; store errors fail open.
+func RateLimit(name string, limit Limit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if services.RateLimiter == nil {
+			c.Next()
+			return
+		}
+		allowed, retryAfter, err := services.RateLimiter.Take(name+":"+key(c), limit.Rate, limit.Burst)
+		if err != nil {
+			log.Println("rate limit:", err)
+			c.Next()
+			return
+		}
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+// AccountStatus loads the authenticated user, rejects frozen accounts and
+// stores the current role in the context. Must run after AuthMiddleware.
+func AccountStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		if err := config.DB.Select("id", "role", "frozen").First(&user, c.GetUint("userID")).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if user.Frozen {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is frozen"})
+			c.Abort()
+			return
+		}
+
+		c.Set("role", user.Role)
+		c.Next()
+	}
+}
+
+// RequirePermission aborts with 403 unless the user's role grants every perm.
+// Must run after AccountStatus.
+func RequirePermission(perms ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, p := range perms {
+			if !models.HasPermission(role, p) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API key scopes
+const (
+	ScopeRead     = "read"
+	ScopeTrade    = "trade"
+	ScopeWithdraw = "withdraw"
+)
+
+var ValidScopes = map[string]bool{ScopeRead: true, ScopeTrade: true, ScopeWithdraw: true}
+
+// APIKey lets a user's scripts call the API with HMAC-signed requests.
+// The secret is needed to verify signatures, so it's stored encrypted, not hashed.
+type APIKey struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	UserID          uint       `gorm:"not null;index" json:"-"`
+	Name            string     `json:"name"`
+	KeyID           string     `gorm:"uniqueIndex;size:64;not null" json:"key_id"`
+	SecretEncrypted string     `gorm:"not null" json:"-"`
+	Scopes          string     `json:"scopes"`      // comma-separated
+	AllowedIPs      string     `json:"allowed_ips"` // comma-separated IPs/CIDRs, empty = any
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// HasScope reports whether the key was granted scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range strings.Split(k.Scopes, ",") {
+		if strings.TrimSpace(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APINonce remembers recently used request nonces per key to block replays
+type APINonce struct {
+	ID        uint      `gorm:"primaryKey"`
+	APIKeyID  uint      `gorm:"not null;uniqueIndex:idx_api_nonce"`
+	Nonce     string    `gorm:"not null;size:64;uniqueIndex:idx_api_nonce"`
+	CreatedAt time.Time `gorm:"index"`
+}
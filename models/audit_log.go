@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AuditLog is an append-only, hash-chained record of a privileged or financial action.
+// Hash = sha256(PrevHash + entry fields); altering or deleting a row breaks the chain.
+type AuditLog struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    *uint     `gorm:"index" json:"actor_id"` // nil for system actions (e.g. settlement)
+	Action     string    `gorm:"not null;index" json:"action"`
+	TargetType string    `gorm:"index:idx_audit_target" json:"target_type"`
+	TargetID   string    `gorm:"index:idx_audit_target" json:"target_id"`
+	Before     string    `gorm:"type:text" json:"before,omitempty"` // JSON snapshot
+	After      string    `gorm:"type:text" json:"after,omitempty"`  // JSON snapshot
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	PrevHash   string    `gorm:"size:64" json:"prev_hash"`
+	Hash       string    `gorm:"size:64;uniqueIndex" json:"hash"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// KYC levels, in ascending order of verification
+const (
+	KYCNone  = "none"
+	KYCBasic = "basic"
+	KYCFull  = "full"
+)
+
+// KYC submission statuses
+const (
+	KYCPending  = "pending"
+	KYCApproved = "approved"
+	KYCRejected = "rejected"
+)
+
+// NoLimit marks a KYC limit that doesn't apply
+const NoLimit = -1
+
+// KYCLimits caps what an account may move at its verification level. Amounts
+// are in USD, other wallet currencies valued at the current price, and the
+// daily limits are rolling 24h totals across the user's real wallets. NoLimit disables a cap, 0 blocks the action.
+type KYCLimits struct {
+	DailyDeposit  float64 `json:"daily_deposit"`
+	DailyWithdraw float64 `json:"daily_withdraw"`
+	MaxStake      float64 `json:"max_stake"`
+}
+
+// KYCTierLimits lists the limits for each level; unverified users can deposit
+// and trade small amounts but not withdraw.
+var KYCTierLimits = map[string]KYCLimits{
+	KYCNone:  {DailyDeposit: 500, DailyWithdraw: 0, MaxStake: 50},
+	KYCBasic: {DailyDeposit: 10000, DailyWithdraw: 5000, MaxStake: 1000},
+	KYCFull:  {DailyDeposit: NoLimit, DailyWithdraw: NoLimit, MaxStake: 25000},
+}
+
+// KYCRank orders levels so a submission can only ask for a higher one
+var KYCRank = map[string]int{KYCNone: 0, KYCBasic: 1, KYCFull: 2}
+
+// KYCSubmission is one identity document sent in for review
+type KYCSubmission struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	Level          string     `gorm:"not null" json:"level"` // level requested
+	FullName       string     `gorm:"not null" json:"full_name"`
+	DateOfBirth    time.Time  `json:"date_of_birth"`
+	Country        string     `gorm:"size:2" json:"country"` // ISO 3166-1 alpha-2
+	DocumentType   string     `gorm:"not null" json:"document_type"`
+	DocumentPath   string     `json:"-"`
+	Status         string     `gorm:"default:'pending';index" json:"status"`
+	Provider       string     `json:"provider"`
+	ProviderRef    string     `json:"provider_ref,omitempty"`
+	ProviderResult string     `json:"provider_result,omitempty"` // clear / consider / error
+	ReviewerID     *uint      `json:"reviewer_id,omitempty"`
+	ReviewNote     string     `json:"review_note,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // Candle represents OHLCV candle data
 type Candle struct {
 	OpenTime  int64   `json:"open_time"`
@@ -10,3 +12,29 @@ type Candle struct {
 	Volume    float64 `json:"volume"`
 	CloseTime int64   `json:"close_time"`
 }
+
+// StoredCandle is a persisted OHLCV bar at one resolution (1s, 1m, 5m, 1h),
+// built from the trade stream or backfilled from Binance klines.
+type StoredCandle struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	Symbol     string    `gorm:"size:20;not null;uniqueIndex:idx_candle_bucket" json:"symbol"`
+	Resolution string    `gorm:"size:4;not null;uniqueIndex:idx_candle_bucket" json:"resolution"`
+	OpenTime   time.Time `gorm:"not null;uniqueIndex:idx_candle_bucket" json:"open_time"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     float64   `json:"volume"`
+	Trades     int       `json:"trades"`
+	Source     string    `gorm:"size:16" json:"source"` // ticks / binance
+}
+
+// Tick is a single trade from the exchange stream
+type Tick struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Symbol    string    `gorm:"size:20;not null;index:idx_tick_symbol_time" json:"symbol"`
+	TradeID   int64     `json:"trade_id"`
+	Price     float64   `gorm:"not null" json:"price"`
+	Quantity  float64   `json:"quantity"`
+	TradeTime time.Time `gorm:"not null;index:idx_tick_symbol_time" json:"trade_time"`
+}
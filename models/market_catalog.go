@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Market trading statuses
+const (
+	MarketTrading  = "trading"
+	MarketHalted   = "halted"   // visible, no new trades
+	MarketDelisted = "delisted" // hidden, not streamed after a restart
+)
+
+// DefaultPayout is the return on a winning trade when a market doesn't set one
+const DefaultPayout = 0.8
+
+// DefaultDurations are the expiries offered on a new market, in seconds
+var DefaultDurations = []int{30, 60, 300, 900, 3600}
+
+// Market is a tradable symbol and how it is presented and traded
+type Market struct {
+	ID               uint      `gorm:"primaryKey" json:"-"`
+	Symbol           string    `gorm:"size:20;uniqueIndex;not null" json:"symbol"` // e.g. BTCUSDT
+	BaseAsset        string    `gorm:"size:10;not null" json:"base_asset"`
+	QuoteAsset       string    `gorm:"size:10;not null" json:"quote_asset"`
+	DisplayName      string    `json:"display_name"`
+	IconURL          string    `json:"icon_url"`
+	PricePrecision   int       `gorm:"default:2" json:"price_precision"`
+	Status           string    `gorm:"default:'trading';index" json:"status"`
+	AllowedDurations []int     `gorm:"serializer:json" json:"allowed_durations"` // seconds
+	Payout           float64   `gorm:"default:0.8" json:"payout"`                // 0.8 = 80% return on a win
+	SortOrder        int       `gorm:"default:0" json:"sort_order"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// AllowsDuration reports whether seconds is one of the market's expiries
+func (m *Market) AllowsDuration(seconds int) bool {
+	for _, d := range m.AllowedDurations {
+		if d == seconds {
+			return true
+		}
+	}
+	return false
+}
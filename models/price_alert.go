@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Price alert conditions
+const (
+	AlertAbove = "above" // price rises to or through Price
+	AlertBelow = "below" // price falls to or through Price
+	AlertMove  = "move"  // price moves Percent or more, either way, within WindowSeconds
+)
+
+// PriceAlert notifies a user when a market's price meets a condition. One-shot
+// alerts deactivate after firing; recurring ones fire again each time the
+// condition is met afresh (a level crossed again, or a new move after the
+// previous one settled), at most once per minute.
+type PriceAlert struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"-"`
+	Symbol        string     `gorm:"not null;index" json:"symbol"`
+	Condition     string     `gorm:"not null" json:"condition"` // above / below / move
+	Price         float64    `json:"price,omitempty"`           // above / below level
+	Percent       float64    `json:"percent,omitempty"`         // move threshold
+	WindowSeconds int        `json:"window_seconds,omitempty"`  // move window
+	Recurring     bool       `gorm:"default:false" json:"recurring"`
+	Note          string     `gorm:"size:140" json:"note,omitempty"`
+	Active        bool       `gorm:"default:true;index" json:"active"`
+	TriggerCount  int        `gorm:"default:0" json:"trigger_count"`
+	TriggeredAt   *time.Time `json:"triggered_at,omitempty"`
+	TriggerPrice  float64    `json:"trigger_price,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// RateLimitBucket is the shared token-bucket state used by the Postgres rate limit store
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey;size:255"`
+	Tokens    float64   `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single-use 2FA backup code; only its SHA-256 is stored
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	CodeHash  string `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
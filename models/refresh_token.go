@@ -2,12 +2,20 @@ package models
 
 import "time"
 
+// RefreshToken is one link in a session's rotation chain. Every token minted by
+// rotating the same login shares a FamilyID; the family is what users see as a
+// "session" and what gets revoked wholesale on reuse.
 type RefreshToken struct {
-	ID        uint   `gorm:"primaryKey" json:"-"`
-	UserID    uint   `gorm:"index" json:"-"`
-	JTI       string `gorm:"uniqueIndex;size:64"`
-	ExpiresAt time.Time
-	Revoked   bool `gorm:"default:false"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID               uint   `gorm:"primaryKey" json:"-"`
+	UserID           uint   `gorm:"index" json:"-"`
+	JTI              string `gorm:"uniqueIndex;size:64"`
+	FamilyID         string `gorm:"index;size:64"`
+	ExpiresAt        time.Time
+	Revoked          bool `gorm:"default:false"`
+	UserAgent        string
+	IP               string
+	SessionStartedAt time.Time
+	LastUsedAt       time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
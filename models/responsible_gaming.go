@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Responsible-gaming limit kinds
+const (
+	LimitDeposit = "deposit"
+	LimitLoss    = "loss"
+)
+
+// LimitPeriods maps each limit period to its rolling window
+var LimitPeriods = map[string]time.Duration{
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+}
+
+// GamingLimit is a user-chosen cap, in USD, on deposits or net trading losses
+// over a rolling period, across all of the user's real wallets. Lowering a limit applies at once; raising or removing one is
+// parked in the Pending fields until the cooling-off delay has passed.
+type GamingLimit struct {
+	ID                 uint       `gorm:"primaryKey" json:"-"`
+	UserID             uint       `gorm:"not null;uniqueIndex:idx_gaming_limit" json:"-"`
+	Kind               string     `gorm:"not null;uniqueIndex:idx_gaming_limit" json:"kind"`   // deposit / loss
+	Period             string     `gorm:"not null;uniqueIndex:idx_gaming_limit" json:"period"` // daily / weekly / monthly
+	Amount             *float64   `json:"amount"`                                              // nil = no limit
+	PendingAmount      *float64   `json:"pending_amount,omitempty"`
+	PendingRemove      bool       `gorm:"default:false" json:"pending_remove,omitempty"`
+	PendingEffectiveAt *time.Time `json:"pending_effective_at,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
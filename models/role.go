@@ -0,0 +1,54 @@
+package models
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleRisk    = "risk"
+	RoleAdmin   = "admin"
+)
+
+// Permission is a single privileged capability checked by middleware.RequirePermission
+type Permission string
+
+const (
+	PermUsersRead      Permission = "users:read"
+	PermUsersFreeze    Permission = "users:freeze"
+	PermUsersManage    Permission = "users:manage" // create users, change roles
+	PermBalanceAdjust  Permission = "balances:adjust"
+	PermTradesVoid     Permission = "trades:void"
+	PermStatementsRead Permission = "statements:read"
+	PermAuditRead      Permission = "audit:read"
+	PermKYCReview      Permission = "kyc:review"
+	PermMarketsManage  Permission = "markets:manage"
+)
+
+// RolePermissions lists what each role may do; plain users have no admin permissions.
+var RolePermissions = map[string][]Permission{
+	RoleUser:    {},
+	RoleSupport: {PermUsersRead, PermStatementsRead, PermKYCReview},
+	RoleRisk: {
+		PermUsersRead, PermUsersFreeze, PermTradesVoid, PermStatementsRead, PermAuditRead, PermKYCReview,
+		PermMarketsManage,
+	},
+	RoleAdmin: {
+		PermUsersRead, PermUsersFreeze, PermUsersManage,
+		PermBalanceAdjust, PermTradesVoid, PermStatementsRead, PermAuditRead, PermKYCReview,
+		PermMarketsManage,
+	},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether role grants perm
+func HasPermission(role string, perm Permission) bool {
+	for _, p := range RolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// StatementExport tracks an asynchronously generated account statement
+type StatementExport struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	From      time.Time `gorm:"not null" json:"from"`
+	To        time.Time `gorm:"not null" json:"to"`
+	Format    string    `gorm:"not null" json:"format"`          // csv / pdf
+	Status    string    `gorm:"default:'PENDING'" json:"status"` // PENDING / READY / FAILED
+	FilePath  string    `json:"-"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -4,15 +4,17 @@ import "time"
 
 type Trade struct {
 	ID         uint    `gorm:"primaryKey"`
-	UserID     uint    `gorm:"not null"`
+	UserID     uint    `gorm:"not null;index"`
 	WalletID   uint    `gorm:"not null"`
 	Asset      string  `gorm:"not null"` // e.g. BTCUSDT
 	Amount     float64 `gorm:"not null"`
 	Direction  string  `gorm:"not null"` // "UP" or "DOWN"
 	EntryPrice float64 `gorm:"not null"`
 	ExitPrice  float64
-	Duration   int    `gorm:"not null"`       // in seconds
-	Status     string `gorm:"default:'OPEN'"` // OPEN / WON / LOST
+	Payout     float64 // return rate on a win, from the market at placement
+	Duration   int     `gorm:"not null"`            // in seconds
+	Status     string  `gorm:"default:'OPEN'"`      // OPEN / WON / LOST
+	IsDemo     bool    `gorm:"default:false;index"` // placed from a demo wallet
 	CreatedAt  time.Time
 	ExpiredAt  time.Time
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Deposit is money paid into a wallet from outside the platform
+type Deposit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	WalletID  uint      `gorm:"not null;index" json:"wallet_id"`
+	Amount    float64   `gorm:"not null" json:"amount"`
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Withdrawal is money paid out of a wallet
+type Withdrawal struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	WalletID  uint      `gorm:"not null;index" json:"wallet_id"`
+	Amount    float64   `gorm:"not null" json:"amount"`
+	Reference string    `json:"reference"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -3,10 +3,46 @@ package models
 import "time"
 
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"unique;not null" json:"username"`
-	Email     string    `gorm:"unique;not null" json:"email"`
-	Password  string    `json:"password"` // hashed later
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Username      string     `gorm:"unique;not null" json:"username"`
+	Email         string     `gorm:"unique;not null" json:"email"`
+	EmailVerified bool       `gorm:"default:false" json:"email_verified"`
+	Password      string     `json:"-"` // bcrypt hash, never serialized
+	Role          string     `gorm:"default:'user';not null" json:"role"`
+	Frozen        bool       `gorm:"default:false" json:"frozen"`
+	FrozenReason  string     `json:"frozen_reason,omitempty"`
+	FrozenAt      *time.Time `json:"frozen_at,omitempty"`
+	TOTPSecret    string     `json:"-"` // base32, sealed with utils.EncryptSecret; set on setup, active once TOTPEnabled
+	TOTPEnabled   bool       `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastStep  int64      `json:"-"` // last accepted time step, blocks code replay
+	FailedLogins  int        `gorm:"default:0" json:"-"`
+	LockedUntil   *time.Time `json:"-"` // progressive lockout after repeated failed logins
+	KYCLevel      string     `gorm:"default:'none';not null" json:"kyc_level"`
+	// Responsible gaming
+	SelfExcludedUntil      *time.Time `json:"self_excluded_until,omitempty"`
+	SessionReminderMinutes int        `gorm:"default:0" json:"session_reminder_minutes"` // 0 = off
+	// Profile and preferences
+	DisplayName          string            `gorm:"size:64" json:"display_name"`
+	PendingEmail         string            `json:"pending_email,omitempty"` // awaiting confirmation from the new address
+	Country              string            `gorm:"size:2" json:"country"`   // ISO 3166-1 alpha-2
+	Timezone             string            `gorm:"default:'UTC'" json:"timezone"`
+	QuoteCurrency        string            `gorm:"default:'USD'" json:"quote_currency"`
+	DefaultTradeAmount   float64           `gorm:"default:10" json:"default_trade_amount"`
+	DefaultTradeDuration int               `gorm:"default:60" json:"default_trade_duration"` // seconds
+	AvatarPath           string            `json:"-"`
+	Notifications        NotificationPrefs `gorm:"embedded;embeddedPrefix:notify_" json:"notifications"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
 }
+
+// NotificationPrefs controls which emails a user receives. Security notices
+// such as password changes are always sent.
+type NotificationPrefs struct {
+	TradeResults bool `gorm:"default:true" json:"trade_results"`
+	Deposits     bool `gorm:"default:true" json:"deposits"`
+	PriceAlerts  bool `gorm:"default:true" json:"price_alerts"`
+	Marketing    bool `gorm:"default:false" json:"marketing"`
+}
+
+// QuoteCurrencies are the currencies prices and balances can be displayed in
+var QuoteCurrencies = map[string]bool{"USD": true, "USDT": true, "EUR": true, "BTC": true, "ETH": true}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// UserIdentity links a User to an external OpenID Connect account
+type UserIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"-"`
+	Provider  string    `gorm:"not null;size:32;uniqueIndex:idx_identity_subject" json:"provider"`
+	Subject   string    `gorm:"not null;size:255;uniqueIndex:idx_identity_subject" json:"-"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCState holds the per-login state, nonce and PKCE verifier between the
+// redirect to the provider and the callback
+type OIDCState struct {
+	State        string `gorm:"primaryKey;size:64"`
+	Provider     string `gorm:"not null;size:32"`
+	Nonce        string `gorm:"not null;size:64"`
+	CodeVerifier string `gorm:"not null;size:128"`
+	RedirectURL  string
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposeEmailVerify   = "email_verify"
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeEmailChange   = "email_change"
+)
+
+// UserToken is a single-use, expiring token sent by email. Only the SHA-256 of
+// the token is stored, so a database leak can't be replayed.
+type UserToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"not null;size:32"`
+	TokenHash string    `gorm:"uniqueIndex;size:64;not null"`
+	Email     string    // address the token was sent to
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
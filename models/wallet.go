@@ -9,7 +9,8 @@ type Wallet struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	UserID    uint      `gorm:"not null;index" json:"user_id"` // ✅ indexed, not unique
 	Currency  string    `gorm:"not null" json:"currency"`
-	Balance   float64   `gorm:"default:0" json:"balance"` // Main trading balance
+	Balance   float64   `gorm:"default:0" json:"balance"`           // Main trading balance
+	IsDemo    bool      `gorm:"default:false;index" json:"is_demo"` // virtual practice balance, never real funds
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	User      User      `gorm:"foreignKey:UserID"`
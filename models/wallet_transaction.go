@@ -6,11 +6,17 @@ import (
 
 // Wallet represents a user's wallet
 type WalletTransaction struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	WalletID  uint      `gorm:"not null" json:"wallet_id"`
-	Amount    float64   `json:"amount"`
-	Type      string    `json:"type"` // deposit, withdraw, trade, bonus, etc.
-	Reference string    `json:"reference"`
-	CreatedAt time.Time `json:"created_at"`
-	Wallet    Wallet    `gorm:"foreignKey:WalletID"`
+	ID           uint        `gorm:"primaryKey" json:"id"`
+	WalletID     uint        `gorm:"not null;index" json:"wallet_id"`
+	Amount       float64     `json:"amount"`
+	Type         string      `gorm:"index" json:"type"` // deposit, withdraw, trade, bonus, etc.
+	Reference    string      `json:"reference"`
+	TradeID      *uint       `gorm:"index" json:"trade_id,omitempty"`      // set for trade / trade_win / trade_void rows
+	DepositID    *uint       `gorm:"index" json:"deposit_id,omitempty"`    // set for deposit rows
+	WithdrawalID *uint       `gorm:"index" json:"withdrawal_id,omitempty"` // set for withdraw rows
+	CreatedAt    time.Time   `gorm:"index" json:"created_at"`
+	Wallet       Wallet      `gorm:"foreignKey:WalletID"`
+	Trade        *Trade      `gorm:"foreignKey:TradeID" json:"trade,omitempty"`
+	Deposit      *Deposit    `gorm:"foreignKey:DepositID" json:"deposit,omitempty"`
+	Withdrawal   *Withdrawal `gorm:"foreignKey:WithdrawalID" json:"withdrawal,omitempty"`
 }
@@ -1,44 +1,145 @@
 package routes
 
 import (
+	"os"
+
 	"github.com/gin-gonic/gin"
 	"github.com/solchef/crypto-options-backend/controllers"
 	"github.com/solchef/crypto-options-backend/middleware"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/utils"
 )
 
 func RegisterRoutes(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
+
 	api := r.Group("/api")
 
 	//markets
 	api.GET("/market/history", controllers.GetPriceHistory)
+	api.GET("/market/tickers", controllers.GetTickers)
+	api.GET("/market/index", controllers.GetPriceIndex)
+	api.GET("/market/status", controllers.GetFeedStatus)
+	api.GET("/market/depth", controllers.GetOrderBook)
+	api.GET("/market/indicators", controllers.GetIndicators)
+	api.GET("/markets", controllers.GetMarkets)
+	api.GET("/markets/:symbol", controllers.GetMarket)
 	// Public routes
 	auth := api.Group("/auth")
 	{
-		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
-		auth.POST("/refresh", controllers.Refresh)
+		auth.POST("/register", middleware.RateLimit("register", middleware.PerMinute(5, 5), middleware.ByIP), controllers.Register)
+		auth.POST("/login", middleware.RateLimit("login", middleware.PerMinute(10, 10), middleware.ByIP), controllers.Login)
+		auth.POST("/login/2fa", middleware.RateLimit("login_2fa", middleware.PerMinute(10, 10), middleware.ByIP), controllers.LoginVerify2FA)
+		auth.POST("/refresh", middleware.RateLimit("refresh", middleware.PerMinute(30, 10), middleware.ByIP), controllers.Refresh)
 		auth.POST("/logout", controllers.Logout)
+		auth.POST("/verify-email", middleware.RateLimit("verify_email", middleware.PerMinute(10, 10), middleware.ByIP), controllers.VerifyEmail)
+		auth.POST("/email/confirm", middleware.RateLimit("verify_email", middleware.PerMinute(10, 10), middleware.ByIP), controllers.ConfirmEmailChange)
+		auth.POST("/password/forgot", middleware.RateLimit("password_forgot", middleware.PerMinute(3, 3), middleware.ByIP), controllers.ForgotPassword)
+		auth.POST("/password/reset", middleware.RateLimit("password_reset", middleware.PerMinute(10, 10), middleware.ByIP), controllers.ResetPassword)
+
+		// Social login
+		auth.GET("/oidc/providers", controllers.GetOIDCProviders)
+		auth.GET("/oidc/:provider/login", middleware.RateLimit("oidc_login", middleware.PerMinute(20, 10), middleware.ByIP), controllers.OIDCLogin)
+		auth.GET("/oidc/:provider/callback", middleware.RateLimit("oidc_callback", middleware.PerMinute(20, 10), middleware.ByIP), controllers.OIDCCallback)
+		auth.POST("/oidc/:provider/callback", middleware.RateLimit("oidc_callback", middleware.PerMinute(20, 10), middleware.ByIP), controllers.OIDCCallback)
+	}
+
+	api.GET("/users/:id/avatar", controllers.GetAvatar)
+
+	// Local mock OpenID provider for development
+	if utils.IsDevMode() && os.Getenv("OIDC_MOCK") == "true" {
+		controllers.RegisterMockOIDC(r)
 	}
 
 	// Protected routes
 	protected := api.Group("/")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(), middleware.AccountStatus())
 	{
-		protected.GET("/users", controllers.GetUsers)
-		protected.POST("/users", controllers.CreateUser)
 		protected.GET("/profile", controllers.Profile)
+		protected.PATCH("/profile", controllers.UpdateProfile)
+		protected.POST("/profile/email", middleware.RateLimit("email_change", middleware.PerMinute(3, 3), middleware.ByUser), controllers.ChangeEmail)
+		protected.POST("/profile/avatar", middleware.RateLimit("avatar", middleware.PerMinute(10, 5), middleware.ByUser), controllers.UploadAvatar)
+		protected.DELETE("/profile/avatar", controllers.DeleteAvatar)
+
+		// Account security
+		protected.POST("/account/verify-email/resend", controllers.ResendVerification)
+		protected.POST("/account/password", controllers.ChangePassword)
+
+		// Sessions
+		protected.GET("/sessions", controllers.GetSessions)
+		protected.DELETE("/sessions/:id", controllers.RevokeSession)
+		protected.POST("/sessions/revoke-all", controllers.RevokeAllSessions)
+
+		// API keys
+		protected.POST("/apikeys", controllers.CreateAPIKey)
+		protected.GET("/apikeys", controllers.GetAPIKeys)
+		protected.DELETE("/apikeys/:id", controllers.RevokeAPIKey)
+
+		// Two-factor auth
+		protected.POST("/2fa/setup", controllers.Setup2FA)
+		protected.POST("/2fa/enable", controllers.Enable2FA)
+		protected.POST("/2fa/disable", controllers.Disable2FA)
+		protected.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+
+		// Identity verification
+		protected.GET("/kyc", controllers.GetKYCStatus)
+		protected.POST("/kyc/submissions", middleware.RateLimit("kyc_submit", middleware.PerMinute(5, 5), middleware.ByUser), controllers.SubmitKYC)
+
+		// Responsible gaming
+		protected.GET("/responsible-gaming", controllers.GetResponsibleGaming)
+		protected.PUT("/responsible-gaming/limits", controllers.SetGamingLimit)
+		protected.PUT("/responsible-gaming/session-reminder", controllers.SetSessionReminder)
+		protected.POST("/responsible-gaming/self-exclusion", controllers.SelfExclude)
+
+		// Price alerts
+		protected.GET("/alerts", controllers.GetAlerts)
+		protected.POST("/alerts", controllers.CreateAlert)
+		protected.GET("/alerts/:id", controllers.GetAlert)
+		protected.PATCH("/alerts/:id", controllers.UpdateAlert)
+		protected.DELETE("/alerts/:id", controllers.DeleteAlert)
 
 		// Trades
-		protected.POST("/trades/place", controllers.PlaceTrade)
+		protected.POST("/trades/place", middleware.RateLimit("trade_place", middleware.PerMinute(60, 10), middleware.ByUser), controllers.PlaceTrade)
 		protected.GET("/trades/open", controllers.GetOpenTrades)
 		protected.GET("/trades/history", controllers.GetTradeHistory)
+		protected.GET("/trades/:id", controllers.GetTrade)
 		protected.POST("/trades/close", controllers.CloseTrade)
 
 		//wallets
 		protected.GET("/wallets", controllers.GetWallets)
 		protected.POST("/wallets/deposit", controllers.Deposit)
-		protected.POST("/wallets/withdraw", controllers.Withdraw)
+		protected.POST("/wallets/withdraw", middleware.RateLimit("withdraw", middleware.PerMinute(5, 5), middleware.ByUser), controllers.Withdraw)
 		protected.GET("/wallets/transactions", controllers.GetWalletTransactions)
+		protected.POST("/wallets/demo/reset", controllers.ResetDemoWallet)
 
+		// Statements
+		protected.GET("/statements", controllers.DownloadStatement)
+		protected.POST("/statements/exports", controllers.CreateStatementExport)
+		protected.GET("/statements/exports", controllers.GetStatementExports)
+		protected.GET("/statements/exports/:id", controllers.GetStatementExport)
+		protected.GET("/statements/exports/:id/download", controllers.DownloadStatementExport)
+
+	}
+
+	// Admin routes, gated per permission
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(), middleware.AccountStatus())
+	{
+		admin.GET("/users", middleware.RequirePermission(models.PermUsersRead), controllers.GetUsers)
+		admin.POST("/users", middleware.RequirePermission(models.PermUsersManage), controllers.CreateUser)
+		admin.GET("/users/:id", middleware.RequirePermission(models.PermUsersRead), controllers.AdminGetUser)
+		admin.GET("/users/:id/statement", middleware.RequirePermission(models.PermStatementsRead), controllers.AdminUserStatement)
+		admin.POST("/users/:id/freeze", middleware.RequirePermission(models.PermUsersFreeze), controllers.AdminFreezeUser)
+		admin.POST("/users/:id/role", middleware.RequirePermission(models.PermUsersManage), controllers.AdminSetRole)
+		admin.POST("/wallets/:id/adjust", middleware.RequirePermission(models.PermBalanceAdjust), controllers.AdminAdjustBalance)
+		admin.POST("/trades/:id/void", middleware.RequirePermission(models.PermTradesVoid), controllers.AdminVoidTrade)
+		admin.GET("/kyc", middleware.RequirePermission(models.PermKYCReview), controllers.AdminGetKYCSubmissions)
+		admin.GET("/kyc/:id/document", middleware.RequirePermission(models.PermKYCReview), controllers.AdminGetKYCDocument)
+		admin.POST("/kyc/:id/review", middleware.RequirePermission(models.PermKYCReview), controllers.AdminReviewKYC)
+		admin.GET("/markets", middleware.RequirePermission(models.PermMarketsManage), controllers.AdminGetMarkets)
+		admin.POST("/markets", middleware.RequirePermission(models.PermMarketsManage), controllers.AdminCreateMarket)
+		admin.PATCH("/markets/:symbol", middleware.RequirePermission(models.PermMarketsManage), controllers.AdminUpdateMarket)
+		admin.GET("/audit", middleware.RequirePermission(models.PermAuditRead), controllers.AdminGetAuditLog)
+		admin.GET("/audit/verify", middleware.RequirePermission(models.PermAuditRead), controllers.AdminVerifyAuditLog)
 	}
 }
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNegativeBalance = errors.New("adjustment would make balance negative")
+	ErrTradeVoided     = errors.New("trade already voided")
+)
+
+// BootstrapAdmin promotes the given username to admin so a fresh install has
+// someone who can reach /api/admin. No-op when username is empty.
+func BootstrapAdmin(username string) {
+	if username == "" {
+		return
+	}
+	res := config.DB.Model(&models.User{}).Where("username = ?", username).Update("role", models.RoleAdmin)
+	if res.Error == nil && res.RowsAffected > 0 {
+		fmt.Printf("Promoted %s to admin\n", username)
+	}
+}
+
+// AdjustBalance credits (positive) or debits (negative) a wallet outside the
+// normal deposit/withdraw flow, recording the reason on the transaction.
+func AdjustBalance(walletID uint, amount float64, reason string, actorID uint) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&wallet, walletID).Error; err != nil {
+			return err
+		}
+		if wallet.Balance+amount < 0 {
+			return ErrNegativeBalance
+		}
+		wallet.Balance += amount
+		if err := tx.Save(&wallet).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WalletTransaction{
+			WalletID:  wallet.ID,
+			Amount:    amount,
+			Type:      "adjustment",
+			Reference: fmt.Sprintf("admin #%d: %s", actorID, reason),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func voidReference(tradeID, actorID uint, reason string) string {
+	if actorID == 0 {
+		return fmt.Sprintf("Trade #%d voided: %s", tradeID, reason)
+	}
+	return fmt.Sprintf("Trade #%d voided by admin #%d: %s", tradeID, actorID, reason)
+}
+
+// VoidTrade cancels a trade: the stake is refunded and any payout already
+// credited is reversed. Open trades are skipped by SettleTrade once voided.
+// actorID 0 means the system voided it.
+func VoidTrade(tradeID uint, reason string, actorID uint) (*models.Trade, error) {
+	var trade models.Trade
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the trade so a settlement or another void can't credit or
+		// refund it between reading its transactions and marking it VOID
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&trade, tradeID).Error; err != nil {
+			return err
+		}
+		if trade.Status == "VOID" {
+			return ErrTradeVoided
+		}
+
+		// Net effect of this trade on the wallet so far (stake debit + any payout)
+		var net float64
+		if err := tx.Model(&models.WalletTransaction{}).
+			Where("trade_id = ?", trade.ID).
+			Select("COALESCE(SUM(amount), 0)").Scan(&net).Error; err != nil {
+			return err
+		}
+
+		if net != 0 {
+			if err := tx.Model(&models.Wallet{}).
+				Where("id = ?", trade.WalletID).
+				Update("balance", gorm.Expr("balance + ?", -net)).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.WalletTransaction{
+				WalletID:  trade.WalletID,
+				Amount:    -net,
+				Type:      "trade_void",
+				Reference: voidReference(trade.ID, actorID, reason),
+				TradeID:   &trade.ID,
+				CreatedAt: time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		trade.Status = "VOID"
+		return tx.Model(&trade).Update("status", "VOID").Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msg := fmt.Sprintf(`{"trade_id": %d, "status": "VOID"}`, trade.ID)
+	config.WSHub.SendToUser(trade.UserID, msg)
+	return &trade, nil
+}
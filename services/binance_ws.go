@@ -73,23 +73,20 @@
 package services
 
 import (
-	"encoding/json"
-	"log"
-	"strconv"
 	"sync"
-
-	"github.com/gorilla/websocket"
+	"time"
 )
 
 type PriceUpdate struct {
-	Symbol        string  `json:"symbol"`
-	Price         float64 `json:"price"`
-	Change        float64 `json:"change,omitempty"`
-	ChangePercent float64 `json:"change_percent,omitempty"`
-	High          float64 `json:"high,omitempty"`
-	Low           float64 `json:"low,omitempty"`
-	Volume        float64 `json:"volume,omitempty"`
-	LastPrice     float64 `json:"last_price,omitempty"`
+	Symbol        string    `json:"symbol"`
+	Price         float64   `json:"price"`
+	Change        float64   `json:"change,omitempty"`
+	ChangePercent float64   `json:"change_percent,omitempty"`
+	High          float64   `json:"high,omitempty"`
+	Low           float64   `json:"low,omitempty"`
+	Volume        float64   `json:"volume,omitempty"`
+	LastPrice     float64   `json:"last_price,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 var priceMap = struct {
@@ -97,67 +94,45 @@ var priceMap = struct {
 	data map[string]PriceUpdate
 }{data: make(map[string]PriceUpdate)}
 
-// ListenPriceStream subscribes to Binance trade price stream
-func ListenPriceStream(symbol string, ch chan<- float64) {
-	url := "wss://stream.binance.com:9443/ws/" + symbol + "@trade"
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		log.Println("trade dial:", err)
-		return
-	}
-	defer c.Close()
-
-	for {
-		_, msg, err := c.ReadMessage()
-		if err != nil {
-			log.Println("trade read:", err)
-			return
-		}
-		var data struct {
-			Price string `json:"p"`
-		}
-		if err := json.Unmarshal(msg, &data); err == nil {
-			if price, err := strconv.ParseFloat(data.Price, 64); err == nil {
-				ch <- price
-			}
-		}
-	}
+// GetCurrentPrice returns latest cached update
+func GetCurrentPrice(symbol string) PriceUpdate {
+	priceMap.RLock()
+	defer priceMap.RUnlock()
+	return priceMap.data[symbol]
 }
 
-// ListenTickerStream subscribes to Binance 24hr ticker stats
-func ListenTickerStream(symbol string, ch chan<- map[string]float64) {
-	url := "wss://stream.binance.com:9443/ws/" + symbol + "@ticker"
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		log.Println("stats dial:", err)
-		return
-	}
-	defer c.Close()
+// setLastPrice caches the latest trade price for symbol
+func setLastPrice(symbol string, price float64, at time.Time) {
+	priceMap.Lock()
+	defer priceMap.Unlock()
+	u := priceMap.data[symbol]
+	u.Symbol = symbol
+	u.LastPrice = u.Price
+	u.Price = price
+	u.UpdatedAt = at
+	priceMap.data[symbol] = u
+}
 
-	for {
-		_, msg, err := c.ReadMessage()
-		if err != nil {
-			log.Println("stats read:", err)
-			return
-		}
-		var data struct {
-			PriceChange     string `json:"p"`
-			PriceChangePerc string `json:"P"`
-		}
-		if err := json.Unmarshal(msg, &data); err == nil {
-			change, _ := strconv.ParseFloat(data.PriceChange, 64)
-			changePct, _ := strconv.ParseFloat(data.PriceChangePerc, 64)
-			ch <- map[string]float64{
-				"change":    change,
-				"changePct": changePct,
-			}
-		}
-	}
+// setTickerStats caches the rolling 24h stats for symbol
+func setTickerStats(symbol string, change, changePct, high, low, volume float64) {
+	priceMap.Lock()
+	defer priceMap.Unlock()
+	u := priceMap.data[symbol]
+	u.Symbol = symbol
+	u.Change, u.ChangePercent = change, changePct
+	u.High, u.Low, u.Volume = high, low, volume
+	priceMap.data[symbol] = u
 }
 
-// GetCurrentPrice returns latest cached update
-func GetCurrentPrice(symbol string) PriceUpdate {
+// Tickers returns the cached update for each of symbols that has one
+func Tickers(symbols []string) []PriceUpdate {
 	priceMap.RLock()
 	defer priceMap.RUnlock()
-	return priceMap.data[symbol]
+	out := make([]PriceUpdate, 0, len(symbols))
+	for _, s := range symbols {
+		if u, ok := priceMap.data[s]; ok {
+			out = append(out, u)
+		}
+	}
+	return out
 }
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm/clause"
+)
+
+// CandleResolutions are the intervals built from ticks and kept locally
+var CandleResolutions = map[string]time.Duration{
+	"1s": time.Second,
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+}
+
+// How long each resolution is kept. Finer bars expire first, so older history
+// survives at coarser resolutions only; 0 keeps forever.
+var candleRetention = map[string]time.Duration{
+	"1s": 24 * time.Hour,
+	"1m": 30 * 24 * time.Hour,
+	"5m": 365 * 24 * time.Hour,
+	"1h": 0,
+}
+
+// How far back a gap is backfilled from Binance on (re)connect
+var candleBackfill = map[string]time.Duration{
+	"1s": time.Hour,
+	"1m": 7 * 24 * time.Hour,
+	"5m": 30 * 24 * time.Hour,
+	"1h": 365 * 24 * time.Hour,
+}
+
+// Cap on buffered ticks if the database falls behind
+const maxPendingTicks = 100000
+
+// liveCandle is a bar being built from ticks. Partial bars started mid-bucket
+// (at startup or after a reconnect) and are replaced from Binance once closed.
+type liveCandle struct {
+	models.StoredCandle
+	partial bool
+}
+
+type candleKey struct {
+	symbol, resolution string
+}
+
+var candleAgg = struct {
+	sync.Mutex
+	live   map[candleKey]*liveCandle
+	closed []liveCandle
+	ticks  []models.Tick
+}{live: map[candleKey]*liveCandle{}}
+
+// ClosedCandle is a finished bar at one of the CandleResolutions
+type ClosedCandle struct {
+	Symbol     string
+	Resolution string
+	models.Candle
+}
+
+var candleCloseHandlers struct {
+	sync.RWMutex
+	h []func(ClosedCandle)
+}
+
+// OnCandleClose registers a handler called once a bar closes and is stored.
+// Repaired partial bars are published when the repair lands, so they may
+// arrive after later bars. Handlers run on the store goroutine and must not block.
+func OnCandleClose(h func(ClosedCandle)) {
+	candleCloseHandlers.Lock()
+	candleCloseHandlers.h = append(candleCloseHandlers.h, h)
+	candleCloseHandlers.Unlock()
+}
+
+func publishCandleClose(candles []models.StoredCandle) {
+	candleCloseHandlers.RLock()
+	defer candleCloseHandlers.RUnlock()
+	for _, c := range candles {
+		step := CandleResolutions[c.Resolution]
+		closed := ClosedCandle{Symbol: c.Symbol, Resolution: c.Resolution, Candle: models.Candle{
+			OpenTime:  c.OpenTime.UnixMilli(),
+			Open:      c.Open,
+			High:      c.High,
+			Low:       c.Low,
+			Close:     c.Close,
+			Volume:    c.Volume,
+			CloseTime: c.OpenTime.Add(step).UnixMilli() - 1,
+		}}
+		for _, h := range candleCloseHandlers.h {
+			h(closed)
+		}
+	}
+}
+
+// TickRetention is how long raw ticks are kept. Override with TICK_RETENTION_HOURS.
+func TickRetention() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("TICK_RETENTION_HOURS")); err == nil && v > 0 {
+		return time.Duration(v) * time.Hour
+	}
+	return 48 * time.Hour
+}
+
+// StartCandleStore records ticks and builds candles from the market stream.
+// Call before StartMarketStreams so no ticks are missed.
+func StartCandleStore() {
+	OnMarketTick(aggregateTick)
+	OnMarketConnect(func(symbol string) {
+		resetLiveCandles(symbol)
+		go BackfillCandles(symbol)
+	})
+
+	go func() {
+		for range time.Tick(time.Second) {
+			flushCandleStore()
+		}
+	}()
+	go func() {
+		for range time.Tick(time.Hour) {
+			pruneCandleStore()
+		}
+	}()
+}
+
+func aggregateTick(t MarketTick) {
+	candleAgg.Lock()
+	defer candleAgg.Unlock()
+
+	if len(candleAgg.ticks) < maxPendingTicks {
+		candleAgg.ticks = append(candleAgg.ticks, models.Tick{
+			Symbol:    t.Symbol,
+			TradeID:   t.TradeID,
+			Price:     t.Price,
+			Quantity:  t.Quantity,
+			TradeTime: t.Time,
+		})
+	}
+
+	for res, step := range CandleResolutions {
+		key := candleKey{t.Symbol, res}
+		bucket := t.Time.Truncate(step)
+		c := candleAgg.live[key]
+
+		if c != nil && bucket.Before(c.OpenTime) {
+			continue // late tick for a bar already closed
+		}
+		if c != nil && bucket.After(c.OpenTime) {
+			candleAgg.closed = append(candleAgg.closed, *c)
+			c = nil
+		}
+		if c == nil {
+			// The first bar after startup or a reconnect missed earlier ticks
+			_, seen := candleAgg.live[key]
+			c = &liveCandle{
+				StoredCandle: models.StoredCandle{
+					Symbol: t.Symbol, Resolution: res, OpenTime: bucket,
+					Open: t.Price, High: t.Price, Low: t.Price, Source: "ticks",
+				},
+				partial: !seen,
+			}
+			candleAgg.live[key] = c
+		}
+
+		if t.Price > c.High {
+			c.High = t.Price
+		}
+		if t.Price < c.Low {
+			c.Low = t.Price
+		}
+		c.Close = t.Price
+		c.Volume += t.Quantity
+		c.Trades++
+	}
+}
+
+// resetLiveCandles closes symbol's bars in progress as partial after a stream
+// reconnect; ticks were missed, so they and the next bars get repaired.
+func resetLiveCandles(symbol string) {
+	candleAgg.Lock()
+	defer candleAgg.Unlock()
+	for key, c := range candleAgg.live {
+		if key.symbol == symbol {
+			c.partial = true
+			candleAgg.closed = append(candleAgg.closed, *c)
+			delete(candleAgg.live, key)
+		}
+	}
+}
+
+func flushCandleStore() {
+	candleAgg.Lock()
+	ticks, closed := candleAgg.ticks, candleAgg.closed
+	candleAgg.ticks, candleAgg.closed = nil, nil
+	candleAgg.Unlock()
+
+	if len(ticks) > 0 {
+		if err := config.DB.CreateInBatches(ticks, 500).Error; err != nil {
+			log.Println("store ticks:", err)
+		}
+	}
+	for _, c := range closed {
+		if c.partial {
+			go repairCandle(c)
+			continue
+		}
+		upsertCandles([]models.StoredCandle{c.StoredCandle})
+		publishCandleClose([]models.StoredCandle{c.StoredCandle})
+	}
+}
+
+// repairCandle replaces a partial bar with Binance's, keeping ours if that fails
+func repairCandle(c liveCandle) {
+	step := CandleResolutions[c.Resolution]
+	klines, err := fetchKlines(context.Background(), c.Symbol, c.Resolution, c.OpenTime, c.OpenTime.Add(step-time.Millisecond), 1)
+	repaired := storedFromKlines(c.Symbol, c.Resolution, klines)
+	if err != nil || len(klines) == 0 {
+		repaired = []models.StoredCandle{c.StoredCandle}
+	}
+	upsertCandles(repaired)
+	publishCandleClose(repaired)
+}
+
+func upsertCandles(candles []models.StoredCandle) {
+	if len(candles) == 0 {
+		return
+	}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "symbol"}, {Name: "resolution"}, {Name: "open_time"}},
+		DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "volume", "trades", "source"}),
+	}).CreateInBatches(candles, 500).Error
+	if err != nil {
+		log.Println("store candles:", err)
+	}
+}
+
+func storedFromKlines(symbol, resolution string, klines []models.Candle) []models.StoredCandle {
+	out := make([]models.StoredCandle, len(klines))
+	for i, k := range klines {
+		out[i] = models.StoredCandle{
+			Symbol: symbol, Resolution: resolution, OpenTime: time.UnixMilli(k.OpenTime).UTC(),
+			Open: k.Open, High: k.High, Low: k.Low, Close: k.Close, Volume: k.Volume, Source: "binance",
+		}
+	}
+	return out
+}
+
+// BackfillCandles fills closed buckets missing since the last stored bar (up
+// to each resolution's backfill horizon) from Binance klines.
+func BackfillCandles(symbol string) {
+	now := time.Now()
+	for res, step := range CandleResolutions {
+		from := now.Add(-candleBackfill[res]).Truncate(step)
+		var last models.StoredCandle
+		if err := config.DB.Where("symbol = ? AND resolution = ?", symbol, res).
+			Order("open_time DESC").First(&last).Error; err == nil && !last.OpenTime.Before(from) {
+			from = last.OpenTime.Add(step)
+		}
+		// Only closed buckets; the current one is being built from ticks
+		to := now.Truncate(step).Add(-time.Millisecond)
+		if !from.Before(to) {
+			continue
+		}
+
+		n := int(to.Sub(from)/step) + 1
+		klines, err := fetchKlines(context.Background(), symbol, res, from, to, n)
+		if err != nil {
+			log.Printf("backfill %s %s: %v", symbol, res, err)
+			continue
+		}
+		upsertCandles(storedFromKlines(symbol, res, klines))
+	}
+}
+
+func pruneCandleStore() {
+	now := time.Now()
+	config.DB.Where("trade_time < ?", now.Add(-TickRetention())).Delete(&models.Tick{})
+	for res, keep := range candleRetention {
+		if keep > 0 {
+			config.DB.Where("resolution = ? AND open_time < ?", res, now.Add(-keep)).Delete(&models.StoredCandle{})
+		}
+	}
+}
+
+// storedHistory serves a history request from the local store. It only
+// answers (ok) when every bucket in the range is present, so callers can fall
+// back to Binance for anything else.
+func storedHistory(symbol, interval string, start, end time.Time, limit int) ([]models.Candle, bool) {
+	step, ok := CandleResolutions[interval]
+	if !ok || config.DB == nil {
+		return nil, false
+	}
+
+	// Buckets whose open time is in [start, end], as Binance would return them
+	first := start.Truncate(step)
+	if first.Before(start) {
+		first = first.Add(step)
+	}
+	current := time.Now().Truncate(step)
+	lastWanted := end.Truncate(step)
+	if lastWanted.After(current) {
+		lastWanted = current
+	}
+	if lastWanted.Before(first) {
+		return nil, false
+	}
+	want := int(lastWanted.Sub(first)/step) + 1
+	if want > limit {
+		want = limit
+		lastWanted = first.Add(time.Duration(want-1) * step)
+	}
+
+	// The bar in progress comes from memory, closed ones from the database
+	lastClosed := lastWanted
+	var live *liveCandle
+	if !lastWanted.Before(current) {
+		candleAgg.Lock()
+		if c := candleAgg.live[candleKey{symbol, interval}]; c != nil && c.OpenTime.Equal(current) && !c.partial {
+			cp := *c
+			live = &cp
+		}
+		candleAgg.Unlock()
+		if live == nil {
+			return nil, false
+		}
+		lastClosed = current.Add(-step)
+	}
+
+	var rows []models.StoredCandle
+	if !lastClosed.Before(first) {
+		if err := config.DB.Where("symbol = ? AND resolution = ? AND open_time BETWEEN ? AND ?", symbol, interval, first, lastClosed).
+			Order("open_time ASC").Find(&rows).Error; err != nil {
+			return nil, false
+		}
+	}
+	if live != nil {
+		rows = append(rows, live.StoredCandle)
+	}
+	if len(rows) != want {
+		return nil, false
+	}
+
+	candles := make([]models.Candle, len(rows))
+	for i, r := range rows {
+		candles[i] = models.Candle{
+			OpenTime:  r.OpenTime.UnixMilli(),
+			Open:      r.Open,
+			High:      r.High,
+			Low:       r.Low,
+			Close:     r.Close,
+			Volume:    r.Volume,
+			CloseTime: r.OpenTime.Add(step).UnixMilli() - 1,
+		}
+	}
+	return candles, true
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+var marketClock = struct {
+	sync.RWMutex
+	now func() time.Time
+}{now: time.Now}
+
+// clockNow is the time feed health, the price index and settlement judge
+// prices by. It is wall time except during a market replay, where it follows
+// the recording.
+func clockNow() time.Time {
+	marketClock.RLock()
+	defer marketClock.RUnlock()
+	return marketClock.now()
+}
+
+// SetClock replaces the time source behind clockNow
+func SetClock(now func() time.Time) {
+	marketClock.Lock()
+	marketClock.now = now
+	marketClock.Unlock()
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const DemoCurrency = "USD"
+
+var ErrDemoTradesOpen = errors.New("demo trades still open")
+
+// CreateDefaultWallets gives a new user zero-balance USD/BTC/ETH wallets plus
+// the demo wallet.
+func CreateDefaultWallets(userID uint) {
+	defaultCurrencies := []string{"USD", "BTC", "ETH"}
+	for _, currency := range defaultCurrencies {
+		wallet := models.Wallet{
+			UserID:   userID,
+			Currency: currency,
+			Balance:  0,
+		}
+		config.DB.Create(&wallet)
+	}
+	EnsureDemoWallet(userID)
+}
+
+// DemoStartingBalance is the virtual balance a demo wallet starts (and resets) with.
+// Override with DEMO_BALANCE.
+func DemoStartingBalance() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("DEMO_BALANCE"), 64); err == nil && v > 0 {
+		return v
+	}
+	return 10000
+}
+
+// EnsureDemoWalletIndex allows at most one demo wallet per user. It can't be
+// created while duplicates made before it existed remain; those are logged.
+func EnsureDemoWalletIndex() {
+	if err := config.DB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_one_demo ON wallets (user_id) WHERE is_demo`).Error; err != nil {
+		log.Printf("⚠️ Demo wallet index not created, remove duplicate demo wallets: %v", err)
+	}
+}
+
+// EnsureDemoWallet returns the user's demo wallet, creating it on first use.
+// Concurrent first calls all get the one wallet the unique index lets through.
+func EnsureDemoWallet(userID uint) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := config.DB.Where("user_id = ? AND is_demo = ?", userID, true).First(&wallet).Error
+	if err == nil {
+		return &wallet, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	wallet = models.Wallet{
+		UserID:   userID,
+		Currency: DemoCurrency,
+		Balance:  DemoStartingBalance(),
+		IsDemo:   true,
+	}
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&wallet).Error; err != nil {
+		return nil, err
+	}
+	if wallet.ID == 0 {
+		// Another request created it first
+		if err := config.DB.Where("user_id = ? AND is_demo = ?", userID, true).First(&wallet).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &wallet, nil
+}
+
+// ResetDemoWallet restores the demo wallet to the starting balance. It refuses
+// while demo trades are still open so a late settlement can't skew the reset.
+func ResetDemoWallet(userID uint) (*models.Wallet, error) {
+	if _, err := EnsureDemoWallet(userID); err != nil {
+		return nil, err
+	}
+
+	var wallet models.Wallet
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so a concurrent demo trade can't debit between read and reset
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND is_demo = ?", userID, true).First(&wallet).Error; err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.Trade{}).
+			Where("user_id = ? AND is_demo = ? AND status = ?", userID, true, "OPEN").
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrDemoTradesOpen
+		}
+
+		diff := DemoStartingBalance() - wallet.Balance
+		wallet.Balance = DemoStartingBalance()
+		if err := tx.Save(&wallet).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WalletTransaction{
+			WalletID:  wallet.ID,
+			Amount:    diff,
+			Type:      "demo_reset",
+			Reference: "demo_reset",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
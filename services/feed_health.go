@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+)
+
+// FeedHealth is the state of one symbol's price feed
+type FeedHealth struct {
+	Symbol         string     `json:"symbol"`
+	Connected      bool       `json:"connected"`
+	LastTickAt     *time.Time `json:"last_tick_at,omitempty"`
+	TickAgeSeconds float64    `json:"tick_age_seconds"`
+	Reconnects     int        `json:"reconnects"`
+	LastError      string     `json:"last_error,omitempty"`
+	DivergencePct  float64    `json:"divergence_pct"` // spread between fresh sources
+	Sources        int        `json:"sources"`        // sources used in the index
+	Halted         bool       `json:"halted"`
+	HaltReason     string     `json:"halt_reason,omitempty"`
+	HaltedSince    *time.Time `json:"halted_since,omitempty"`
+}
+
+// TradingHaltedError is returned for new trades while a symbol's feed is unhealthy
+type TradingHaltedError struct {
+	Symbol string
+	Reason string
+}
+
+func (e *TradingHaltedError) Error() string {
+	return fmt.Sprintf("trading on %s is halted: %s", e.Symbol, e.Reason)
+}
+
+type feedState struct {
+	FeedHealth
+	connects     int
+	healthySince time.Time
+}
+
+var feeds = struct {
+	sync.Mutex
+	m map[string]*feedState
+}{m: map[string]*feedState{}}
+
+// FeedStaleAfter is how long a symbol may go without trades before it is
+// halted. Override with FEED_STALE_SECONDS.
+func FeedStaleAfter() time.Duration {
+	return time.Duration(envFloat("FEED_STALE_SECONDS", 15) * float64(time.Second))
+}
+
+// FeedMaxDivergence is the spread between sources, as a fraction, beyond
+// which a symbol is halted even if the index could drop the outliers.
+// Override with FEED_MAX_DIVERGENCE_PCT (percent).
+func FeedMaxDivergence() float64 {
+	return envFloat("FEED_MAX_DIVERGENCE_PCT", 5) / 100
+}
+
+// FeedRecoveryPeriod is how long a halted feed must stay healthy before
+// trading resumes. Override with FEED_RECOVERY_SECONDS.
+func FeedRecoveryPeriod() time.Duration {
+	return time.Duration(envFloat("FEED_RECOVERY_SECONDS", 10) * float64(time.Second))
+}
+
+func feedFor(symbol string) *feedState {
+	f := feeds.m[symbol]
+	if f == nil {
+		// Halted until the first connection proves the feed works
+		now := clockNow()
+		f = &feedState{FeedHealth: FeedHealth{Symbol: symbol, Halted: true, HaltReason: "feed not connected", HaltedSince: &now}}
+		feeds.m[symbol] = f
+	}
+	return f
+}
+
+// StartFeedMonitor tracks stream health and halts or resumes trading per
+// symbol, announcing each change with a "feed_status" WebSocket message.
+func StartFeedMonitor() {
+	trackFeedHealth()
+	go func() {
+		for range time.Tick(time.Second) {
+			checkFeeds()
+		}
+	}()
+}
+
+// trackFeedHealth subscribes to the stream events feed health is derived from
+func trackFeedHealth() {
+	OnMarketTick(func(t MarketTick) {
+		now := clockNow()
+		feeds.Lock()
+		feedFor(t.Symbol).LastTickAt = &now
+		feeds.Unlock()
+	})
+	OnMarketConnect(func(symbol string) {
+		feeds.Lock()
+		f := feedFor(symbol)
+		f.Connected = true
+		if f.connects++; f.connects > 1 {
+			f.Reconnects++
+		}
+		feeds.Unlock()
+	})
+	OnMarketDisconnect(func(symbol string, err error) {
+		feeds.Lock()
+		f := feedFor(symbol)
+		f.Connected = false
+		if err != nil {
+			f.LastError = err.Error()
+		}
+		feeds.Unlock()
+	})
+}
+
+func checkFeeds() {
+	for _, symbol := range streamingSymbols() {
+		checkFeed(symbol)
+	}
+}
+
+// checkFeed re-evaluates one symbol and applies the circuit breaker
+func checkFeed(symbol string) {
+	// Computed outside the lock; IndexPrice reads other sources' state
+	index, indexErr := IndexPrice(symbol)
+
+	feeds.Lock()
+	f := feedFor(symbol)
+	now := clockNow()
+
+	f.DivergencePct, f.Sources = 0, 0
+	var lo, hi float64
+	for _, c := range index.Components {
+		if c.Status == ComponentUsed {
+			f.Sources++
+		}
+		if c.Status != ComponentUsed && c.Status != ComponentOutlier {
+			continue
+		}
+		if lo == 0 || c.Price < lo {
+			lo = c.Price
+		}
+		hi = math.Max(hi, c.Price)
+	}
+	if lo > 0 {
+		f.DivergencePct = math.Round((hi-lo)/lo*10000) / 100
+	}
+	f.TickAgeSeconds = 0
+	if f.LastTickAt != nil {
+		f.TickAgeSeconds = math.Round(now.Sub(*f.LastTickAt).Seconds()*10) / 10
+	}
+
+	var reason string
+	switch {
+	case !f.Connected:
+		reason = "feed disconnected"
+	case f.LastTickAt == nil || now.Sub(*f.LastTickAt) > FeedStaleAfter():
+		reason = "feed stale"
+	case indexErr != nil:
+		reason = "no reliable index price"
+	case f.DivergencePct/100 > FeedMaxDivergence():
+		reason = fmt.Sprintf("sources diverge by %.2f%%", f.DivergencePct)
+	}
+
+	changed := false
+	switch {
+	case reason != "":
+		f.healthySince = time.Time{}
+		if !f.Halted {
+			f.HaltedSince = &now
+		}
+		changed = !f.Halted || f.HaltReason != reason
+		f.Halted, f.HaltReason = true, reason
+	case f.Halted:
+		if f.healthySince.IsZero() {
+			f.healthySince = now
+		}
+		if now.Sub(f.healthySince) >= FeedRecoveryPeriod() {
+			f.Halted, f.HaltReason, f.HaltedSince = false, "", nil
+			changed = true
+		}
+	}
+	status := f.FeedHealth
+	feeds.Unlock()
+
+	if changed {
+		announceFeedStatus(status)
+	}
+}
+
+func announceFeedStatus(status FeedHealth) {
+	msg, _ := json.Marshal(WSMessage{Type: "feed_status", Data: status, Timestamp: clockNow().UnixMilli()})
+	config.WSHub.Broadcast(string(msg))
+	broadcastMarket(string(msg))
+}
+
+// FeedStatus returns the health of every streamed symbol
+func FeedStatus() []FeedHealth {
+	symbols := streamingSymbols()
+	sort.Strings(symbols)
+	feeds.Lock()
+	defer feeds.Unlock()
+	out := make([]FeedHealth, 0, len(symbols))
+	for _, s := range symbols {
+		out = append(out, feedFor(s).FeedHealth)
+	}
+	return out
+}
+
+func symbolFeedStatus(symbol string) FeedHealth {
+	feeds.Lock()
+	defer feeds.Unlock()
+	return feedFor(symbol).FeedHealth
+}
+
+// CheckFeedHealthy fails with TradingHaltedError while symbol's circuit breaker is open
+func CheckFeedHealthy(symbol string) error {
+	feeds.Lock()
+	defer feeds.Unlock()
+	if f := feedFor(symbol); f.Halted {
+		return &TradingHaltedError{Symbol: symbol, Reason: f.HaltReason}
+	}
+	return nil
+}
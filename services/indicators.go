@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/indicators"
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+// MaxIndicatorPoints caps one indicator request, leaving room under
+// MaxHistoryLimit for the warm-up candles loaded before it.
+const MaxIndicatorPoints = 1000
+
+// IndicatorSeries is an indicator evaluated over a range of candles
+type IndicatorSeries struct {
+	Symbol    string             `json:"symbol"`
+	Interval  string             `json:"interval"`
+	Indicator indicators.Spec    `json:"indicator"`
+	Points    []indicators.Point `json:"points"`
+}
+
+// GetIndicator evaluates spec over the candles q selects. Extra history is
+// loaded before the range so the first points are already settled.
+func GetIndicator(ctx context.Context, q HistoryQuery, spec indicators.Spec) (*IndicatorSeries, error) {
+	step, ok := KlineIntervals[q.Interval]
+	if !ok {
+		return nil, ErrInvalidInterval
+	}
+	symbol, err := NormalizeSymbol(q.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit <= 0 || q.Limit > MaxIndicatorPoints {
+		q.Limit = MaxIndicatorPoints
+	}
+
+	want := q.Limit
+	lookback := spec.Lookback()
+	wide := q
+	wide.Symbol = symbol
+	wide.Limit = want + lookback
+	if q.Start != nil {
+		from := q.Start.Add(-time.Duration(lookback) * step)
+		wide.Start = &from
+	}
+	candles, err := GetPriceHistory(ctx, wide)
+	if err != nil {
+		return nil, err
+	}
+
+	points := spec.Compute(candles)
+	if q.Start != nil {
+		first := q.Start.UnixMilli()
+		for len(points) > 0 && points[0].Time < first {
+			points = points[1:]
+		}
+		if len(points) > want {
+			points = points[:want]
+		}
+	} else if len(points) > want {
+		points = points[len(points)-want:]
+	}
+	return &IndicatorSeries{Symbol: symbol, Interval: q.Interval, Indicator: spec, Points: points}, nil
+}
+
+// IndicatorUpdate is the latest point of a streamed indicator, sent to
+// /trading clients as an "indicator" message when a candle closes.
+type IndicatorUpdate struct {
+	Symbol    string           `json:"symbol"`
+	Interval  string           `json:"interval"`
+	Indicator indicators.Spec  `json:"indicator"`
+	Point     indicators.Point `json:"point"`
+}
+
+// indicatorWindows holds the recent closed candles per symbol and interval
+// with indicator subscribers, enough to evaluate the longest subscribed spec.
+var indicatorWindows = struct {
+	sync.Mutex
+	m map[candleKey][]models.Candle
+}{m: map[candleKey][]models.Candle{}}
+
+var (
+	indicatorStream sync.Once
+	indicatorCloses = make(chan ClosedCandle, 1000)
+)
+
+// startIndicatorStream evaluates subscribed indicators as candles close. The
+// work (which may load history) runs on its own goroutine, off the candle store.
+func startIndicatorStream() {
+	indicatorStream.Do(func() {
+		OnCandleClose(func(c ClosedCandle) {
+			select {
+			case indicatorCloses <- c:
+			default:
+			}
+		})
+		go func() {
+			for c := range indicatorCloses {
+				streamIndicators(c)
+			}
+		}()
+	})
+}
+
+func streamIndicators(c ClosedCandle) {
+	key := candleKey{c.Symbol, c.Resolution}
+	specs := indicatorSubscriptions(c.Symbol, c.Resolution)
+	if len(specs) == 0 {
+		indicatorWindows.Lock()
+		delete(indicatorWindows.m, key)
+		indicatorWindows.Unlock()
+		return
+	}
+	need := 1
+	for _, s := range specs {
+		need = max(need, s.Lookback()+1)
+	}
+
+	window, ok := advanceWindow(key, c.Candle, need)
+	if !ok {
+		return
+	}
+	for _, spec := range specs {
+		points := spec.Compute(window)
+		if len(points) == 0 || points[len(points)-1].Time != c.OpenTime {
+			continue
+		}
+		sendIndicator(IndicatorUpdate{Symbol: c.Symbol, Interval: c.Resolution, Indicator: spec, Point: points[len(points)-1]})
+	}
+}
+
+// advanceWindow appends closed to key's window and returns the last need
+// candles. It reloads history when the window is too short or a bar is
+// missing, and reports false for a late (repaired) bar, which is patched in
+// without producing a new point.
+func advanceWindow(key candleKey, closed models.Candle, need int) ([]models.Candle, bool) {
+	step := CandleResolutions[key.resolution].Milliseconds()
+
+	indicatorWindows.Lock()
+	window := indicatorWindows.m[key]
+	if n := len(window); n > 0 && closed.OpenTime <= window[n-1].OpenTime {
+		for i := range window {
+			if window[i].OpenTime == closed.OpenTime {
+				window[i] = closed
+			}
+		}
+		indicatorWindows.Unlock()
+		return nil, false
+	}
+	fresh := len(window) > 0 && window[len(window)-1].OpenTime+step == closed.OpenTime
+	indicatorWindows.Unlock()
+
+	if fresh && len(window)+1 >= need {
+		window = append(window, closed)
+	} else {
+		end := time.UnixMilli(closed.OpenTime)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		history, err := GetPriceHistory(ctx, HistoryQuery{Symbol: key.symbol, Interval: key.resolution, End: &end, Limit: need})
+		cancel()
+		if err != nil {
+			log.Printf("indicator history %s %s: %v", key.symbol, key.resolution, err)
+			return nil, false
+		}
+		if len(history) == 0 || history[len(history)-1].OpenTime != closed.OpenTime {
+			history = append(history, closed)
+		}
+		window = history
+	}
+	if len(window) > need {
+		window = append([]models.Candle(nil), window[len(window)-need:]...)
+	}
+
+	indicatorWindows.Lock()
+	indicatorWindows.m[key] = window
+	indicatorWindows.Unlock()
+	return window, true
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrKYCPending       = errors.New("a verification request is already pending")
+	ErrKYCLevel         = errors.New("requested level must be above the current one")
+	ErrKYCAlreadyReview = errors.New("submission has already been reviewed")
+)
+
+// KYCCheckResult is what an identity provider reports about a submission
+type KYCCheckResult struct {
+	Ref    string // provider-side reference
+	Result string // clear / consider / error
+}
+
+// KYCProvider runs automated identity checks on a submitted document. The
+// outcome is advisory; a reviewer always makes the final decision.
+type KYCProvider interface {
+	Name() string
+	Check(sub *models.KYCSubmission, documentPath string) (KYCCheckResult, error)
+}
+
+// StubKYCProvider accepts every document; for local development and tests.
+type StubKYCProvider struct{}
+
+func (StubKYCProvider) Name() string { return "stub" }
+
+func (StubKYCProvider) Check(sub *models.KYCSubmission, documentPath string) (KYCCheckResult, error) {
+	return KYCCheckResult{Ref: fmt.Sprintf("stub-%d", sub.ID), Result: "clear"}, nil
+}
+
+var kycProvider KYCProvider = StubKYCProvider{}
+
+// InitKYCProvider selects the identity provider from KYC_PROVIDER. Only the
+// stub ships with the backend; real providers plug in via SetKYCProvider.
+func InitKYCProvider() {
+	switch p := os.Getenv("KYC_PROVIDER"); p {
+	case "", "stub":
+		kycProvider = StubKYCProvider{}
+	default:
+		log.Printf("⚠️ Unknown KYC_PROVIDER %q, using stub", p)
+		kycProvider = StubKYCProvider{}
+	}
+}
+
+// SetKYCProvider replaces the identity provider
+func SetKYCProvider(p KYCProvider) {
+	kycProvider = p
+}
+
+func kycDocumentDir() string {
+	dir := os.Getenv("KYC_DOCUMENT_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "kyc")
+	}
+	return dir
+}
+
+// SubmitKYC stores the document, records the submission as pending and starts
+// the provider check in the background. Nothing is recorded if the document
+// can't be stored, so the user can simply try again.
+func SubmitKYC(sub *models.KYCSubmission, doc io.Reader, ext string) error {
+	// Write the upload under a temporary name; it is renamed once the
+	// submission is committed and has an ID
+	if err := os.MkdirAll(kycDocumentDir(), 0o700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(kycDocumentDir(), "upload-*"+ext)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+	_, err = io.Copy(f, doc)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	sub.Status = models.KYCPending
+	sub.Provider = kycProvider.Name()
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the user serialises concurrent submissions, so only one of
+		// them sees no pending submission
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, sub.UserID).Error; err != nil {
+			return err
+		}
+		if models.KYCRank[sub.Level] <= models.KYCRank[user.KYCLevel] {
+			return ErrKYCLevel
+		}
+		var pending int64
+		if err := tx.Model(&models.KYCSubmission{}).
+			Where("user_id = ? AND status = ?", sub.UserID, models.KYCPending).Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrKYCPending
+		}
+
+		if err := tx.Create(sub).Error; err != nil {
+			return err
+		}
+		sub.DocumentPath = filepath.Join(kycDocumentDir(), fmt.Sprintf("kyc-%d-%d%s", sub.UserID, sub.ID, ext))
+		return tx.Model(sub).Update("document_path", sub.DocumentPath).Error
+	})
+	if err != nil {
+		sub.ID, sub.DocumentPath = 0, ""
+		return err
+	}
+
+	// Only move the document into place once the submission is committed, so
+	// a failed commit leaves no stray file; a submission without its document
+	// is withdrawn
+	if err := os.Rename(tmp, sub.DocumentPath); err != nil {
+		if derr := config.DB.Delete(&models.KYCSubmission{}, sub.ID).Error; derr != nil {
+			log.Printf("KYC submission %d has no document and could not be removed: %v", sub.ID, derr)
+		}
+		sub.ID, sub.DocumentPath = 0, ""
+		return err
+	}
+
+	go runKYCCheck(sub.ID)
+	return nil
+}
+
+func runKYCCheck(subID uint) {
+	var sub models.KYCSubmission
+	if err := config.DB.First(&sub, subID).Error; err != nil {
+		return
+	}
+	res, err := kycProvider.Check(&sub, sub.DocumentPath)
+	if err != nil {
+		log.Printf("KYC check for submission %d failed: %v", sub.ID, err)
+		res.Result = "error"
+	}
+	config.DB.Model(&sub).Updates(map[string]interface{}{
+		"provider_ref":    res.Ref,
+		"provider_result": res.Result,
+	})
+}
+
+// ReviewKYC approves or rejects a pending submission. Approval raises the
+// user's KYC level to the one requested.
+func ReviewKYC(subID uint, approve bool, note string, reviewerID uint) (*models.KYCSubmission, error) {
+	var sub models.KYCSubmission
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&sub, subID).Error; err != nil {
+			return err
+		}
+		if sub.Status != models.KYCPending {
+			return ErrKYCAlreadyReview
+		}
+
+		now := time.Now()
+		sub.Status = models.KYCRejected
+		if approve {
+			sub.Status = models.KYCApproved
+		}
+		sub.ReviewerID = &reviewerID
+		sub.ReviewNote = note
+		sub.ReviewedAt = &now
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		if !approve {
+			return nil
+		}
+		return tx.Model(&models.User{}).Where("id = ?", sub.UserID).Update("kyc_level", sub.Level).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	config.WSHub.SendToUser(sub.UserID, fmt.Sprintf(`{"type": "kyc_review", "submission_id": %d, "status": "%s"}`, sub.ID, sub.Status))
+	return &sub, nil
+}
+
+// KYCLimitError is returned when an action would exceed the user's tier limits
+type KYCLimitError struct {
+	Level     string
+	Limit     string
+	Max       float64
+	Remaining float64
+}
+
+func (e *KYCLimitError) Error() string {
+	return fmt.Sprintf("%s limit of %.2f %s for KYC level %s exceeded", e.Limit, e.Max, LimitCurrency, e.Level)
+}
+
+// LimitCurrency is the currency KYC and responsible-gaming limits are set in
+const LimitCurrency = "USD"
+
+// limitValue converts amount of a wallet currency into LimitCurrency at the
+// current index price. USD stablecoins count at par.
+func limitValue(currency string, amount float64) (float64, error) {
+	switch currency = strings.ToUpper(currency); currency {
+	case LimitCurrency, "USDT", "USDC":
+		return amount, nil
+	}
+	idx, err := IndexPrice(currency + "USDT")
+	if err != nil {
+		return 0, err
+	}
+	return amount * idx.Price, nil
+}
+
+// KYCLimitsFor returns the level and limits that apply to a user
+func KYCLimitsFor(userID uint) (string, models.KYCLimits, error) {
+	var user models.User
+	if err := config.DB.Select("kyc_level").First(&user, userID).Error; err != nil {
+		return "", models.KYCLimits{}, err
+	}
+	level := user.KYCLevel
+	if _, ok := models.KYCTierLimits[level]; !ok {
+		level = models.KYCNone
+	}
+	return level, models.KYCTierLimits[level], nil
+}
+
+// realWalletTotal sums the signed amount of the given transaction types on the
+// user's real (non-demo) wallets since the given time, in LimitCurrency. Each
+// wallet currency's total is valued at the current price.
+func realWalletTotal(userID uint, since time.Time, txTypes ...string) (float64, error) {
+	var rows []struct {
+		Currency string
+		Total    float64
+	}
+	err := config.DB.Model(&models.WalletTransaction{}).
+		Joins("JOIN wallets ON wallets.id = wallet_transactions.wallet_id").
+		Where("wallets.user_id = ? AND wallets.is_demo = ? AND wallet_transactions.type IN ? AND wallet_transactions.created_at >= ?",
+			userID, false, txTypes, since).
+		Select("wallets.currency, COALESCE(SUM(wallet_transactions.amount), 0) AS total").
+		Group("wallets.currency").Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, r := range rows {
+		v, err := limitValue(r.Currency, r.Total)
+		if err != nil {
+			return 0, err
+		}
+		total += v
+	}
+	return total, nil
+}
+
+func checkDailyLimit(userID uint, currency string, amount float64, txType, name string, limit func(models.KYCLimits) float64) error {
+	level, limits, err := KYCLimitsFor(userID)
+	if err != nil {
+		return err
+	}
+	allowed := limit(limits)
+	if allowed == models.NoLimit {
+		return nil
+	}
+	if amount, err = limitValue(currency, amount); err != nil {
+		return err
+	}
+	used, err := realWalletTotal(userID, time.Now().Add(-24*time.Hour), txType)
+	if err != nil {
+		return err
+	}
+	used = math.Abs(used)
+	if used+amount > allowed {
+		return &KYCLimitError{Level: level, Limit: name, Max: allowed, Remaining: math.Max(allowed-used, 0)}
+	}
+	return nil
+}
+
+// CheckDepositLimit verifies a deposit of amount currency fits in the user's
+// daily KYC allowance
+func CheckDepositLimit(userID uint, currency string, amount float64) error {
+	return checkDailyLimit(userID, currency, amount, "deposit", "daily deposit",
+		func(l models.KYCLimits) float64 { return l.DailyDeposit })
+}
+
+// CheckWithdrawLimit verifies a withdrawal of amount currency fits in the
+// user's daily KYC allowance
+func CheckWithdrawLimit(userID uint, currency string, amount float64) error {
+	return checkDailyLimit(userID, currency, amount, "withdraw", "daily withdrawal",
+		func(l models.KYCLimits) float64 { return l.DailyWithdraw })
+}
+
+// CheckStakeLimit verifies a single trade stake of amount currency against the
+// user's KYC level
+func CheckStakeLimit(userID uint, currency string, amount float64) error {
+	level, limits, err := KYCLimitsFor(userID)
+	if err != nil {
+		return err
+	}
+	if limits.MaxStake == models.NoLimit {
+		return nil
+	}
+	if amount, err = limitValue(currency, amount); err != nil {
+		return err
+	}
+	if amount > limits.MaxStake {
+		return &KYCLimitError{Level: level, Limit: "max stake", Max: limits.MaxStake, Remaining: limits.MaxStake}
+	}
+	return nil
+}
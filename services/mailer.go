@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+// Mailer delivers a rendered email. Swap implementations with InitMailer.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTPMailer sends through a plain SMTP relay (STARTTLS negotiated by net/smtp).
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.From, to, subject, body)
+	return smtp.SendMail(m.Host+":"+m.Port, auth, m.From, []string{to}, []byte(msg))
+}
+
+// LogMailer is for local development: it logs each email and, if Dir is set,
+// also writes it to a file there. Bodies include live verification and reset
+// links, so it is refused outside development mode.
+type LogMailer struct {
+	Dir string
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.Printf("📧 mail to=%s subject=%q\n%s", to, subject, body)
+	if m.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(to))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s", to, subject, body)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o644)
+}
+
+// DefaultMailer is used by SendTemplate. Defaults to logging until InitMailer runs.
+var DefaultMailer Mailer = &LogMailer{}
+
+// InitMailer picks the mailer from MAILER (smtp | log, default log). The log
+// mailer is only allowed with APP_ENV=development.
+func InitMailer() error {
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		DefaultMailer = &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	default:
+		if !utils.IsDevMode() {
+			return errors.New("MAILER=smtp is required outside development; the log mailer would log reset links")
+		}
+		DefaultMailer = &LogMailer{Dir: os.Getenv("MAIL_DIR")}
+	}
+	return nil
+}
+
+// expiryText formats a link lifetime for emails: whole hours ("1 hour",
+// "48 hours"), otherwise whole minutes.
+func expiryText(d time.Duration) string {
+	n, unit := int(d/time.Minute), "minute"
+	if d%time.Hour == 0 {
+		n, unit = int(d/time.Hour), "hour"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", n, unit)
+}
+
+// AppURL is the frontend base used to build links in emails.
+func AppURL() string {
+	if v := os.Getenv("APP_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return "http://localhost:3000"
+}
+
+type emailTemplate struct {
+	subject string
+	body    *template.Template
+}
+
+var emailTemplates = map[string]emailTemplate{
+	"verify_email": {
+		subject: "Verify your email address",
+		body: template.Must(template.New("verify_email").Parse(`Hi {{.Username}},
+
+Please confirm your email address by opening the link below:
+
+{{.Link}}
+
+This link expires in {{.TTL}}. If you didn't create an account, ignore this email.
+`)),
+	},
+	"password_reset": {
+		subject: "Reset your password",
+		body: template.Must(template.New("password_reset").Parse(`Hi {{.Username}},
+
+Someone asked to reset the password for your account. Open the link below to choose a new one:
+
+{{.Link}}
+
+This link expires in {{.TTL}} and can only be used once. If this wasn't you, you can ignore this email.
+`)),
+	},
+	"password_changed": {
+		subject: "Your password was changed",
+		body: template.Must(template.New("password_changed").Parse(`Hi {{.Username}},
+
+The password for your account was just changed and all other sessions were signed out.
+If this wasn't you, contact support immediately.
+`)),
+	},
+	"confirm_email_change": {
+		subject: "Confirm your new email address",
+		body: template.Must(template.New("confirm_email_change").Parse(`Hi {{.Username}},
+
+Please confirm that you want to use this address for your account by opening the link below:
+
+{{.Link}}
+
+This link expires in {{.TTL}}. Until then your old address stays active.
+`)),
+	},
+	"email_change_requested": {
+		subject: "Your email address is being changed",
+		body: template.Must(template.New("email_change_requested").Parse(`Hi {{.Username}},
+
+Someone asked to change the email address on your account to {{.NewEmail}}.
+The change only happens once the new address is confirmed. If this wasn't you,
+change your password and contact support immediately.
+`)),
+	},
+	"price_alert": {
+		subject: "Price alert triggered",
+		body: template.Must(template.New("price_alert").Parse(`Hi {{.Username}},
+
+{{.Summary}}.
+{{if .Note}}
+Your note: {{.Note}}
+{{end}}
+{{if .Once}}This alert has now been switched off.{{else}}This alert stays on and will notify you again next time.{{end}}
+Manage your alerts at {{.Link}}. To stop these emails, turn off price alerts in your notification settings.
+`)),
+	},
+}
+
+// SendTemplate renders a named template with data and sends it via DefaultMailer.
+func SendTemplate(to, name string, data interface{}) error {
+	tpl, ok := emailTemplates[name]
+	if !ok {
+		return fmt.Errorf("unknown email template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := tpl.body.Execute(&buf, data); err != nil {
+		return err
+	}
+	return DefaultMailer.Send(to, tpl.subject, buf.String())
+}
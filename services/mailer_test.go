@@ -0,0 +1,24 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryText(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{time.Hour, "1 hour"},
+		{48 * time.Hour, "48 hours"},
+		{30 * time.Minute, "30 minutes"},
+		{time.Minute, "1 minute"},
+		{90 * time.Minute, "90 minutes"},
+	}
+	for _, tt := range tests {
+		if got := expiryText(tt.ttl); got != tt.want {
+			t.Errorf("expiryText(%v) = %q, want %q", tt.ttl, got, tt.want)
+		}
+	}
+}
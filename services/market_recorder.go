@@ -0,0 +1,206 @@
+package services
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Recorded event kinds
+const (
+	RecordMessage    = "message"    // a raw trade or ticker message
+	RecordConnect    = "connect"    // the symbol's stream (re)connected
+	RecordDisconnect = "disconnect" // the symbol's stream dropped
+	RecordQuote      = "quote"      // a polled price source's quote (or error)
+)
+
+// RecordedEvent is one line of a market recording
+type RecordedEvent struct {
+	At     int64           `json:"at"` // receive time, Unix ms
+	Symbol string          `json:"symbol"`
+	Event  string          `json:"event"`
+	Source string          `json:"source,omitempty"` // price source of a quote
+	Data   json.RawMessage `json:"data,omitempty"`   // combined-stream message as received, or a SourceQuote
+	Error  string          `json:"error,omitempty"`
+}
+
+// Recording layout: <dir>/<YYYY-MM-DD>/<HH>-<part>.jsonl.gz, a gzip file per
+// UTC hour and recorder process. part is the process's start time in Unix ms,
+// so parts sort in the order they were written, and a restart never appends
+// to a file a crash left without its gzip trailer.
+const (
+	recordDayLayout  = "2006-01-02"
+	recordHourLayout = "15"
+	recordFileSuffix = ".jsonl.gz"
+)
+
+func recordingPath(dir string, hour time.Time, part string) string {
+	hour = hour.UTC()
+	return filepath.Join(dir, hour.Format(recordDayLayout), hour.Format(recordHourLayout)+"-"+part+recordFileSuffix)
+}
+
+// recordingParts lists the files recorded for hour, oldest first
+func recordingParts(dir string, hour time.Time) ([]string, error) {
+	paths, err := filepath.Glob(recordingPath(dir, hour, "*"))
+	sort.Strings(paths)
+	return paths, err
+}
+
+// Events are queued so the stream never waits on the disk
+var marketRecorder struct {
+	events chan RecordedEvent
+}
+
+// RecordRetention is how long recordings are kept. Override with
+// MARKET_RECORD_RETENTION_DAYS.
+func RecordRetention() time.Duration {
+	return time.Duration(envFloat("MARKET_RECORD_RETENTION_DAYS", 30) * float64(24*time.Hour))
+}
+
+// StartMarketRecorder writes every raw trade and ticker message, stream
+// connects and disconnects, and the quotes polled from the other price
+// sources to hourly gzip files under MARKET_RECORD_DIR, so a period can be
+// replayed later (see cmd/replay). Does nothing when MARKET_RECORD_DIR is
+// unset. Call before InitPriceIndex and StartMarketStreams.
+func StartMarketRecorder() {
+	dir := os.Getenv("MARKET_RECORD_DIR")
+	if dir == "" {
+		return
+	}
+	marketRecorder.events = make(chan RecordedEvent, 10000)
+
+	OnMarketConnect(func(symbol string) {
+		queueRecord(RecordedEvent{At: time.Now().UnixMilli(), Symbol: symbol, Event: RecordConnect})
+	})
+	OnMarketDisconnect(func(symbol string, err error) {
+		ev := RecordedEvent{At: time.Now().UnixMilli(), Symbol: symbol, Event: RecordDisconnect}
+		if err != nil {
+			ev.Error = err.Error()
+		}
+		queueRecord(ev)
+	})
+
+	part := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	go writeRecording(dir, part, marketRecorder.events)
+	go func() {
+		for ; ; time.Sleep(time.Hour) {
+			pruneRecordings(dir, time.Now().Add(-RecordRetention()))
+		}
+	}()
+}
+
+func recordMarketMessage(symbol string, msg []byte) {
+	if marketRecorder.events == nil {
+		return
+	}
+	queueRecord(RecordedEvent{At: time.Now().UnixMilli(), Symbol: symbol, Event: RecordMessage, Data: json.RawMessage(msg)})
+}
+
+func recordSourceQuote(source, symbol string, q SourceQuote, err error) {
+	if marketRecorder.events == nil {
+		return
+	}
+	ev := RecordedEvent{At: time.Now().UnixMilli(), Symbol: symbol, Event: RecordQuote, Source: source}
+	if err != nil {
+		ev.Error = err.Error()
+	} else if ev.Data, err = json.Marshal(q); err != nil {
+		return
+	}
+	queueRecord(ev)
+}
+
+func queueRecord(ev RecordedEvent) {
+	select {
+	case marketRecorder.events <- ev:
+	default:
+		log.Printf("market recorder behind, dropped %s %s event", ev.Symbol, ev.Event)
+	}
+}
+
+// recordingFile is the open hourly file. Reopening an hour within a process
+// appends a new gzip member after the closed one, which readers see as one
+// stream.
+type recordingFile struct {
+	hour time.Time
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func (r *recordingFile) close() {
+	if r.f == nil {
+		return
+	}
+	if err := r.gz.Close(); err != nil {
+		log.Println("close recording:", err)
+	}
+	r.f.Close()
+	r.f = nil
+}
+
+func (r *recordingFile) open(dir, part string, hour time.Time) error {
+	path := recordingPath(dir, hour, part)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	r.hour, r.f = hour, f
+	r.gz = gzip.NewWriter(f)
+	r.enc = json.NewEncoder(r.gz)
+	return nil
+}
+
+// writeRecording appends events to the file for their hour, flushing every
+// second so a crash loses at most the last second.
+func writeRecording(dir, part string, events <-chan RecordedEvent) {
+	var file recordingFile
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+	for {
+		select {
+		case ev := <-events:
+			hour := time.UnixMilli(ev.At).UTC().Truncate(time.Hour)
+			if file.f == nil || !hour.Equal(file.hour) {
+				file.close()
+				if err := file.open(dir, part, hour); err != nil {
+					log.Println("open recording:", err)
+					continue
+				}
+			}
+			if err := file.enc.Encode(ev); err != nil {
+				log.Println("write recording:", err)
+			}
+		case <-flush.C:
+			if file.f != nil {
+				file.gz.Flush()
+			}
+		}
+	}
+}
+
+// pruneRecordings deletes day directories entirely older than before
+func pruneRecordings(dir string, before time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		day, err := time.Parse(recordDayLayout, e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		if day.Add(24 * time.Hour).Before(before) {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				log.Println("prune recordings:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+// writePart records events for hour as recorder process part. crash leaves
+// the file as a killed process would after its last flush: the unflushed
+// events lost and no gzip trailer. torn ends the flushed data mid-line.
+func writePart(t *testing.T, dir, part string, hour time.Time, flushed, unflushed []RecordedEvent, crash, torn bool) {
+	t.Helper()
+	var file recordingFile
+	if err := file.open(dir, part, hour); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range flushed {
+		if err := file.enc.Encode(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !crash {
+		file.close()
+		return
+	}
+	if torn {
+		file.gz.Write([]byte(`{"at":17096`))
+	}
+	file.gz.Flush()
+	for _, ev := range unflushed {
+		file.enc.Encode(ev)
+	}
+	file.f.Close()
+}
+
+func events(hour time.Time, symbol string, seconds ...int) []RecordedEvent {
+	var out []RecordedEvent
+	for _, s := range seconds {
+		out = append(out, RecordedEvent{
+			At:     hour.Add(time.Duration(s) * time.Second).UnixMilli(),
+			Symbol: symbol,
+			Event:  RecordMessage,
+			Data:   json.RawMessage(`{"stream":"test"}`),
+		})
+	}
+	return out
+}
+
+func TestReadRecordingAfterCrash(t *testing.T) {
+	hour := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	next := hour.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		torn bool
+		// appendRestart writes the restarted process into the crashed file,
+		// as the recorder did before files were per process
+		appendRestart bool
+		want          []int64 // seconds into hour of the events read, in order
+	}{
+		{name: "no trailer", want: []int64{1, 2, 5, 6, 3600}},
+		{name: "torn line", torn: true, want: []int64{1, 2, 5, 6, 3600}},
+		{name: "restart appended to crashed file", appendRestart: true, want: []int64{1, 2, 3600}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePart(t, dir, "1709647200000", hour, events(hour, "BTCUSDT", 1, 2), events(hour, "BTCUSDT", 3), true, tt.torn)
+			if tt.appendRestart {
+				writePart(t, dir, "1709647200000", hour, events(hour, "BTCUSDT", 5, 6), nil, false, false)
+			} else {
+				writePart(t, dir, "1709647204000", hour, events(hour, "BTCUSDT", 5, 6), nil, false, false)
+			}
+			writePart(t, dir, "1709647204000", next, events(hour, "BTCUSDT", 3600), nil, false, false)
+
+			var got []int64
+			err := readRecording(dir, hour, next.Add(time.Minute), func(ev RecordedEvent) error {
+				got = append(got, (ev.At-hour.UnixMilli())/1000)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("readRecording: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("read events at %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("read events at %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRecordingEmptyPart(t *testing.T) {
+	// A process killed before its first flush leaves an empty file
+	hour := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	writePart(t, dir, "1709647200000", hour, nil, events(hour, "BTCUSDT", 1), true, false)
+	os.Truncate(recordingPath(dir, hour, "1709647200000"), 0)
+	writePart(t, dir, "1709647201000", hour, events(hour, "BTCUSDT", 2), nil, false, false)
+
+	n := 0
+	if err := readRecording(dir, hour, hour.Add(time.Hour), func(RecordedEvent) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("read %d events, want 1", n)
+	}
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+// ReplayOptions selects the part of a recording ReplayMarket plays back
+type ReplayOptions struct {
+	Dir     string
+	From    time.Time
+	To      time.Time // zero for the end of the recording
+	Symbols []string  // empty for every recorded symbol
+	Speed   float64   // 1 is real time, 10 ten times faster; 0 as fast as possible
+}
+
+// SettlementReplay is a trade settled again against the replayed feed
+type SettlementReplay struct {
+	TradeID        uint      `json:"trade_id"`
+	Symbol         string    `json:"symbol"`
+	Direction      string    `json:"direction"`
+	EntryPrice     float64   `json:"entry_price"`
+	ExpiredAt      time.Time `json:"expired_at"`
+	Result         string    `json:"result,omitempty"` // WON, LOST or VOID; empty if it could not be replayed
+	ExitPrice      float64   `json:"exit_price,omitempty"`
+	Payout         float64   `json:"payout,omitempty"`
+	PricedAt       time.Time `json:"priced_at,omitempty"` // replayed time the exit price was taken
+	Reason         string    `json:"reason,omitempty"`    // why it was voided or not replayed
+	RecordedStatus string    `json:"recorded_status"`
+	RecordedExit   float64   `json:"recorded_exit_price"`
+}
+
+var errReplayDone = errors.New("replay reached its end time")
+
+// replayClock is the time during a replay: the time of the event or timer
+// being processed.
+type replayClock struct {
+	mu sync.RWMutex
+	t  time.Time
+}
+
+func (c *replayClock) now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.t
+}
+
+func (c *replayClock) set(t time.Time) {
+	c.mu.Lock()
+	if t.After(c.t) {
+		c.t = t
+	}
+	c.mu.Unlock()
+}
+
+// pendingSettlement is a replayed trade waiting for its next pricing attempt
+type pendingSettlement struct {
+	*SettlementReplay
+	trade    models.Trade
+	next     time.Time
+	deadline time.Time
+}
+
+// replayedSource serves a polled price source's recorded quotes: each
+// symbol's latest one replayed so far, as the live poller held it
+type replayedSource struct {
+	name string
+	mu   sync.Mutex
+	last map[string]polledQuote
+}
+
+func (s *replayedSource) Name() string { return s.name }
+
+func (s *replayedSource) Quote(symbol string) (SourceQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, ok := s.last[symbol]
+	if !ok {
+		return SourceQuote{}, ErrNoQuote
+	}
+	return last.quote, last.err
+}
+
+func (s *replayedSource) replay(ev RecordedEvent) {
+	var last polledQuote
+	if ev.Error != "" {
+		last.err = errors.New(ev.Error)
+	} else if err := json.Unmarshal(ev.Data, &last.quote); err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.last[ev.Symbol] = last
+	s.mu.Unlock()
+}
+
+// replayPriceSources builds the index sources named in PRICE_SOURCES, as
+// InitPriceIndex does, with the polled ones served from the recording
+func replayPriceSources() ([]PriceSource, map[string]*replayedSource) {
+	var sources []PriceSource
+	recorded := map[string]*replayedSource{}
+	for _, name := range priceSourceNames() {
+		if name == "binance" {
+			sources = append(sources, BinanceSource{})
+			continue
+		}
+		src := &replayedSource{name: name, last: map[string]polledQuote{}}
+		recorded[name] = src
+		sources = append(sources, src)
+	}
+	return sources, recorded
+}
+
+// ReplayMarket feeds a recording back through the market stream handlers, as
+// if it were arriving from the exchange: prices, ticks, feed health and
+// /trading clients behave as they did live. The price index is built from
+// PRICE_SOURCES like InitPriceIndex builds it, with the recorded quotes of
+// the polled sources, so run it with the live INDEX_* and PRICE_SOURCES
+// settings. The service clock follows the recording, and feed checks and
+// settlement attempts run at their replayed times, so the outcome doesn't
+// depend on Speed. Each of trades is settled again the way SettleTrade would,
+// without touching the database. Call once per process, without
+// StartMarketStreams, StartFeedMonitor or InitPriceIndex.
+func ReplayMarket(ctx context.Context, opts ReplayOptions, trades []models.Trade) ([]SettlementReplay, error) {
+	clock := &replayClock{t: opts.From}
+	SetClock(clock.now)
+	trackFeedHealth()
+	sources, recorded := replayPriceSources()
+	SetPriceSources(sources...)
+
+	wanted := map[string]bool{}
+	for _, s := range opts.Symbols {
+		wanted[strings.ToUpper(s)] = true
+	}
+
+	results := make([]SettlementReplay, len(trades))
+	var pending []*pendingSettlement
+	for i, t := range trades {
+		results[i] = SettlementReplay{
+			TradeID: t.ID, Symbol: t.Asset, Direction: t.Direction, EntryPrice: t.EntryPrice,
+			ExpiredAt: t.ExpiredAt, RecordedStatus: t.Status, RecordedExit: t.ExitPrice,
+		}
+		switch {
+		case len(wanted) > 0 && !wanted[t.Asset]:
+			results[i].Reason = "symbol not replayed"
+		case !t.ExpiredAt.After(opts.From):
+			results[i].Reason = "expired before the replay starts"
+		default:
+			pending = append(pending, &pendingSettlement{
+				SettlementReplay: &results[i],
+				trade:            t,
+				next:             t.ExpiredAt,
+				deadline:         t.ExpiredAt.Add(SettlementGrace()),
+			})
+		}
+	}
+	settle := func(at time.Time) {
+		remaining := pending[:0]
+		for _, p := range pending {
+			if p.next.After(at) {
+				remaining = append(remaining, p)
+				continue
+			}
+			price, err := settlementQuote(p.Symbol)
+			switch {
+			case err == nil:
+				p.Result, p.Payout = tradeOutcome(p.trade, price)
+				p.ExitPrice, p.PricedAt = price, at
+			case at.After(p.deadline):
+				p.Result, p.Reason, p.PricedAt = "VOID", "no reliable price at expiry: "+err.Error(), at
+			default:
+				p.next = at.Add(time.Second) // as settlementPrice polls
+				remaining = append(remaining, p)
+			}
+		}
+		pending = remaining
+	}
+
+	var (
+		started   bool
+		firstAt   time.Time
+		wallStart time.Time
+		nextCheck time.Time // next whole second; feeds are checked once a second
+		last      time.Time
+		streamed  = map[string]bool{}
+	)
+	pace := func(t time.Time) error {
+		if opts.Speed <= 0 {
+			return ctx.Err()
+		}
+		wait := time.Until(wallStart.Add(time.Duration(float64(t.Sub(firstAt)) / opts.Speed)))
+		if wait <= 0 {
+			return ctx.Err()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+			return nil
+		}
+	}
+	// advance runs the timers due up to t, in order, then moves the clock to t
+	advance := func(t time.Time) error {
+		for {
+			next := nextCheck
+			for _, p := range pending {
+				if p.next.Before(next) {
+					next = p.next
+				}
+			}
+			if next.After(t) {
+				break
+			}
+			if err := pace(next); err != nil {
+				return err
+			}
+			clock.set(next)
+			if next.Equal(nextCheck) {
+				checkFeeds()
+				nextCheck = nextCheck.Add(time.Second)
+			}
+			settle(next)
+		}
+		if err := pace(t); err != nil {
+			return err
+		}
+		clock.set(t)
+		return nil
+	}
+
+	err := readRecording(opts.Dir, opts.From, opts.To, func(ev RecordedEvent) error {
+		if len(wanted) > 0 && !wanted[ev.Symbol] {
+			return nil
+		}
+		at := time.UnixMilli(ev.At)
+		if at.Before(opts.From) {
+			return nil
+		}
+		if !opts.To.IsZero() && at.After(opts.To) {
+			return errReplayDone
+		}
+		if !started {
+			started, firstAt, wallStart = true, at, time.Now()
+			nextCheck = at.Truncate(time.Second).Add(time.Second)
+		}
+		if ev.Event == RecordQuote {
+			if err := advance(at); err != nil {
+				return err
+			}
+			last = at
+			if src := recorded[ev.Source]; src != nil {
+				src.replay(ev)
+			}
+			return nil
+		}
+		first := !streamed[ev.Symbol]
+		if first {
+			streamed[ev.Symbol] = true
+			markStreaming(ev.Symbol)
+		}
+		if err := advance(at); err != nil {
+			return err
+		}
+		last = at
+		// Messages were only recorded while connected, so a range starting
+		// mid-connection opens with one
+		if first && ev.Event == RecordMessage {
+			publishConnect(ev.Symbol)
+		}
+
+		switch ev.Event {
+		case RecordConnect:
+			publishConnect(ev.Symbol)
+		case RecordDisconnect:
+			publishDisconnect(ev.Symbol, errors.New(ev.Error))
+		case RecordMessage:
+			dispatchMarketMessage(ev.Symbol, ev.Data)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errReplayDone) {
+		return results, err
+	}
+
+	// Play out the remaining timers up to the end of the range, so settlements
+	// waiting on a recovering feed get their final attempts
+	if started {
+		end := last
+		if !opts.To.IsZero() {
+			end = opts.To
+		}
+		if err := advance(end); err != nil {
+			return results, err
+		}
+	}
+	for _, p := range pending {
+		p.Reason = "recording ends before settlement"
+	}
+	return results, nil
+}
+
+// readRecording calls fn for each recorded event in the hourly files covering
+// [from, to] (to zero for now), oldest first. A file that can't be decoded to
+// the end (still being written, or cut short by a crash) is read up to the
+// damage and the replay moves on to the next one.
+func readRecording(dir string, from, to time.Time, fn func(RecordedEvent) error) error {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	for hour := from.UTC().Truncate(time.Hour); !hour.After(to); hour = hour.Add(time.Hour) {
+		paths, err := recordingParts(dir, hour)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			var stop error
+			err = readRecordingFile(f, func(ev RecordedEvent) error {
+				stop = fn(ev)
+				return stop
+			})
+			f.Close()
+			if stop != nil {
+				return stop
+			}
+			if err != nil {
+				log.Printf("%s is damaged or incomplete (%v); replayed what was readable", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func readRecordingFile(r io.Reader, fn func(RecordedEvent) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var ev RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue // a line torn by a crash
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
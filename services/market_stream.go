@@ -0,0 +1,259 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MarketTick is one trade received from the exchange stream
+type MarketTick struct {
+	Symbol   string
+	TradeID  int64
+	Price    float64
+	Quantity float64
+	Time     time.Time
+}
+
+var marketHandlers struct {
+	sync.RWMutex
+	tick       []func(MarketTick)
+	connect    []func(symbol string)
+	disconnect []func(symbol string, err error)
+}
+
+// OnMarketTick registers a handler for every trade on every streamed symbol.
+// Handlers run on the stream goroutine and must not block.
+func OnMarketTick(h func(MarketTick)) {
+	marketHandlers.Lock()
+	marketHandlers.tick = append(marketHandlers.tick, h)
+	marketHandlers.Unlock()
+}
+
+// OnMarketConnect registers a handler called each time a symbol's stream
+// (re)connects, so consumers can repair anything missed while it was down.
+func OnMarketConnect(h func(symbol string)) {
+	marketHandlers.Lock()
+	marketHandlers.connect = append(marketHandlers.connect, h)
+	marketHandlers.Unlock()
+}
+
+// OnMarketDisconnect registers a handler called when a symbol's stream drops
+func OnMarketDisconnect(h func(symbol string, err error)) {
+	marketHandlers.Lock()
+	marketHandlers.disconnect = append(marketHandlers.disconnect, h)
+	marketHandlers.Unlock()
+}
+
+func publishTick(t MarketTick) {
+	marketHandlers.RLock()
+	defer marketHandlers.RUnlock()
+	for _, h := range marketHandlers.tick {
+		h(t)
+	}
+}
+
+func publishConnect(symbol string) {
+	marketHandlers.RLock()
+	defer marketHandlers.RUnlock()
+	for _, h := range marketHandlers.connect {
+		h(symbol)
+	}
+}
+
+func publishDisconnect(symbol string, err error) {
+	marketHandlers.RLock()
+	defer marketHandlers.RUnlock()
+	for _, h := range marketHandlers.disconnect {
+		h(symbol, err)
+	}
+}
+
+// MarketSymbols lists the symbols streamed and stored server-side, from
+// MARKET_SYMBOLS (comma separated), defaulting to BTCUSDT and ETHUSDT.
+func MarketSymbols() []string {
+	v := os.Getenv("MARKET_SYMBOLS")
+	if v == "" {
+		return []string{"BTCUSDT", "ETHUSDT"}
+	}
+	var symbols []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			symbols = append(symbols, s)
+		}
+	}
+	return symbols
+}
+
+func binanceStreamURL() string {
+	if v := os.Getenv("BINANCE_WS"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return "wss://stream.binance.com:9443"
+}
+
+var streamedSymbols = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+// StartMarketStreams keeps one trade, ticker and depth stream per symbol open
+// for the lifetime of the process, reconnecting after errors. Trades fan out
+// to the registered handlers; trades and tickers keep the price cache current
+// and depth diffs the local order book. Symbols already
+// streaming are skipped, so it is safe to call again for newly listed markets.
+func StartMarketStreams(symbols []string) {
+	streamedSymbols.Lock()
+	defer streamedSymbols.Unlock()
+	for _, symbol := range symbols {
+		if !streamedSymbols.m[symbol] {
+			streamedSymbols.m[symbol] = true
+			go runMarketStream(symbol)
+		}
+	}
+}
+
+// markStreaming counts symbol as streamed without connecting to the exchange,
+// for replays that feed recorded messages instead.
+func markStreaming(symbol string) {
+	streamedSymbols.Lock()
+	streamedSymbols.m[symbol] = true
+	streamedSymbols.Unlock()
+}
+
+// streamingSymbols lists the symbols StartMarketStreams has started
+func streamingSymbols() []string {
+	streamedSymbols.Lock()
+	defer streamedSymbols.Unlock()
+	symbols := make([]string, 0, len(streamedSymbols.m))
+	for s := range streamedSymbols.m {
+		symbols = append(symbols, s)
+	}
+	return symbols
+}
+
+// Reconnect backoff: doubles from min to max, reset once a connection has
+// stayed up for streamStableAfter
+const (
+	streamBackoffMin  = time.Second
+	streamBackoffMax  = time.Minute
+	streamStableAfter = time.Minute
+	// The ticker stream pushes every second, so a silent minute means a dead connection
+	streamReadTimeout = 30 * time.Second
+)
+
+func runMarketStream(symbol string) {
+	s := strings.ToLower(symbol)
+	url := binanceStreamURL() + "/stream?streams=" + s + "@trade/" + s + "@ticker/" + s + "@depth@100ms"
+	backoff := streamBackoffMin
+	for {
+		started := time.Now()
+		err := readMarketStream(symbol, url)
+		log.Printf("%s market stream: %v", symbol, err)
+		publishDisconnect(symbol, err)
+
+		if time.Since(started) > streamStableAfter {
+			backoff = streamBackoffMin
+		}
+		// Full jitter so many symbols don't reconnect in lockstep
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		if backoff *= 2; backoff > streamBackoffMax {
+			backoff = streamBackoffMax
+		}
+	}
+}
+
+func readMarketStream(symbol, url string) error {
+	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	publishConnect(symbol)
+
+	for {
+		c.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if kind := dispatchMarketMessage(symbol, msg); kind == "trade" || kind == "ticker" {
+			recordMarketMessage(symbol, msg)
+		}
+	}
+}
+
+// dispatchMarketMessage routes one combined-stream message to its handler and
+// returns which stream it came from (trade, ticker or depth), or "" if unknown.
+func dispatchMarketMessage(symbol string, msg []byte) string {
+	var env struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(msg, &env); err != nil {
+		return ""
+	}
+	switch {
+	case strings.HasSuffix(env.Stream, "@trade"):
+		handleTradeMessage(symbol, env.Data)
+		return "trade"
+	case strings.HasSuffix(env.Stream, "@ticker"):
+		handleTickerMessage(symbol, env.Data)
+		return "ticker"
+	case strings.HasSuffix(env.Stream, "@depth@100ms"):
+		handleDepthMessage(symbol, env.Data)
+		return "depth"
+	}
+	return ""
+}
+
+func handleTradeMessage(symbol string, msg []byte) {
+	var data struct {
+		TradeID   int64  `json:"t"`
+		Price     string `json:"p"`
+		Quantity  string `json:"q"`
+		TradeTime int64  `json:"T"`
+	}
+	if err := json.Unmarshal(msg, &data); err != nil {
+		return
+	}
+	price, err := strconv.ParseFloat(data.Price, 64)
+	if err != nil || price <= 0 {
+		return
+	}
+	qty, _ := strconv.ParseFloat(data.Quantity, 64)
+	t := time.UnixMilli(data.TradeTime)
+	setLastPrice(symbol, price, t)
+	publishTick(MarketTick{
+		Symbol:   symbol,
+		TradeID:  data.TradeID,
+		Price:    price,
+		Quantity: qty,
+		Time:     t,
+	})
+}
+
+func handleTickerMessage(symbol string, msg []byte) {
+	var data struct {
+		Change    string `json:"p"`
+		ChangePct string `json:"P"`
+		High      string `json:"h"`
+		Low       string `json:"l"`
+		Volume    string `json:"v"`
+	}
+	if err := json.Unmarshal(msg, &data); err != nil {
+		return
+	}
+	f := func(s string) float64 {
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	}
+	setTickerStats(symbol, f(data.Change), f(data.ChangePct), f(data.High), f(data.Low), f(data.Volume))
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMarketNotFound  = errors.New("market not found")
+	ErrMarketExists    = errors.New("market already exists")
+	ErrMarketClosed    = errors.New("market is not open for trading")
+	ErrDurationInvalid = errors.New("duration not offered on this market")
+	ErrMarketInvalid   = errors.New("invalid market settings")
+)
+
+// Quote assets recognised when splitting a bare symbol, longest match first
+var knownQuoteAssets = []string{"USDT", "USDC", "BUSD", "EUR", "BTC", "ETH"}
+
+// SeedMarkets creates a catalog entry with default settings for each symbol
+// that doesn't have one yet. Existing entries are left untouched.
+func SeedMarkets(symbols []string) {
+	for i, symbol := range symbols {
+		base, quote := splitSymbol(symbol)
+		m := models.Market{
+			Symbol:           symbol,
+			BaseAsset:        base,
+			QuoteAsset:       quote,
+			DisplayName:      base + "/" + quote,
+			PricePrecision:   2,
+			Status:           models.MarketTrading,
+			AllowedDurations: models.DefaultDurations,
+			Payout:           models.DefaultPayout,
+			SortOrder:        i,
+		}
+		config.DB.Where(models.Market{Symbol: symbol}).FirstOrCreate(&m)
+	}
+}
+
+func splitSymbol(symbol string) (base, quote string) {
+	for _, q := range knownQuoteAssets {
+		if strings.HasSuffix(symbol, q) && len(symbol) > len(q) {
+			return strings.TrimSuffix(symbol, q), q
+		}
+	}
+	return symbol, ""
+}
+
+// ListMarkets returns the catalog in display order; delisted markets only
+// when includeDelisted is set.
+func ListMarkets(includeDelisted bool) ([]models.Market, error) {
+	var markets []models.Market
+	q := config.DB.Order("sort_order, symbol")
+	if !includeDelisted {
+		q = q.Where("status <> ?", models.MarketDelisted)
+	}
+	err := q.Find(&markets).Error
+	return markets, err
+}
+
+// ActiveMarketSymbols lists the symbols that are streamed: every market that
+// isn't delisted.
+func ActiveMarketSymbols() []string {
+	var symbols []string
+	config.DB.Model(&models.Market{}).Where("status <> ?", models.MarketDelisted).
+		Order("sort_order, symbol").Pluck("symbol", &symbols)
+	return symbols
+}
+
+// GetMarket looks up a market by symbol, case-insensitively
+func GetMarket(symbol string) (*models.Market, error) {
+	var m models.Market
+	err := config.DB.Where("symbol = ?", strings.ToUpper(symbol)).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMarketNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// TradableMarket returns the market for a new trade, checking it is open,
+// offers the requested duration and its price feed is healthy.
+func TradableMarket(symbol string, duration int) (*models.Market, error) {
+	m, err := GetMarket(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status != models.MarketTrading {
+		return nil, ErrMarketClosed
+	}
+	if !m.AllowsDuration(duration) {
+		return nil, ErrDurationInvalid
+	}
+	if err := CheckFeedHealthy(m.Symbol); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ValidateMarket checks the settings an admin can change
+func ValidateMarket(m *models.Market) error {
+	switch {
+	case m.Status != models.MarketTrading && m.Status != models.MarketHalted && m.Status != models.MarketDelisted,
+		m.Payout <= 0 || m.Payout > 10,
+		m.PricePrecision < 0 || m.PricePrecision > 10,
+		len(m.AllowedDurations) == 0:
+		return ErrMarketInvalid
+	}
+	for _, d := range m.AllowedDurations {
+		if d < 5 || d > 86400 {
+			return ErrMarketInvalid
+		}
+	}
+	return nil
+}
+
+// CreateMarket adds a market listed on Binance to the catalog and, unless
+// it is delisted, starts streaming it.
+func CreateMarket(m *models.Market) error {
+	symbol, err := NormalizeSymbol(m.Symbol)
+	if err != nil {
+		return err
+	}
+	m.Symbol = symbol
+	if m.BaseAsset == "" || m.QuoteAsset == "" {
+		base, quote := splitSymbol(symbol)
+		if m.BaseAsset == "" {
+			m.BaseAsset = base
+		}
+		if m.QuoteAsset == "" {
+			m.QuoteAsset = quote
+		}
+	}
+	if m.QuoteAsset == "" {
+		return ErrMarketInvalid
+	}
+	if m.DisplayName == "" {
+		m.DisplayName = m.BaseAsset + "/" + m.QuoteAsset
+	}
+	if m.Status == "" {
+		m.Status = models.MarketTrading
+	}
+	if m.AllowedDurations == nil {
+		m.AllowedDurations = models.DefaultDurations
+	}
+	if m.Payout == 0 {
+		m.Payout = models.DefaultPayout
+	}
+	if err := ValidateMarket(m); err != nil {
+		return err
+	}
+
+	var count int64
+	config.DB.Model(&models.Market{}).Where("symbol = ?", symbol).Count(&count)
+	if count > 0 {
+		return ErrMarketExists
+	}
+	if err := config.DB.Create(m).Error; err != nil {
+		return err
+	}
+	if m.Status != models.MarketDelisted {
+		StartMarketStreams([]string{m.Symbol})
+	}
+	return nil
+}
+
+// UpdateMarket saves admin changes. A market relisted from delisted starts streaming again.
+func UpdateMarket(m *models.Market) error {
+	if err := ValidateMarket(m); err != nil {
+		return err
+	}
+	if err := config.DB.Save(m).Error; err != nil {
+		return err
+	}
+	if m.Status != models.MarketDelisted {
+		StartMarketStreams([]string{m.Symbol})
+	}
+	return nil
+}
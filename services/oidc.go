@@ -0,0 +1,462 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrOIDCUnknownProvider = errors.New("unknown OIDC provider")
+	ErrOIDCState           = errors.New("invalid or expired login state")
+	ErrOIDCEmailUnverified = errors.New("provider did not return a verified email")
+	ErrOIDCLinkUnverified  = errors.New("an account with this email exists but its email is not verified; log in with your password and verify it first")
+)
+
+// OIDCStateTTL is how long a login started with OIDCAuthURL can be completed
+const OIDCStateTTL = 10 * time.Minute
+
+// OIDCProvider is one configured identity provider
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+var defaultIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+	"apple":  "https://appleid.apple.com",
+}
+
+// OIDCProviders returns the providers enabled via OIDC_PROVIDERS (comma-separated),
+// each configured by OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL.
+// Apple's client secret is the pre-signed ES256 JWT Apple requires.
+func OIDCProviders() map[string]OIDCProvider {
+	out := make(map[string]OIDCProvider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		env := func(k string) string { return os.Getenv("OIDC_" + strings.ToUpper(name) + "_" + k) }
+		p := OIDCProvider{
+			Name:         name,
+			Issuer:       env("ISSUER"),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  env("REDIRECT_URL"),
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+		if p.Issuer == "" {
+			p.Issuer = defaultIssuers[name]
+		}
+		if name == "apple" {
+			p.Scopes = []string{"openid", "email"}
+		}
+		if p.Issuer != "" && p.ClientID != "" {
+			out[name] = p
+		}
+	}
+	return out
+}
+
+func GetOIDCProvider(name string) (OIDCProvider, error) {
+	p, ok := OIDCProviders()[name]
+	if !ok {
+		return OIDCProvider{}, ErrOIDCUnknownProvider
+	}
+	return p, nil
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwkSet struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	} `json:"keys"`
+}
+
+var (
+	oidcHTTP  = &http.Client{Timeout: 10 * time.Second}
+	oidcCache = struct {
+		sync.Mutex
+		discovery map[string]*oidcDiscovery
+		keys      map[string]map[string]interface{} // issuer -> kid -> public key
+		fetched   map[string]time.Time
+	}{
+		discovery: map[string]*oidcDiscovery{},
+		keys:      map[string]map[string]interface{}{},
+		fetched:   map[string]time.Time{},
+	}
+)
+
+func getJSON(u string, v interface{}) error {
+	resp, err := oidcHTTP.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func discover(p OIDCProvider) (*oidcDiscovery, error) {
+	oidcCache.Lock()
+	d := oidcCache.discovery[p.Issuer]
+	oidcCache.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	d = &oidcDiscovery{}
+	if err := getJSON(strings.TrimRight(p.Issuer, "/")+"/.well-known/openid-configuration", d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.Issuer {
+		return nil, fmt.Errorf("issuer mismatch: %s != %s", d.Issuer, p.Issuer)
+	}
+	oidcCache.Lock()
+	oidcCache.discovery[p.Issuer] = d
+	oidcCache.Unlock()
+	return d, nil
+}
+
+// providerKey returns the provider's public key for kid, refetching the JWKS
+// (at most once a minute) when the kid is unknown, which covers key rotation.
+func providerKey(p OIDCProvider, kid string) (interface{}, error) {
+	oidcCache.Lock()
+	key := oidcCache.keys[p.Issuer][kid]
+	stale := time.Since(oidcCache.fetched[p.Issuer]) > time.Minute
+	oidcCache.Unlock()
+	if key != nil {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+
+	d, err := discover(p)
+	if err != nil {
+		return nil, err
+	}
+	var set jwkSet
+	if err := getJSON(d.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	b64 := base64.RawURLEncoding
+	for _, k := range set.Keys {
+		switch k.Kty {
+		case "RSA":
+			n, err1 := b64.DecodeString(k.N)
+			e, err2 := b64.DecodeString(k.E)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			x, err1 := b64.DecodeString(k.X)
+			y, err2 := b64.DecodeString(k.Y)
+			if err1 != nil || err2 != nil || k.Crv != "P-256" {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	oidcCache.Lock()
+	oidcCache.keys[p.Issuer] = keys
+	oidcCache.fetched[p.Issuer] = time.Now()
+	oidcCache.Unlock()
+
+	if key = keys[kid]; key == nil {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+// OIDCStateStore keeps the logins started by OIDCAuthURL until their callback
+type OIDCStateStore interface {
+	Save(st *models.OIDCState) error
+	// Take removes and returns a state, so each can be used only once
+	Take(state, provider string) (*models.OIDCState, error)
+}
+
+// DBOIDCStates keeps login states in the oidc_states table
+type DBOIDCStates struct{}
+
+func (DBOIDCStates) Save(st *models.OIDCState) error {
+	return config.DB.Create(st).Error
+}
+
+func (DBOIDCStates) Take(state, provider string) (*models.OIDCState, error) {
+	var st models.OIDCState
+	res := config.DB.Clauses(clause.Returning{}).Where("state = ? AND provider = ?", state, provider).Delete(&st)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrOIDCState
+	}
+	return &st, nil
+}
+
+var oidcStates OIDCStateStore = DBOIDCStates{}
+
+// SetOIDCStateStore replaces where login states are kept (DBOIDCStates by default)
+func SetOIDCStateStore(s OIDCStateStore) {
+	oidcStates = s
+}
+
+func randomToken(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// OIDCAuthURL persists a fresh state/nonce/PKCE verifier and returns the
+// provider authorization URL to redirect the browser to, and the state, which
+// the caller binds to the browser. loginHint, if set, is passed through as the
+// standard login_hint parameter.
+func OIDCAuthURL(p OIDCProvider, loginHint string) (string, string, error) {
+	d, err := discover(p)
+	if err != nil {
+		return "", "", err
+	}
+
+	st := models.OIDCState{
+		State:        randomToken(24),
+		Provider:     p.Name,
+		Nonce:        randomToken(24),
+		CodeVerifier: randomToken(48),
+		RedirectURL:  p.RedirectURL,
+		ExpiresAt:    time.Now().Add(OIDCStateTTL),
+	}
+	if err := oidcStates.Save(&st); err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(st.CodeVerifier))
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", st.State)
+	q.Set("nonce", st.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	if loginHint != "" {
+		q.Set("login_hint", loginHint)
+	}
+	if p.Name == "apple" {
+		q.Set("response_mode", "form_post")
+	}
+	return d.AuthorizationEndpoint + "?" + q.Encode(), st.State, nil
+}
+
+// OIDCClaims are the ID token claims we rely on
+type OIDCClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // bool, or "true" string from Apple
+	Name          string      `json:"name"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+func (c *OIDCClaims) emailVerified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// OIDCExchange consumes the login state, redeems the code and verifies the
+// returned ID token (signature, issuer, audience, expiry, nonce).
+func OIDCExchange(p OIDCProvider, state, code string) (*OIDCClaims, error) {
+	st, err := oidcStates.Take(state, p.Name)
+	if err != nil {
+		return nil, ErrOIDCState
+	}
+	if time.Now().After(st.ExpiresAt) {
+		return nil, ErrOIDCState
+	}
+
+	d, err := discover(p)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", st.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", st.CodeVerifier)
+	resp, err := oidcHTTP.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tok struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || tok.IDToken == "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", resp.Status, tok.Error)
+	}
+
+	claims := &OIDCClaims{}
+	_, err = jwt.ParseWithClaims(tok.IDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+		}
+		kid, _ := t.Header["kid"].(string)
+		return providerKey(p, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !claims.VerifyIssuer(p.Issuer, true) || !claims.VerifyAudience(p.ClientID, true) {
+		return nil, errors.New("id token issuer or audience mismatch")
+	}
+	if claims.Nonce != st.Nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id token has no subject")
+	}
+	return claims, nil
+}
+
+var usernameSanitizer = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// FindOrCreateOIDCUser resolves the local user for an external identity:
+// an existing link wins, then a user with the same email is linked if both the
+// provider and the local account have verified it, otherwise a new account is
+// created. An unverified local account with the email is never linked: whoever
+// registered it may not own the address, and would keep access through its
+// password. Returns created=true for new accounts.
+func FindOrCreateOIDCUser(provider string, claims *OIDCClaims) (*models.User, bool, error) {
+	var identity models.UserIdentity
+	err := config.DB.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		var user models.User
+		if err := config.DB.First(&user, identity.UserID).Error; err != nil {
+			return nil, false, err
+		}
+		return &user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	// Linking by email is only safe if the provider vouches for it
+	if claims.Email == "" || !claims.emailVerified() {
+		return nil, false, ErrOIDCEmailUnverified
+	}
+
+	var user models.User
+	created := false
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("LOWER(email) = LOWER(?)", claims.Email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = models.User{
+				Username:      uniqueUsername(tx, claims.Email),
+				Email:         claims.Email,
+				EmailVerified: true,
+				// No usable password: bcrypt of random bytes nobody knows
+				Password: mustHash(randomToken(32)),
+				Role:     models.RoleUser,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			created = true
+		} else if err != nil {
+			return err
+		} else if !user.EmailVerified {
+			return ErrOIDCLinkUnverified
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &user, created, nil
+}
+
+func uniqueUsername(tx *gorm.DB, email string) string {
+	base := usernameSanitizer.ReplaceAllString(strings.Split(email, "@")[0], "")
+	if base == "" {
+		base = "user"
+	}
+	name := base
+	for {
+		var n int64
+		tx.Model(&models.User{}).Where("username = ?", name).Count(&n)
+		if n == 0 {
+			return name
+		}
+		name = fmt.Sprintf("%s%s", base, randomToken(3))
+	}
+}
+
+func mustHash(s string) string {
+	h, _ := utils.HashPassword(s)
+	return h
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/binance"
+)
+
+const (
+	depthSnapshotLimit = 1000                   // levels fetched when (re)syncing a book
+	maxBufferedDepth   = 1000                   // diff events held while a snapshot loads
+	DepthPushInterval  = 250 * time.Millisecond // how often /trading clients get depth
+	MaxDepthLevels     = 100
+)
+
+// DepthSnapshot is the top of a symbol's order book, each level [price, quantity]
+type DepthSnapshot struct {
+	Symbol       string       `json:"symbol"`
+	LastUpdateID int64        `json:"last_update_id"`
+	Bids         [][2]float64 `json:"bids"` // best (highest) first
+	Asks         [][2]float64 `json:"asks"` // best (lowest) first
+}
+
+// depthEvent is one message from the <symbol>@depth diff stream
+type depthEvent struct {
+	FirstID int64                `json:"U"`
+	FinalID int64                `json:"u"`
+	Bids    []binance.PriceLevel `json:"b"`
+	Asks    []binance.PriceLevel `json:"a"`
+}
+
+// orderBook is a local copy of an exchange book, kept in sync by applying
+// diff events on top of a REST snapshot in update-ID order.
+type orderBook struct {
+	bids, asks   map[float64]float64
+	lastUpdateID int64
+	synced       bool
+	syncing      bool // a snapshot request is in flight
+	buffer       []depthEvent
+	snapshot     *binance.DepthSnapshot // newer than every diff so far; kept until the stream reaches it
+	changed      bool                   // since the last push
+}
+
+var orderBooks = struct {
+	sync.Mutex
+	m map[string]*orderBook
+}{m: map[string]*orderBook{}}
+
+func bookFor(symbol string) *orderBook {
+	b := orderBooks.m[symbol]
+	if b == nil {
+		b = &orderBook{}
+		orderBooks.m[symbol] = b
+	}
+	return b
+}
+
+// StartOrderBooks keeps a local order book per streamed symbol and pushes
+// throttled top-of-book snapshots to /trading clients that asked for depth.
+func StartOrderBooks() {
+	// Diffs from a previous connection can't be trusted to continue the sequence
+	OnMarketConnect(func(symbol string) {
+		orderBooks.Lock()
+		b := bookFor(symbol)
+		b.synced, b.buffer = false, nil
+		orderBooks.Unlock()
+	})
+
+	go func() {
+		for range time.Tick(DepthPushInterval) {
+			pushDepth()
+		}
+	}()
+}
+
+func handleDepthMessage(symbol string, msg []byte) {
+	var ev depthEvent
+	if err := json.Unmarshal(msg, &ev); err != nil {
+		return
+	}
+
+	orderBooks.Lock()
+	defer orderBooks.Unlock()
+	b := bookFor(symbol)
+
+	if !b.synced {
+		if len(b.buffer) < maxBufferedDepth {
+			b.buffer = append(b.buffer, ev)
+		}
+		if b.snapshot != nil && b.replay(b.snapshot) {
+			return // applied, or the stream still hasn't reached it
+		}
+		if !b.syncing {
+			b.syncing = true
+			go syncOrderBook(symbol)
+		}
+		return
+	}
+	if ev.FinalID <= b.lastUpdateID {
+		return
+	}
+	if ev.FirstID != b.lastUpdateID+1 {
+		// Missed an event; the book is no longer reliable
+		log.Printf("%s order book gap (%d after %d), resyncing", symbol, ev.FirstID, b.lastUpdateID)
+		b.synced, b.buffer, b.syncing = false, []depthEvent{ev}, true
+		go syncOrderBook(symbol)
+		return
+	}
+	b.apply(ev)
+}
+
+// syncOrderBook loads a snapshot and replays the buffered diffs on top of it,
+// retrying until the snapshot is usable: applied, or kept for the stream to
+// catch up with.
+func syncOrderBook(symbol string) {
+	for attempt := 0; ; attempt++ {
+		// Snapshots are heavy on request weight, so back off while they keep failing
+		if attempt > 0 {
+			time.Sleep(time.Duration(min(attempt, 30)) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		snap, err := binanceREST().Depth(ctx, symbol, depthSnapshotLimit)
+		cancel()
+		if err != nil {
+			log.Printf("%s depth snapshot: %v", symbol, err)
+			continue
+		}
+
+		orderBooks.Lock()
+		b := bookFor(symbol)
+		if b.replay(snap) {
+			b.syncing = false
+			orderBooks.Unlock()
+			return
+		}
+		orderBooks.Unlock()
+	}
+}
+
+// replay applies snap and the buffered events, as Binance documents: events
+// the snapshot already covers are dropped, and the first remaining one must
+// have U <= lastUpdateId+1 <= u. A snapshot newer than every buffered event is
+// kept and replayed again as events arrive. It fails, so a new snapshot is
+// needed, when the snapshot is older than the first buffered event or the
+// buffer has a gap.
+func (b *orderBook) replay(snap *binance.DepthSnapshot) bool {
+	var pending []depthEvent
+	for _, ev := range b.buffer {
+		if ev.FinalID > snap.LastUpdateID {
+			pending = append(pending, ev)
+		}
+	}
+	b.buffer = pending
+	if len(pending) == 0 {
+		b.snapshot = snap // the stream hasn't caught up with it yet
+		return true
+	}
+	b.snapshot = nil
+	if pending[0].FirstID > snap.LastUpdateID+1 {
+		return false
+	}
+	for i := 1; i < len(pending); i++ {
+		if pending[i].FirstID != pending[i-1].FinalID+1 {
+			b.buffer = nil // the buffer itself has a gap; start over
+			return false
+		}
+	}
+
+	b.bids = make(map[float64]float64, len(snap.Bids))
+	b.asks = make(map[float64]float64, len(snap.Asks))
+	for _, l := range snap.Bids {
+		b.bids[l.Price] = l.Quantity
+	}
+	for _, l := range snap.Asks {
+		b.asks[l.Price] = l.Quantity
+	}
+	b.lastUpdateID = snap.LastUpdateID
+	for _, ev := range pending {
+		b.apply(ev)
+	}
+	b.buffer, b.synced = nil, true
+	return true
+}
+
+func (b *orderBook) apply(ev depthEvent) {
+	for _, l := range ev.Bids {
+		setLevel(b.bids, l)
+	}
+	for _, l := range ev.Asks {
+		setLevel(b.asks, l)
+	}
+	b.lastUpdateID = ev.FinalID
+	b.changed = true
+}
+
+func setLevel(side map[float64]float64, l binance.PriceLevel) {
+	if l.Quantity == 0 {
+		delete(side, l.Price)
+	} else {
+		side[l.Price] = l.Quantity
+	}
+}
+
+func topLevels(side map[float64]float64, n int, desc bool) [][2]float64 {
+	prices := make([]float64, 0, len(side))
+	for p := range side {
+		prices = append(prices, p)
+	}
+	if desc {
+		sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+	} else {
+		sort.Float64s(prices)
+	}
+	if len(prices) > n {
+		prices = prices[:n]
+	}
+	levels := make([][2]float64, len(prices))
+	for i, p := range prices {
+		levels[i] = [2]float64{p, side[p]}
+	}
+	return levels
+}
+
+// OrderBookDepth returns the top levels of symbol's book, or false while it
+// is not in sync.
+func OrderBookDepth(symbol string, levels int) (*DepthSnapshot, bool) {
+	orderBooks.Lock()
+	defer orderBooks.Unlock()
+	b := orderBooks.m[symbol]
+	if b == nil || !b.synced {
+		return nil, false
+	}
+	return &DepthSnapshot{
+		Symbol:       symbol,
+		LastUpdateID: b.lastUpdateID,
+		Bids:         topLevels(b.bids, levels, true),
+		Asks:         topLevels(b.asks, levels, false),
+	}, true
+}
+
+// pushDepth sends each depth subscriber the top of its symbol's book, if it
+// changed since the last push.
+func pushDepth() {
+	want := depthSubscriptions()
+	for symbol, levels := range want {
+		orderBooks.Lock()
+		b := orderBooks.m[symbol]
+		changed := b != nil && b.synced && b.changed
+		if changed {
+			b.changed = false
+		}
+		orderBooks.Unlock()
+		if !changed {
+			continue
+		}
+		if snap, ok := OrderBookDepth(symbol, levels); ok {
+			sendDepth(snap)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/solchef/crypto-options-backend/binance"
+)
+
+func depthDiff(first, final int64, bidPrice, bidQty float64) depthEvent {
+	return depthEvent{FirstID: first, FinalID: final, Bids: []binance.PriceLevel{{Price: bidPrice, Quantity: bidQty}}}
+}
+
+func TestOrderBookReplay(t *testing.T) {
+	snap := &binance.DepthSnapshot{
+		LastUpdateID: 100,
+		Bids:         []binance.PriceLevel{{Price: 10, Quantity: 1}},
+		Asks:         []binance.PriceLevel{{Price: 11, Quantity: 1}},
+	}
+	tests := []struct {
+		name       string
+		buffer     []depthEvent
+		wantOK     bool
+		wantSynced bool
+		wantKept   bool
+		wantLast   int64
+		wantBid10  float64
+	}{
+		{
+			name:       "diff straddles the snapshot",
+			buffer:     []depthEvent{depthDiff(90, 95, 10, 5), depthDiff(96, 103, 10, 2), depthDiff(104, 104, 10, 3)},
+			wantOK:     true,
+			wantSynced: true,
+			wantLast:   104,
+			wantBid10:  3,
+		},
+		{
+			name:     "snapshot newer than every diff is kept",
+			buffer:   []depthEvent{depthDiff(90, 95, 10, 5), depthDiff(96, 100, 10, 2)},
+			wantOK:   true,
+			wantKept: true,
+		},
+		{
+			name:   "snapshot older than the first diff",
+			buffer: []depthEvent{depthDiff(102, 105, 10, 2)},
+		},
+		{
+			name:   "gap in the buffer",
+			buffer: []depthEvent{depthDiff(99, 101, 10, 2), depthDiff(103, 104, 10, 3)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &orderBook{buffer: tt.buffer}
+			ok := b.replay(snap)
+			if ok != tt.wantOK || b.synced != tt.wantSynced || (b.snapshot != nil) != tt.wantKept {
+				t.Fatalf("replay = %v, synced %v, kept %v", ok, b.synced, b.snapshot != nil)
+			}
+			if tt.wantSynced && (b.lastUpdateID != tt.wantLast || b.bids[10] != tt.wantBid10) {
+				t.Fatalf("last update %d, bid 10 qty %v", b.lastUpdateID, b.bids[10])
+			}
+		})
+	}
+}
+
+func TestOrderBookKeptSnapshot(t *testing.T) {
+	// A snapshot fetched ahead of the stream is applied once it catches up,
+	// without another fetch
+	b := bookFor("TESTKEPTUSDT")
+	b.snapshot = &binance.DepthSnapshot{LastUpdateID: 100, Bids: []binance.PriceLevel{{Price: 10, Quantity: 1}}}
+
+	for _, ev := range []string{
+		`{"U":97,"u":99,"b":[["10","4"]],"a":[]}`,
+		`{"U":100,"u":100,"b":[["10","5"]],"a":[]}`,
+	} {
+		handleDepthMessage("TESTKEPTUSDT", []byte(ev))
+		if b.synced || b.syncing || b.snapshot == nil {
+			t.Fatalf("after %s: synced %v, syncing %v, kept %v", ev, b.synced, b.syncing, b.snapshot != nil)
+		}
+	}
+	handleDepthMessage("TESTKEPTUSDT", []byte(`{"U":101,"u":102,"b":[["10","2"],["9","1"]],"a":[]}`))
+	if !b.synced || b.syncing || b.snapshot != nil {
+		t.Fatalf("synced %v, syncing %v, kept %v", b.synced, b.syncing, b.snapshot != nil)
+	}
+	if b.lastUpdateID != 102 || b.bids[10] != 2 || b.bids[9] != 1 {
+		t.Fatalf("last update %d, bids %v", b.lastUpdateID, b.bids)
+	}
+}
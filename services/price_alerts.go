@@ -0,0 +1,430 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxActiveAlerts  = 50
+	MinAlertWindow   = time.Minute
+	MaxAlertWindow   = 24 * time.Hour
+	alertRepeatAfter = time.Minute // recurring alerts fire at most this often
+)
+
+var (
+	ErrAlertNotFound = errors.New("price alert not found")
+	ErrAlertInvalid  = errors.New("invalid price alert")
+	ErrAlertLimit    = fmt.Errorf("at most %d active price alerts", MaxActiveAlerts)
+)
+
+// ValidateAlert checks an alert's condition and the fields it uses, and
+// clears the ones it doesn't.
+func ValidateAlert(a *models.PriceAlert) error {
+	switch a.Condition {
+	case models.AlertAbove, models.AlertBelow:
+		if a.Price <= 0 {
+			return fmt.Errorf("%w: price must be positive", ErrAlertInvalid)
+		}
+		a.Percent, a.WindowSeconds = 0, 0
+	case models.AlertMove:
+		window := time.Duration(a.WindowSeconds) * time.Second
+		if a.Percent <= 0 || a.Percent > 100 {
+			return fmt.Errorf("%w: percent must be between 0 and 100", ErrAlertInvalid)
+		}
+		if window < MinAlertWindow || window > MaxAlertWindow {
+			return fmt.Errorf("%w: window_seconds must be between 60 and 86400", ErrAlertInvalid)
+		}
+		a.Price = 0
+	default:
+		return fmt.Errorf("%w: condition must be above, below or move", ErrAlertInvalid)
+	}
+	if len(a.Note) > 140 {
+		return fmt.Errorf("%w: note is limited to 140 characters", ErrAlertInvalid)
+	}
+	return nil
+}
+
+// ListAlerts returns the user's alerts, newest first
+func ListAlerts(userID uint) ([]models.PriceAlert, error) {
+	var alerts []models.PriceAlert
+	err := config.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&alerts).Error
+	return alerts, err
+}
+
+// GetAlert loads one of the user's alerts
+func GetAlert(userID uint, id string) (*models.PriceAlert, error) {
+	var a models.PriceAlert
+	err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&a).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAlertNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAlert stores a new alert on a listed market and starts watching it
+func CreateAlert(a *models.PriceAlert) error {
+	market, err := GetMarket(a.Symbol)
+	if err != nil {
+		return err
+	}
+	if market.Status == models.MarketDelisted {
+		return ErrMarketNotFound
+	}
+	a.Symbol = market.Symbol
+	a.Active = true
+	if err := ValidateAlert(a); err != nil {
+		return err
+	}
+	if err := checkAlertLimit(a.UserID, 0); err != nil {
+		return err
+	}
+	if err := config.DB.Create(a).Error; err != nil {
+		return err
+	}
+	indexAlert(*a, true)
+	return nil
+}
+
+// UpdateAlert saves changes to an alert. Reactivating or changing an alert
+// checks it against the current price again.
+func UpdateAlert(a *models.PriceAlert) error {
+	if err := ValidateAlert(a); err != nil {
+		return err
+	}
+	if a.Active {
+		if err := checkAlertLimit(a.UserID, a.ID); err != nil {
+			return err
+		}
+	}
+	// Only the user's settings; trigger fields are owned by the evaluator
+	err := config.DB.Model(a).Select("condition", "price", "percent", "window_seconds", "recurring", "note", "active").Updates(a).Error
+	if err != nil {
+		return err
+	}
+	unindexAlert(a.Symbol, a.ID)
+	if a.Active {
+		indexAlert(*a, true)
+	}
+	return nil
+}
+
+// DeleteAlert removes one of the user's alerts
+func DeleteAlert(userID uint, id string) error {
+	a, err := GetAlert(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := config.DB.Delete(a).Error; err != nil {
+		return err
+	}
+	unindexAlert(a.Symbol, a.ID)
+	return nil
+}
+
+func checkAlertLimit(userID, except uint) error {
+	var n int64
+	if err := config.DB.Model(&models.PriceAlert{}).
+		Where("user_id = ? AND active = ? AND id <> ?", userID, true, except).Count(&n).Error; err != nil {
+		return err
+	}
+	if n >= MaxActiveAlerts {
+		return ErrAlertLimit
+	}
+	return nil
+}
+
+// alertEntry is an active alert as held by the evaluator
+type alertEntry struct {
+	alert models.PriceAlert
+	armed bool // move alerts: cleared on firing until the move falls back under the threshold
+}
+
+type priceSample struct {
+	at    time.Time
+	price float64
+}
+
+// symbolAlerts indexes one symbol's active alerts. Level alerts are sorted by
+// price so a tick only visits the levels crossed since the previous tick.
+type symbolAlerts struct {
+	above, below []*alertEntry
+	moves        []*alertEntry
+	fresh        []*alertEntry // checked against the next price outright, then indexed
+	last         float64
+	samples      []priceSample // one per second, for move windows
+}
+
+var alertBook = struct {
+	sync.Mutex
+	m map[string]*symbolAlerts
+}{m: map[string]*symbolAlerts{}}
+
+// alertFire is a triggered alert waiting to be recorded and delivered
+type alertFire struct {
+	alert  models.PriceAlert
+	price  float64
+	change float64 // percent, move alerts only
+	at     time.Time
+}
+
+var alertFires = make(chan alertFire, 1000)
+
+// StartPriceAlerts loads active alerts and evaluates them on every tick.
+// Alerts are delivered over the user's WebSocket and, if they opted in to
+// price alert emails, by mail.
+func StartPriceAlerts() {
+	var alerts []models.PriceAlert
+	if err := config.DB.Where("active = ?", true).Find(&alerts).Error; err != nil {
+		log.Println("load price alerts:", err)
+	}
+	for _, a := range alerts {
+		// A recurring alert that has fired waits for a fresh crossing rather
+		// than repeating just because the service restarted
+		indexAlert(a, !a.Recurring || a.TriggeredAt == nil)
+	}
+
+	OnMarketTick(evaluateAlerts)
+	go func() {
+		for f := range alertFires {
+			deliverAlert(f)
+		}
+	}()
+}
+
+func indexAlert(a models.PriceAlert, fresh bool) {
+	alertBook.Lock()
+	defer alertBook.Unlock()
+	s := alertBook.m[a.Symbol]
+	if s == nil {
+		s = &symbolAlerts{}
+		alertBook.m[a.Symbol] = s
+	}
+	e := &alertEntry{alert: a, armed: true}
+	switch {
+	case a.Condition == models.AlertMove:
+		s.moves = append(s.moves, e)
+	case fresh:
+		s.fresh = append(s.fresh, e)
+	default:
+		s.insertLevel(e)
+	}
+}
+
+func unindexAlert(symbol string, id uint) {
+	alertBook.Lock()
+	defer alertBook.Unlock()
+	if s := alertBook.m[symbol]; s != nil {
+		s.remove(id)
+	}
+}
+
+func (s *symbolAlerts) insertLevel(e *alertEntry) {
+	list := &s.above
+	if e.alert.Condition == models.AlertBelow {
+		list = &s.below
+	}
+	i := sort.Search(len(*list), func(i int) bool { return (*list)[i].alert.Price > e.alert.Price })
+	*list = append(*list, nil)
+	copy((*list)[i+1:], (*list)[i:])
+	(*list)[i] = e
+}
+
+func (s *symbolAlerts) remove(id uint) {
+	for _, list := range []*[]*alertEntry{&s.above, &s.below, &s.moves, &s.fresh} {
+		for i, e := range *list {
+			if e.alert.ID == id {
+				*list = append((*list)[:i], (*list)[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func evaluateAlerts(t MarketTick) {
+	alertBook.Lock()
+	defer alertBook.Unlock()
+	s := alertBook.m[t.Symbol]
+	if s == nil {
+		return
+	}
+
+	var fired []*alertEntry
+	if s.last > 0 {
+		switch {
+		case t.Price > s.last:
+			// Above levels in (last, price]
+			i := sort.Search(len(s.above), func(i int) bool { return s.above[i].alert.Price > s.last })
+			for ; i < len(s.above) && s.above[i].alert.Price <= t.Price; i++ {
+				fired = append(fired, s.above[i])
+			}
+		case t.Price < s.last:
+			// Below levels in [price, last)
+			i := sort.Search(len(s.below), func(i int) bool { return s.below[i].alert.Price >= t.Price })
+			for ; i < len(s.below) && s.below[i].alert.Price < s.last; i++ {
+				fired = append(fired, s.below[i])
+			}
+		}
+	}
+	for _, e := range s.fresh {
+		if (e.alert.Condition == models.AlertAbove && t.Price >= e.alert.Price) ||
+			(e.alert.Condition == models.AlertBelow && t.Price <= e.alert.Price) {
+			fired = append(fired, e)
+		}
+		s.insertLevel(e)
+	}
+	s.fresh = nil
+	s.last = t.Price
+
+	for _, e := range fired {
+		s.fire(e, t, 0)
+	}
+	s.evaluateMoves(t)
+}
+
+// evaluateMoves records the price once per second and checks move alerts
+// against the price their window ago. Until a full window of history has been
+// seen, move alerts can't fire.
+func (s *symbolAlerts) evaluateMoves(t MarketTick) {
+	if len(s.moves) == 0 {
+		s.samples = nil
+		return
+	}
+	second := t.Time.Truncate(time.Second)
+	if n := len(s.samples); n > 0 && s.samples[n-1].at.Equal(second) {
+		s.samples[n-1].price = t.Price
+	} else {
+		s.samples = append(s.samples, priceSample{second, t.Price})
+	}
+	longest := time.Duration(0)
+	for _, e := range s.moves {
+		longest = max(longest, time.Duration(e.alert.WindowSeconds)*time.Second)
+	}
+	drop := sort.Search(len(s.samples), func(i int) bool { return !s.samples[i].at.Before(second.Add(-longest)) })
+	if drop > 0 {
+		s.samples = append(s.samples[:0], s.samples[drop-1:]...)
+	}
+
+	for _, e := range append([]*alertEntry(nil), s.moves...) {
+		from := second.Add(-time.Duration(e.alert.WindowSeconds) * time.Second)
+		if s.samples[0].at.After(from) {
+			continue
+		}
+		i := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].at.After(from) }) - 1
+		ref := s.samples[i].price
+		change := (t.Price - ref) / ref * 100
+		if change >= e.alert.Percent || -change >= e.alert.Percent {
+			if e.armed {
+				s.fire(e, t, change)
+			}
+		} else {
+			e.armed = true
+		}
+	}
+}
+
+// fire hands e to the delivery worker. One-shot alerts leave the index at
+// once; recurring ones stay unless they fired too recently.
+func (s *symbolAlerts) fire(e *alertEntry, t MarketTick, change float64) {
+	if e.alert.Recurring && e.alert.TriggeredAt != nil && t.Time.Sub(*e.alert.TriggeredAt) < alertRepeatAfter {
+		return
+	}
+	e.armed = false
+	at := t.Time
+	e.alert.TriggeredAt = &at
+	if !e.alert.Recurring {
+		s.remove(e.alert.ID)
+	}
+	select {
+	case alertFires <- alertFire{alert: e.alert, price: t.Price, change: change, at: t.Time}:
+	default:
+		log.Printf("price alert %d dropped: delivery queue full", e.alert.ID)
+	}
+}
+
+// deliverAlert records a trigger and notifies the user. Alerts deleted or
+// deactivated since firing are skipped.
+func deliverAlert(f alertFire) {
+	a := f.alert
+	updates := map[string]interface{}{
+		"triggered_at":  f.at,
+		"trigger_price": f.price,
+		"trigger_count": gorm.Expr("trigger_count + 1"),
+	}
+	if !a.Recurring {
+		updates["active"] = false
+	}
+	res := config.DB.Model(&models.PriceAlert{}).Where("id = ? AND active = ?", a.ID, true).Updates(updates)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+
+	data := map[string]interface{}{
+		"alert_id":      a.ID,
+		"symbol":        a.Symbol,
+		"condition":     a.Condition,
+		"trigger_price": f.price,
+		"recurring":     a.Recurring,
+		"note":          a.Note,
+		"message":       alertSummary(a, f.price, f.change),
+	}
+	if a.Condition == models.AlertMove {
+		data["percent"], data["window_seconds"], data["change_pct"] = a.Percent, a.WindowSeconds, f.change
+	} else {
+		data["price"] = a.Price
+	}
+	if msg, err := json.Marshal(WSMessage{Type: "price_alert", Data: data, Timestamp: f.at.UnixMilli()}); err == nil {
+		config.WSHub.SendToUser(a.UserID, string(msg))
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, a.UserID).Error; err != nil || !user.Notifications.PriceAlerts || !user.EmailVerified {
+		return
+	}
+	err := SendTemplate(user.Email, "price_alert", map[string]interface{}{
+		"Username": user.Username,
+		"Summary":  alertSummary(a, f.price, f.change),
+		"Note":     a.Note,
+		"Once":     !a.Recurring,
+		"Link":     AppURL() + "/alerts",
+	})
+	if err != nil {
+		log.Printf("price alert %d email: %v", a.ID, err)
+	}
+}
+
+// alertSummary describes a trigger in one line, e.g. "BTCUSDT rose above 60000 (now 60012.5)"
+func alertSummary(a models.PriceAlert, price, change float64) string {
+	now := strconv.FormatFloat(price, 'f', -1, 64)
+	switch a.Condition {
+	case models.AlertAbove:
+		return fmt.Sprintf("%s rose above %s (now %s)", a.Symbol, strconv.FormatFloat(a.Price, 'f', -1, 64), now)
+	case models.AlertBelow:
+		return fmt.Sprintf("%s fell below %s (now %s)", a.Symbol, strconv.FormatFloat(a.Price, 'f', -1, 64), now)
+	}
+	return fmt.Sprintf("%s moved %+.2f%% in %s (now %s)", a.Symbol, change, alertWindow(a.WindowSeconds), now)
+}
+
+// alertWindow writes a move window in its largest whole unit: 2h, 90m, 45s
+func alertWindow(seconds int) string {
+	switch {
+	case seconds%3600 == 0:
+		return fmt.Sprintf("%dh", seconds/3600)
+	case seconds%60 == 0:
+		return fmt.Sprintf("%dm", seconds/60)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
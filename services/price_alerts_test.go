@@ -0,0 +1,216 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+func TestAlertSummaryWindow(t *testing.T) {
+	tests := []struct {
+		seconds int
+		want    string
+	}{
+		{60, "BTCUSDT moved +2.50% in 1m (now 61500)"},
+		{90, "BTCUSDT moved +2.50% in 90s (now 61500)"},
+		{600, "BTCUSDT moved +2.50% in 10m (now 61500)"},
+		{1800, "BTCUSDT moved +2.50% in 30m (now 61500)"},
+		{3600, "BTCUSDT moved +2.50% in 1h (now 61500)"},
+		{5400, "BTCUSDT moved +2.50% in 90m (now 61500)"},
+		{86400, "BTCUSDT moved +2.50% in 24h (now 61500)"},
+	}
+	for _, tt := range tests {
+		a := models.PriceAlert{Symbol: "BTCUSDT", Condition: models.AlertMove, Percent: 2, WindowSeconds: tt.seconds}
+		if got := alertSummary(a, 61500, 2.5); got != tt.want {
+			t.Errorf("window %ds: %q, want %q", tt.seconds, got, tt.want)
+		}
+	}
+}
+
+type alertTick struct {
+	second int
+	price  float64
+	fired  []uint // IDs of the alerts expected to fire on this tick, in order
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	level := func(id uint, cond string, price float64) models.PriceAlert {
+		return models.PriceAlert{ID: id, Symbol: "TESTUSDT", Condition: cond, Price: price}
+	}
+	recurring := func(a models.PriceAlert) models.PriceAlert {
+		a.Recurring = true
+		return a
+	}
+	move := func(id uint, percent float64, window int) models.PriceAlert {
+		return models.PriceAlert{ID: id, Symbol: "TESTUSDT", Condition: models.AlertMove, Percent: percent, WindowSeconds: window}
+	}
+	longAgo := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		alerts []models.PriceAlert
+		// restored alerts are indexed as after a restart of a recurring alert
+		// that has fired: they wait for a crossing instead of checking the
+		// first price outright
+		restored []models.PriceAlert
+		ticks    []alertTick
+	}{
+		{
+			name:   "above levels fire as they are crossed",
+			alerts: []models.PriceAlert{level(1, models.AlertAbove, 100), level(2, models.AlertAbove, 105), level(3, models.AlertAbove, 110)},
+			ticks: []alertTick{
+				{0, 95, nil}, {1, 101, []uint{1}}, {2, 108, []uint{2}}, {3, 108, nil}, {4, 112, []uint{3}},
+			},
+		},
+		{
+			name:   "below levels fire as they are crossed",
+			alerts: []models.PriceAlert{level(1, models.AlertBelow, 90), level(2, models.AlertBelow, 95)},
+			ticks: []alertTick{
+				{0, 100, nil}, {1, 96, nil}, {2, 94, []uint{2}}, {3, 96, nil}, {4, 89, []uint{1}},
+			},
+		},
+		{
+			name:   "one jump crosses several levels",
+			alerts: []models.PriceAlert{level(3, models.AlertAbove, 102), level(1, models.AlertAbove, 100), level(2, models.AlertAbove, 101), level(4, models.AlertBelow, 98)},
+			ticks: []alertTick{
+				{0, 99, nil}, {1, 103, []uint{1, 2, 3}}, {2, 97, []uint{4}},
+			},
+		},
+		{
+			name:   "touching the level counts",
+			alerts: []models.PriceAlert{level(1, models.AlertAbove, 100), level(2, models.AlertBelow, 98)},
+			ticks: []alertTick{
+				{0, 99, nil}, {1, 100, []uint{1}}, {2, 98, []uint{2}},
+			},
+		},
+		{
+			name:   "fresh alert fires on a price already past its level",
+			alerts: []models.PriceAlert{level(1, models.AlertAbove, 100), level(2, models.AlertBelow, 90)},
+			ticks: []alertTick{
+				{0, 105, []uint{1}}, {1, 106, nil},
+			},
+		},
+		{
+			name: "restored alert waits for a crossing",
+			restored: []models.PriceAlert{func() models.PriceAlert {
+				a := recurring(level(1, models.AlertAbove, 100))
+				a.TriggeredAt = &longAgo
+				return a
+			}()},
+			ticks: []alertTick{
+				{0, 105, nil}, {1, 99, nil}, {2, 101, []uint{1}},
+			},
+		},
+		{
+			name:   "one-shot alert fires once",
+			alerts: []models.PriceAlert{level(1, models.AlertAbove, 100)},
+			ticks: []alertTick{
+				{0, 99, nil}, {1, 101, []uint{1}}, {2, 99, nil}, {3, 101, nil},
+			},
+		},
+		{
+			name:   "recurring alert fires at most once a minute",
+			alerts: []models.PriceAlert{recurring(level(1, models.AlertAbove, 100))},
+			ticks: []alertTick{
+				{0, 99, nil}, {1, 101, []uint{1}}, {2, 99, nil}, {30, 101, nil}, {40, 99, nil}, {61, 101, []uint{1}},
+			},
+		},
+		{
+			name:   "move needs a full window of history",
+			alerts: []models.PriceAlert{move(1, 5, 60)},
+			ticks: []alertTick{
+				{0, 100, nil}, {30, 106, nil}, {59, 106, nil}, {60, 106, []uint{1}}, {120, 100, nil},
+			},
+		},
+		{
+			name:   "move down",
+			alerts: []models.PriceAlert{move(1, 5, 60)},
+			ticks: []alertTick{
+				{0, 100, nil}, {60, 96, nil}, {61, 94, []uint{1}},
+			},
+		},
+		{
+			name:   "move compares with the price one window ago",
+			alerts: []models.PriceAlert{move(1, 5, 60)},
+			ticks: []alertTick{
+				// Climbing slowly never moves 5% within a minute
+				{0, 100, nil}, {30, 102, nil}, {60, 104, nil}, {90, 106, nil}, {120, 108, nil},
+			},
+		},
+		{
+			name:   "recurring move re-arms once the move settles",
+			alerts: []models.PriceAlert{recurring(move(1, 5, 60))},
+			ticks: []alertTick{
+				{0, 100, nil}, {60, 106, []uint{1}},
+				// Still 6% above the minute before: not a new move
+				{61, 106, nil}, {100, 106, nil},
+				// Flat for a window re-arms it
+				{125, 106, nil},
+				{190, 112, []uint{1}},
+			},
+		},
+	}
+
+	start := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alertBook.Lock()
+			alertBook.m = map[string]*symbolAlerts{}
+			alertBook.Unlock()
+			drainAlertFires()
+
+			for _, a := range tt.alerts {
+				indexAlert(a, true)
+			}
+			for _, a := range tt.restored {
+				indexAlert(a, false)
+			}
+			for _, tick := range tt.ticks {
+				evaluateAlerts(MarketTick{Symbol: "TESTUSDT", Price: tick.price, Time: start.Add(time.Duration(tick.second) * time.Second)})
+				got := drainAlertFires()
+				if len(got) != len(tick.fired) {
+					t.Fatalf("tick %ds at %v fired %v, want %v", tick.second, tick.price, got, tick.fired)
+				}
+				for i := range got {
+					if got[i] != tick.fired[i] {
+						t.Fatalf("tick %ds at %v fired %v, want %v", tick.second, tick.price, got, tick.fired)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOneShotAlertLeavesIndex(t *testing.T) {
+	alertBook.Lock()
+	alertBook.m = map[string]*symbolAlerts{}
+	alertBook.Unlock()
+	drainAlertFires()
+
+	indexAlert(models.PriceAlert{ID: 1, Symbol: "TESTUSDT", Condition: models.AlertAbove, Price: 100}, true)
+	indexAlert(models.PriceAlert{ID: 2, Symbol: "TESTUSDT", Condition: models.AlertAbove, Price: 100, Recurring: true}, true)
+	indexAlert(models.PriceAlert{ID: 3, Symbol: "TESTUSDT", Condition: models.AlertMove, Percent: 1, WindowSeconds: 60}, true)
+	at := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	evaluateAlerts(MarketTick{Symbol: "TESTUSDT", Price: 99, Time: at})
+	evaluateAlerts(MarketTick{Symbol: "TESTUSDT", Price: 101, Time: at.Add(time.Minute)})
+	drainAlertFires()
+
+	s := alertBook.m["TESTUSDT"]
+	if len(s.fresh) != 0 || len(s.above) != 1 || s.above[0].alert.ID != 2 || len(s.moves) != 0 {
+		t.Fatalf("index after firing: fresh %d, above %d, moves %d; want only the recurring alert left", len(s.fresh), len(s.above), len(s.moves))
+	}
+}
+
+// drainAlertFires returns the IDs of the alerts queued for delivery so far
+func drainAlertFires() []uint {
+	var ids []uint
+	for {
+		select {
+		case f := <-alertFires:
+			ids = append(ids, f.alert.ID)
+		default:
+			return ids
+		}
+	}
+}
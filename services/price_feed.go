@@ -1,87 +1,213 @@
 package services
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"strconv"
+	"context"
+	"errors"
+	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/solchef/crypto-options-backend/binance"
 	"github.com/solchef/crypto-options-backend/models"
 )
 
+// Candle is one point of the compact line format: open time and close price.
 type Candle struct {
 	Time  string  `json:"time"`
 	Value float64 `json:"value"`
 }
 
+// GetCurrentPriceByAPI fetches the last price straight from Binance's REST API
 func GetCurrentPriceByAPI(symbol string) (float64, error) {
-	url := fmt.Sprintf("https://api.binance.com/api/v3/ticker/price?symbol=%s", symbol)
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
+	return binanceREST().TickerPrice(context.Background(), symbol)
+}
+
+// KlineIntervals are the candle intervals Binance supports, with their length
+var KlineIntervals = map[string]time.Duration{
+	"1s":  time.Second,
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+}
 
-	body, _ := ioutil.ReadAll(resp.Body)
-	var data map[string]string
-	json.Unmarshal(body, &data)
+const (
+	binanceKlineLimit = 1000 // rows per klines request
+	MaxHistoryLimit   = 5000
+)
+
+var (
+	ErrInvalidSymbol   = errors.New("invalid or unknown symbol")
+	ErrInvalidInterval = errors.New("invalid interval")
+	ErrInvalidRange    = errors.New("start must be before end")
+)
 
-	price, _ := strconv.ParseFloat(data["price"], 64)
-	return price, nil
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{5,20}$`)
+
+// HistoryQuery selects candles for GetPriceHistory. Without Start, the range
+// is the Limit candles ending at End (default now).
+type HistoryQuery struct {
+	Symbol   string
+	Interval string
+	Start    *time.Time
+	End      *time.Time
+	Limit    int
 }
 
-func GetPriceHistory(symbol string) ([]models.Candle, error) {
-	// Binance requires uppercase with no spaces
-	symbol = strings.ToUpper(strings.TrimSpace(symbol))
-	fmt.Print(symbol)
-	url := fmt.Sprintf("https://api.binance.com/api/v3/klines?symbol=%s&interval=5m&limit=24", symbol)
+var binanceClient struct {
+	once sync.Once
+	c    *binance.Client
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// binanceREST is the shared REST client for BINANCE_API
+func binanceREST() *binance.Client {
+	binanceClient.once.Do(func() {
+		binanceClient.c = binance.NewClient(os.Getenv("BINANCE_API"))
+	})
+	return binanceClient.c
+}
+
+// NormalizeSymbol upper-cases a symbol and checks it is listed on Binance.
+func NormalizeSymbol(symbol string) (string, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if !symbolPattern.MatchString(symbol) || !binanceSymbolListed(symbol) {
+		return "", ErrInvalidSymbol
 	}
-	defer resp.Body.Close()
+	return symbol, nil
+}
+
+var binanceSymbols = struct {
+	sync.Mutex
+	set     map[string]bool
+	fetched time.Time
+}{}
 
-	body, _ := ioutil.ReadAll(resp.Body)
+// binanceSymbolListed checks symbol against exchangeInfo, refreshed hourly. If
+// Binance can't be reached the format check alone has to do.
+func binanceSymbolListed(symbol string) bool {
+	binanceSymbols.Lock()
+	defer binanceSymbols.Unlock()
 
-	// Check if Binance returned error JSON
-	var errResp map[string]interface{}
-	if json.Unmarshal(body, &errResp) == nil {
-		if msg, ok := errResp["msg"]; ok {
-			fmt.Print(msg)
-			return nil, fmt.Errorf("binance error: %v", errResp)
+	if time.Since(binanceSymbols.fetched) > time.Hour {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		symbols, err := binanceREST().ExchangeSymbols(ctx)
+		cancel()
+		if err == nil && len(symbols) > 0 {
+			set := make(map[string]bool, len(symbols))
+			for _, s := range symbols {
+				set[s.Symbol] = true
+			}
+			binanceSymbols.set = set
+			binanceSymbols.fetched = time.Now()
+		} else {
+			// Retry in a minute rather than on every request
+			binanceSymbols.fetched = time.Now().Add(-time.Hour + time.Minute)
 		}
 	}
+	if binanceSymbols.set == nil {
+		return true
+	}
+	return binanceSymbols.set[symbol]
+}
 
-	// Binance returns array of arrays
-	var raw [][]interface{}
-	if err := json.Unmarshal(body, &raw); err != nil {
+// GetPriceHistory returns OHLCV candles for q, from the local candle store
+// when it covers the range, otherwise from Binance.
+func GetPriceHistory(ctx context.Context, q HistoryQuery) ([]models.Candle, error) {
+	step, ok := KlineIntervals[q.Interval]
+	if !ok {
+		return nil, ErrInvalidInterval
+	}
+	symbol, err := NormalizeSymbol(q.Symbol)
+	if err != nil {
 		return nil, err
 	}
+	if q.Limit <= 0 || q.Limit > MaxHistoryLimit {
+		q.Limit = MaxHistoryLimit
+	}
 
-	candles := make([]models.Candle, len(raw))
-	for i, k := range raw {
-		candles[i] = models.Candle{
-			OpenTime:  int64(k[0].(float64)),
-			Open:      atof(k[1]),
-			High:      atof(k[2]),
-			Low:       atof(k[3]),
-			Close:     atof(k[4]),
-			Volume:    atof(k[5]),
-			CloseTime: int64(k[6].(float64)),
-		}
+	end := time.Now()
+	if q.End != nil {
+		end = *q.End
 	}
+	var start time.Time
+	if q.Start != nil {
+		start = *q.Start
+	} else {
+		start = end.Add(-time.Duration(q.Limit) * step)
+	}
+	if !start.Before(end) {
+		return nil, ErrInvalidRange
+	}
+
+	if candles, ok := storedHistory(symbol, q.Interval, start, end, q.Limit); ok {
+		return candles, nil
+	}
+	return fetchKlines(ctx, symbol, q.Interval, start, end, q.Limit)
+}
 
+// fetchKlines loads up to limit candles with open times in [start, end] from
+// Binance, one 1000-row page at a time.
+func fetchKlines(ctx context.Context, symbol, interval string, start, end time.Time, limit int) ([]models.Candle, error) {
+	step := KlineIntervals[interval]
+	candles := make([]models.Candle, 0, limit)
+	for len(candles) < limit && !start.After(end) {
+		batch := limit - len(candles)
+		if batch > binanceKlineLimit {
+			batch = binanceKlineLimit
+		}
+		klines, err := binanceREST().Klines(ctx, binance.KlinesRequest{
+			Symbol:    symbol,
+			Interval:  interval,
+			StartTime: start,
+			EndTime:   end,
+			Limit:     batch,
+		})
+		if errors.Is(err, binance.ErrInvalidSymbol) {
+			return nil, ErrInvalidSymbol
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range klines {
+			candles = append(candles, models.Candle{
+				OpenTime:  k.OpenTime,
+				Open:      k.Open,
+				High:      k.High,
+				Low:       k.Low,
+				Close:     k.Close,
+				Volume:    k.Volume,
+				CloseTime: k.CloseTime,
+			})
+		}
+		if len(klines) < batch {
+			break // reached the end of available data
+		}
+		start = time.UnixMilli(candles[len(candles)-1].OpenTime).Add(step)
+	}
 	return candles, nil
 }
 
-func atof(v interface{}) float64 {
-	str, ok := v.(string)
-	if !ok {
-		return 0
+// CandleTimeLayout is the timestamp format of the compact line series
+const CandleTimeLayout = "2006-01-02 15:04:05"
+
+// ToLine reduces OHLCV candles to {time, value} close-price points (UTC).
+func ToLine(candles []models.Candle) []Candle {
+	line := make([]Candle, len(candles))
+	for i, k := range candles {
+		line[i] = Candle{
+			Time:  time.UnixMilli(k.OpenTime).UTC().Format(CandleTimeLayout),
+			Value: k.Close,
+		}
 	}
-	val, _ := strconv.ParseFloat(str, 64)
-	return val
+	return line
 }
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrPriceUnavailable = errors.New("no reliable price available")
+
+// Index component statuses
+const (
+	ComponentUsed    = "used"
+	ComponentStale   = "stale"
+	ComponentOutlier = "outlier"
+	ComponentError   = "error"
+)
+
+// IndexComponent is one source's contribution to an index price
+type IndexComponent struct {
+	Source string    `json:"source"`
+	Price  float64   `json:"price,omitempty"`
+	Volume float64   `json:"volume,omitempty"`
+	Time   time.Time `json:"time,omitempty"`
+	Status string    `json:"status"`
+}
+
+// PriceIndex is a composite price across exchanges
+type PriceIndex struct {
+	Symbol     string           `json:"symbol"`
+	Price      float64          `json:"price"`
+	Time       time.Time        `json:"time"`
+	Components []IndexComponent `json:"components"`
+}
+
+var priceSources = struct {
+	sync.RWMutex
+	list []PriceSource
+}{list: []PriceSource{BinanceSource{}}}
+
+// InitPriceIndex sets up the sources named in PRICE_SOURCES. Remote sources
+// are polled in the background for every streamed symbol.
+func InitPriceIndex() {
+	SetPriceSources(priceSourcesFromEnv()...)
+}
+
+// SetPriceSources replaces the sources the index is computed from
+func SetPriceSources(sources ...PriceSource) {
+	priceSources.Lock()
+	priceSources.list = sources
+	priceSources.Unlock()
+}
+
+func envFloat(name string, def float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// IndexStaleAfter is how old a source's quote may be before it is ignored.
+// Override with INDEX_STALE_SECONDS.
+func IndexStaleAfter() time.Duration {
+	return time.Duration(envFloat("INDEX_STALE_SECONDS", 10) * float64(time.Second))
+}
+
+// IndexMaxDeviation is the fraction a quote may stray from the median of all
+// fresh quotes before it is rejected as an outlier. Override with
+// INDEX_MAX_DEVIATION_PCT (percent).
+func IndexMaxDeviation() float64 {
+	return envFloat("INDEX_MAX_DEVIATION_PCT", 0.5) / 100
+}
+
+// IndexMinSources is how many sources must agree for the index to be usable.
+// Override with INDEX_MIN_SOURCES.
+func IndexMinSources() int {
+	if v, err := strconv.Atoi(os.Getenv("INDEX_MIN_SOURCES")); err == nil && v > 0 {
+		return v
+	}
+	return 1
+}
+
+// IndexPrice computes symbol's composite price: quotes older than
+// IndexStaleAfter are dropped, then any further than IndexMaxDeviation from
+// the median, and the rest are averaged weighted by 24h volume. It fails with
+// ErrPriceUnavailable when fewer than IndexMinSources remain.
+func IndexPrice(symbol string) (*PriceIndex, error) {
+	priceSources.RLock()
+	sources := priceSources.list
+	priceSources.RUnlock()
+
+	now := clockNow()
+	idx := &PriceIndex{Symbol: symbol, Time: now, Components: make([]IndexComponent, len(sources))}
+	var fresh []int
+	for i, src := range sources {
+		c := IndexComponent{Source: src.Name()}
+		q, err := src.Quote(symbol)
+		switch {
+		case err != nil:
+			c.Status = ComponentError
+		case now.Sub(q.Time) > IndexStaleAfter():
+			c.Status = ComponentStale
+		default:
+			c.Status = ComponentUsed
+			fresh = append(fresh, i)
+		}
+		if err == nil {
+			c.Price, c.Volume, c.Time = q.Price, q.Volume, q.Time
+		}
+		idx.Components[i] = c
+	}
+	if len(fresh) == 0 || len(fresh) < IndexMinSources() {
+		return idx, ErrPriceUnavailable
+	}
+
+	prices := make([]float64, len(fresh))
+	for n, i := range fresh {
+		prices[n] = idx.Components[i].Price
+	}
+	mid := median(prices)
+
+	var used []IndexComponent
+	for _, i := range fresh {
+		c := &idx.Components[i]
+		if math.Abs(c.Price-mid)/mid > IndexMaxDeviation() {
+			c.Status = ComponentOutlier
+			continue
+		}
+		used = append(used, *c)
+	}
+	if len(used) == 0 || len(used) < IndexMinSources() {
+		return idx, ErrPriceUnavailable
+	}
+
+	var sum, weight float64
+	for _, c := range used {
+		sum += c.Price * c.Volume
+		weight += c.Volume
+	}
+	if weight > 0 {
+		idx.Price = sum / weight
+	} else {
+		// No volume reported anywhere; fall back to the plain median
+		prices = prices[:0]
+		for _, c := range used {
+			prices = append(prices, c.Price)
+		}
+		idx.Price = median(prices)
+	}
+	return idx, nil
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// polledSource wraps a remote source, querying it in the background so
+// IndexPrice never waits on the network. Quote returns the latest result.
+type polledSource struct {
+	PriceSource
+	mu   sync.Mutex
+	last map[string]polledQuote
+}
+
+type polledQuote struct {
+	quote SourceQuote
+	err   error
+}
+
+func pollSource(src PriceSource, every time.Duration) *polledSource {
+	p := &polledSource{PriceSource: src, last: map[string]polledQuote{}}
+	go func() {
+		for {
+			p.poll()
+			time.Sleep(every)
+		}
+	}()
+	return p
+}
+
+func (p *polledSource) poll() {
+	var wg sync.WaitGroup
+	for _, symbol := range streamingSymbols() {
+		wg.Add(1)
+		go func(symbol string) {
+			defer wg.Done()
+			q, err := p.PriceSource.Quote(symbol)
+			recordSourceQuote(p.Name(), symbol, q, err)
+			p.mu.Lock()
+			p.last[symbol] = polledQuote{q, err}
+			p.mu.Unlock()
+		}(symbol)
+	}
+	wg.Wait()
+}
+
+func (p *polledSource) Quote(symbol string) (SourceQuote, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last, ok := p.last[symbol]
+	if !ok {
+		return SourceQuote{}, ErrNoQuote
+	}
+	return last.quote, last.err
+}
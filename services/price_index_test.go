@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type fakeQuote struct {
+	price, volume float64
+	age           time.Duration
+	missing       bool
+}
+
+func TestIndexPrice(t *testing.T) {
+	tests := []struct {
+		name       string
+		minSources string
+		quotes     []fakeQuote
+		want       float64 // 0 when the index should be unavailable
+		statuses   []string
+	}{
+		{
+			name:     "single source",
+			quotes:   []fakeQuote{{price: 100, volume: 5}},
+			want:     100,
+			statuses: []string{ComponentUsed},
+		},
+		{
+			name:     "stale quote ignored",
+			quotes:   []fakeQuote{{price: 100, volume: 1}, {price: 200, volume: 1, age: time.Minute}},
+			want:     100,
+			statuses: []string{ComponentUsed, ComponentStale},
+		},
+		{
+			name:     "missing quote ignored",
+			quotes:   []fakeQuote{{missing: true}, {price: 100, volume: 1}},
+			want:     100,
+			statuses: []string{ComponentError, ComponentUsed},
+		},
+		{
+			name:     "all stale",
+			quotes:   []fakeQuote{{price: 100, volume: 1, age: time.Minute}, {price: 100, volume: 1, age: 11 * time.Second}},
+			statuses: []string{ComponentStale, ComponentStale},
+		},
+		{
+			name:     "outlier rejected around the median",
+			quotes:   []fakeQuote{{price: 100, volume: 1}, {price: 100.2, volume: 1}, {price: 110, volume: 100}},
+			want:     100.1,
+			statuses: []string{ComponentUsed, ComponentUsed, ComponentOutlier},
+		},
+		{
+			name:     "just inside the deviation band",
+			quotes:   []fakeQuote{{price: 100, volume: 1}, {price: 100.4, volume: 1}, {price: 100.8, volume: 1}},
+			want:     100.4,
+			statuses: []string{ComponentUsed, ComponentUsed, ComponentUsed},
+		},
+		{
+			name:     "volume weighted",
+			quotes:   []fakeQuote{{price: 100, volume: 3}, {price: 100.4, volume: 1}},
+			want:     100.1,
+			statuses: []string{ComponentUsed, ComponentUsed},
+		},
+		{
+			name:     "median without volume",
+			quotes:   []fakeQuote{{price: 100}, {price: 100.2}, {price: 100.3}},
+			want:     100.2,
+			statuses: []string{ComponentUsed, ComponentUsed, ComponentUsed},
+		},
+		{
+			name:     "two sources apart both rejected",
+			quotes:   []fakeQuote{{price: 100, volume: 1}, {price: 110, volume: 1}},
+			statuses: []string{ComponentOutlier, ComponentOutlier},
+		},
+		{
+			name:       "too few fresh sources",
+			minSources: "2",
+			quotes:     []fakeQuote{{price: 100, volume: 1}, {price: 100, volume: 1, age: time.Minute}},
+			statuses:   []string{ComponentUsed, ComponentStale},
+		},
+		{
+			name:       "too few after outliers",
+			minSources: "3",
+			quotes:     []fakeQuote{{price: 100, volume: 1}, {price: 100.1, volume: 1}, {price: 90, volume: 1}},
+			statuses:   []string{ComponentUsed, ComponentUsed, ComponentOutlier},
+		},
+		{
+			name:       "enough sources",
+			minSources: "2",
+			quotes:     []fakeQuote{{price: 100, volume: 1}, {price: 100.2, volume: 1}},
+			want:       100.1,
+			statuses:   []string{ComponentUsed, ComponentUsed},
+		},
+	}
+	t.Cleanup(func() { SetPriceSources(BinanceSource{}) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INDEX_MIN_SOURCES", tt.minSources)
+			t.Setenv("INDEX_STALE_SECONDS", "10")
+			t.Setenv("INDEX_MAX_DEVIATION_PCT", "0.5")
+
+			now := time.Now()
+			var sources []PriceSource
+			for i, q := range tt.quotes {
+				src := &FakePriceSource{SourceName: string(rune('a' + i))}
+				if !q.missing {
+					src.SetQuote("BTCUSDT", SourceQuote{Price: q.price, Volume: q.volume, Time: now.Add(-q.age)})
+				}
+				sources = append(sources, src)
+			}
+			SetPriceSources(sources...)
+
+			idx, err := IndexPrice("BTCUSDT")
+			if tt.want == 0 {
+				if !errors.Is(err, ErrPriceUnavailable) {
+					t.Fatalf("IndexPrice = %v, %v; want ErrPriceUnavailable", idx.Price, err)
+				}
+			} else if err != nil || math.Abs(idx.Price-tt.want) > 1e-9 {
+				t.Fatalf("IndexPrice = %v, %v; want %v", idx.Price, err, tt.want)
+			}
+
+			if len(idx.Components) != len(tt.statuses) {
+				t.Fatalf("%d components, want %d", len(idx.Components), len(tt.statuses))
+			}
+			for i, c := range idx.Components {
+				if c.Status != tt.statuses[i] {
+					t.Errorf("component %s status %s, want %s", c.Source, c.Status, tt.statuses[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoQuote = errors.New("no quote for symbol")
+
+// SourceQuote is one venue's view of a symbol's price
+type SourceQuote struct {
+	Price  float64   `json:"price"`  // last trade price
+	Volume float64   `json:"volume"` // rolling 24h volume in the base asset, used as index weight
+	Time   time.Time `json:"time"`   // when the venue reported the price
+}
+
+// PriceSource is an exchange feed contributing to the price index
+type PriceSource interface {
+	Name() string
+	Quote(symbol string) (SourceQuote, error)
+}
+
+// How often REST-only sources are polled, well inside their public rate limits
+const sourcePollInterval = 2 * time.Second
+
+// BinanceSource reads the price cache kept current by the market stream
+type BinanceSource struct{}
+
+func (BinanceSource) Name() string { return "binance" }
+
+func (BinanceSource) Quote(symbol string) (SourceQuote, error) {
+	u := GetCurrentPrice(symbol)
+	if u.Price <= 0 {
+		return SourceQuote{}, ErrNoQuote
+	}
+	return SourceQuote{Price: u.Price, Volume: u.Volume, Time: u.UpdatedAt}, nil
+}
+
+// CoinbaseSource polls the Coinbase Exchange ticker, e.g. BTC-USDT
+type CoinbaseSource struct {
+	BaseURL string
+}
+
+func (CoinbaseSource) Name() string { return "coinbase" }
+
+func (s CoinbaseSource) Quote(symbol string) (SourceQuote, error) {
+	base, quote := splitSymbol(symbol)
+	if quote == "" {
+		return SourceQuote{}, ErrNoQuote
+	}
+	var data struct {
+		Price  string    `json:"price"`
+		Volume string    `json:"volume"`
+		Time   time.Time `json:"time"`
+	}
+	if err := getJSON(fmt.Sprintf("%s/products/%s-%s/ticker", s.BaseURL, base, quote), &data); err != nil {
+		return SourceQuote{}, err
+	}
+	price, err := strconv.ParseFloat(data.Price, 64)
+	if err != nil || price <= 0 {
+		return SourceQuote{}, ErrNoQuote
+	}
+	volume, _ := strconv.ParseFloat(data.Volume, 64)
+	return SourceQuote{Price: price, Volume: volume, Time: data.Time}, nil
+}
+
+// Kraken names some assets differently
+var krakenAssets = map[string]string{"BTC": "XBT", "DOGE": "XDG"}
+
+// KrakenSource polls the Kraken public ticker, e.g. XBTUSDT
+type KrakenSource struct {
+	BaseURL string
+}
+
+func (KrakenSource) Name() string { return "kraken" }
+
+func (s KrakenSource) Quote(symbol string) (SourceQuote, error) {
+	base, quote := splitSymbol(symbol)
+	if quote == "" {
+		return SourceQuote{}, ErrNoQuote
+	}
+	if v, ok := krakenAssets[base]; ok {
+		base = v
+	}
+	var data struct {
+		Error  []string `json:"error"`
+		Result map[string]struct {
+			Last   []string `json:"c"` // [price, lot volume]
+			Volume []string `json:"v"` // [today, last 24h]
+		} `json:"result"`
+	}
+	if err := getJSON(s.BaseURL+"/0/public/Ticker?"+url.Values{"pair": {base + quote}}.Encode(), &data); err != nil {
+		return SourceQuote{}, err
+	}
+	if len(data.Error) > 0 {
+		return SourceQuote{}, fmt.Errorf("kraken: %s", strings.Join(data.Error, ", "))
+	}
+	// The result is keyed by Kraken's own pair name, which may differ from the request
+	for _, t := range data.Result {
+		if len(t.Last) == 0 || len(t.Volume) < 2 {
+			break
+		}
+		price, err := strconv.ParseFloat(t.Last[0], 64)
+		if err != nil || price <= 0 {
+			break
+		}
+		volume, _ := strconv.ParseFloat(t.Volume[1], 64)
+		// Kraken doesn't timestamp the ticker; it is as fresh as the request
+		return SourceQuote{Price: price, Volume: volume, Time: time.Now()}, nil
+	}
+	return SourceQuote{}, ErrNoQuote
+}
+
+// FakePriceSource serves quotes set by hand; for local development and tests.
+type FakePriceSource struct {
+	SourceName string
+	mu         sync.Mutex
+	quotes     map[string]SourceQuote
+}
+
+func (f *FakePriceSource) Name() string { return f.SourceName }
+
+// Set makes the source report price and volume for symbol, timestamped now
+func (f *FakePriceSource) Set(symbol string, price, volume float64) {
+	f.SetQuote(symbol, SourceQuote{Price: price, Volume: volume, Time: time.Now()})
+}
+
+// SetQuote makes the source report q for symbol as is, e.g. to simulate a stale feed
+func (f *FakePriceSource) SetQuote(symbol string, q SourceQuote) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.quotes == nil {
+		f.quotes = map[string]SourceQuote{}
+	}
+	f.quotes[symbol] = q
+}
+
+func (f *FakePriceSource) Quote(symbol string) (SourceQuote, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.quotes[symbol]
+	if !ok {
+		return SourceQuote{}, ErrNoQuote
+	}
+	return q, nil
+}
+
+// priceSourceNames lists the sources named in PRICE_SOURCES (comma
+// separated), defaulting to binance, coinbase and kraken
+func priceSourceNames() []string {
+	names := os.Getenv("PRICE_SOURCES")
+	if names == "" {
+		names = "binance,coinbase,kraken"
+	}
+	var out []string
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// priceSourcesFromEnv builds the sources named in PRICE_SOURCES. Binance is
+// read from the market stream; the others are polled.
+func priceSourcesFromEnv() []PriceSource {
+	coinbaseAPI := os.Getenv("COINBASE_API")
+	if coinbaseAPI == "" {
+		coinbaseAPI = "https://api.exchange.coinbase.com"
+	}
+	krakenAPI := os.Getenv("KRAKEN_API")
+	if krakenAPI == "" {
+		krakenAPI = "https://api.kraken.com"
+	}
+
+	var sources []PriceSource
+	for _, name := range priceSourceNames() {
+		switch name {
+		case "binance":
+			sources = append(sources, BinanceSource{})
+		case "coinbase":
+			sources = append(sources, pollSource(CoinbaseSource{BaseURL: strings.TrimRight(coinbaseAPI, "/")}, sourcePollInterval))
+		case "kraken":
+			sources = append(sources, pollSource(KrakenSource{BaseURL: strings.TrimRight(krakenAPI, "/")}, sourcePollInterval))
+		default:
+			log.Printf("⚠️ Unknown price source %q ignored", name)
+		}
+	}
+	return sources
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+)
+
+var ErrEmailTaken = errors.New("email is already in use")
+
+// RequestEmailChange records newEmail as pending and mails a confirmation
+// link to it, plus a heads-up to the current address. The account keeps its
+// old email until ConfirmEmailChange succeeds.
+func RequestEmailChange(user *models.User, newEmail string) error {
+	var count int64
+	config.DB.Model(&models.User{}).Where("email = ? AND id <> ?", newEmail, user.ID).Count(&count)
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	if err := config.DB.Model(user).Update("pending_email", newEmail).Error; err != nil {
+		return err
+	}
+
+	token, ttl, err := IssueUserToken(user.ID, models.TokenPurposeEmailChange, newEmail)
+	if err != nil {
+		return err
+	}
+	if err := SendTemplate(newEmail, "confirm_email_change", map[string]interface{}{
+		"Username": user.Username,
+		"Link":     AppURL() + "/confirm-email?token=" + token,
+		"TTL":      expiryText(ttl),
+	}); err != nil {
+		return err
+	}
+	return SendTemplate(user.Email, "email_change_requested", map[string]interface{}{
+		"Username": user.Username,
+		"NewEmail": newEmail,
+	})
+}
+
+// ConfirmEmailChange consumes an email_change token and switches the account
+// to the new, now verified, address. It returns the user and the old email.
+func ConfirmEmailChange(token string) (*models.User, string, error) {
+	ut, err := ConsumeUserToken(token, models.TokenPurposeEmailChange)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var user models.User
+	var oldEmail string
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, ut.UserID).Error; err != nil {
+			return err
+		}
+		// A later request replaces the pending address; older links stop working
+		if user.PendingEmail != ut.Email {
+			return ErrInvalidUserToken
+		}
+		var count int64
+		tx.Model(&models.User{}).Where("email = ? AND id <> ?", ut.Email, user.ID).Count(&count)
+		if count > 0 {
+			return ErrEmailTaken
+		}
+		oldEmail = user.Email
+		user.Email, user.EmailVerified, user.PendingEmail = ut.Email, true, ""
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"email":          user.Email,
+			"email_verified": true,
+			"pending_email":  "",
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, oldEmail, nil
+}
+
+func avatarDir() string {
+	dir := os.Getenv("AVATAR_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "avatars")
+	}
+	return dir
+}
+
+// SaveAvatar writes a new avatar image for the user and removes the old one.
+// Each upload gets a fresh file name so clients and caches pick up the change.
+func SaveAvatar(user *models.User, data []byte, ext string) error {
+	if err := os.MkdirAll(avatarDir(), 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(avatarDir(), fmt.Sprintf("avatar-%d-%s%s", user.ID, uuid.NewString()[:8], ext))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	if err := config.DB.Model(user).Update("avatar_path", path).Error; err != nil {
+		os.Remove(path)
+		return err
+	}
+	removeAvatarFile(user.AvatarPath)
+	user.AvatarPath = path
+	return nil
+}
+
+// DeleteAvatar clears the user's avatar
+func DeleteAvatar(user *models.User) error {
+	if err := config.DB.Model(user).Update("avatar_path", "").Error; err != nil {
+		return err
+	}
+	removeAvatarFile(user.AvatarPath)
+	user.AvatarPath = ""
+	return nil
+}
+
+// removeAvatarFile only deletes files inside the avatar directory
+func removeAvatarFile(path string) {
+	if path != "" && strings.HasPrefix(filepath.Clean(path), filepath.Clean(avatarDir())+string(filepath.Separator)) {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitStore holds token buckets. Take refills the bucket for key at rate
+// tokens/sec up to burst, then tries to remove one token.
+type RateLimitStore interface {
+	Take(key string, rate float64, burst int) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// refill applies the token bucket algorithm; shared by both stores.
+func refill(tokens float64, last, now time.Time, rate float64, burst int) (float64, bool, time.Duration) {
+	tokens = math.Min(float64(burst), tokens+now.Sub(last).Seconds()*rate)
+	if tokens >= 1 {
+		return tokens - 1, true, 0
+	}
+	wait := time.Duration((1 - tokens) / rate * float64(time.Second))
+	return tokens, false, wait
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process; limits are per replica.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+	go s.janitor()
+	return s
+}
+
+func (s *MemoryRateLimitStore) Take(key string, rate float64, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	var allowed bool
+	var wait time.Duration
+	b.tokens, allowed, wait = refill(b.tokens, b.last, now, rate, burst)
+	b.last = now
+	return allowed, wait, nil
+}
+
+// janitor drops idle buckets; an idle bucket is full anyway.
+func (s *MemoryRateLimitStore) janitor() {
+	for range time.Tick(10 * time.Minute) {
+		cutoff := time.Now().Add(-time.Hour)
+		s.mu.Lock()
+		for k, b := range s.buckets {
+			if b.last.Before(cutoff) {
+				delete(s.buckets, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// PostgresRateLimitStore shares buckets across replicas via row locks.
+type PostgresRateLimitStore struct {
+	db *gorm.DB
+}
+
+func NewPostgresRateLimitStore(db *gorm.DB) *PostgresRateLimitStore {
+	s := &PostgresRateLimitStore{db: db}
+	go s.janitor()
+	return s
+}
+
+func (s *PostgresRateLimitStore) Take(key string, rate float64, burst int) (bool, time.Duration, error) {
+	var (
+		allowed bool
+		wait    time.Duration
+	)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		b := models.RateLimitBucket{Key: key, Tokens: float64(burst), UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&b).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&b, "key = ?", key).Error; err != nil {
+			return err
+		}
+		b.Tokens, allowed, wait = refill(b.Tokens, b.UpdatedAt, now, rate, burst)
+		return tx.Model(&b).Updates(map[string]interface{}{"tokens": b.Tokens, "updated_at": now}).Error
+	})
+	return allowed, wait, err
+}
+
+func (s *PostgresRateLimitStore) janitor() {
+	for range time.Tick(10 * time.Minute) {
+		s.db.Where("updated_at < ?", time.Now().Add(-time.Hour)).Delete(&models.RateLimitBucket{})
+	}
+}
+
+// RateLimiter is the store used by middleware.RateLimit.
+var RateLimiter RateLimitStore
+
+// InitRateLimiter picks the store from RATE_LIMIT_STORE (memory | postgres, default memory).
+func InitRateLimiter() {
+	switch os.Getenv("RATE_LIMIT_STORE") {
+	case "postgres":
+		RateLimiter = NewPostgresRateLimitStore(config.DB)
+	default:
+		RateLimiter = NewMemoryRateLimitStore()
+	}
+	log.Printf("rate limiter: %T", RateLimiter)
+}
+
+const (
+	lockoutThreshold = 5
+	lockoutBase      = time.Minute
+	lockoutMax       = 24 * time.Hour
+)
+
+// LoginLockedFor returns how long the account stays locked, or 0.
+func LoginLockedFor(user *models.User) time.Duration {
+	if user.LockedUntil == nil {
+		return 0
+	}
+	if d := time.Until(*user.LockedUntil); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// RecordFailedLogin bumps the failure counter and, past the threshold, locks the
+// account for an exponentially growing period. Returns the lock duration (or 0).
+func RecordFailedLogin(user *models.User) time.Duration {
+	config.DB.Model(user).Update("failed_logins", gorm.Expr("failed_logins + 1"))
+	config.DB.Select("failed_logins").First(user, user.ID)
+
+	over := user.FailedLogins - lockoutThreshold
+	if over < 0 {
+		return 0
+	}
+	lock := lockoutBase << uint(min(over, 20))
+	if lock > lockoutMax {
+		lock = lockoutMax
+	}
+	until := time.Now().Add(lock)
+	config.DB.Model(user).Update("locked_until", until)
+	return lock
+}
+
+// ResetFailedLogins clears the counter after a successful login.
+func ResetFailedLogins(user *models.User) {
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return
+	}
+	config.DB.Model(user).Updates(map[string]interface{}{"failed_logins": 0, "locked_until": nil})
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidLimit          = errors.New("kind must be deposit or loss and period daily, weekly or monthly")
+	ErrSelfExclusionPeriod   = errors.New("invalid self-exclusion period")
+	ErrSelfExclusionShorter  = errors.New("an active self-exclusion can only be extended")
+	ErrSessionReminderLength = errors.New("session reminder must be between 0 and 1440 minutes")
+)
+
+// SelfExclusionPeriods are the exclusion lengths users can pick. "permanent"
+// can only be lifted by support.
+var SelfExclusionPeriods = map[string]time.Duration{
+	"24h":       24 * time.Hour,
+	"7d":        7 * 24 * time.Hour,
+	"30d":       30 * 24 * time.Hour,
+	"90d":       90 * 24 * time.Hour,
+	"180d":      180 * 24 * time.Hour,
+	"365d":      365 * 24 * time.Hour,
+	"permanent": 100 * 365 * 24 * time.Hour,
+}
+
+// A session counts as active while its refresh token was used within one access
+// token lifetime.
+const reminderActiveWindow = 3 * time.Hour
+
+// Transactions that make up trading profit and loss
+var tradingTxTypes = []string{"trade", "trade_win", "trade_void"}
+
+// CoolingOffPeriod is how long a raised or removed limit waits before taking
+// effect. Override with RG_COOLING_OFF_HOURS.
+func CoolingOffPeriod() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("RG_COOLING_OFF_HOURS")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// SelfExcludedError is returned for deposits and trades during self-exclusion
+type SelfExcludedError struct {
+	Until time.Time
+}
+
+func (e *SelfExcludedError) Error() string {
+	return fmt.Sprintf("account is self-excluded until %s", e.Until.UTC().Format(time.RFC3339))
+}
+
+// GamingLimitError is returned when an action would break a user's own limit
+type GamingLimitError struct {
+	Kind      string
+	Period    string
+	Limit     float64
+	Remaining float64
+}
+
+func (e *GamingLimitError) Error() string {
+	return fmt.Sprintf("%s %s limit of %.2f %s reached", e.Period, e.Kind, e.Limit, LimitCurrency)
+}
+
+// GamingLimits returns the user's limits, first applying any pending change
+// whose cooling-off period has elapsed.
+func GamingLimits(userID uint) ([]models.GamingLimit, error) {
+	var limits []models.GamingLimit
+	if err := config.DB.Where("user_id = ?", userID).Order("kind, period").Find(&limits).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range limits {
+		l := &limits[i]
+		if l.PendingEffectiveAt == nil || l.PendingEffectiveAt.After(now) {
+			continue
+		}
+		if l.PendingRemove {
+			l.Amount = nil
+		} else {
+			l.Amount = l.PendingAmount
+		}
+		l.PendingAmount, l.PendingRemove, l.PendingEffectiveAt = nil, false, nil
+		if err := config.DB.Save(l).Error; err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
+
+// SetGamingLimit sets or removes (amount nil) a limit. Tighter limits apply
+// immediately; looser ones are queued behind the cooling-off period.
+func SetGamingLimit(userID uint, kind, period string, amount *float64) (*models.GamingLimit, error) {
+	if _, ok := models.LimitPeriods[period]; !ok || (kind != models.LimitDeposit && kind != models.LimitLoss) {
+		return nil, ErrInvalidLimit
+	}
+	if amount != nil && *amount < 0 {
+		return nil, ErrInvalidLimit
+	}
+	if _, err := GamingLimits(userID); err != nil {
+		return nil, err
+	}
+
+	limit := models.GamingLimit{UserID: userID, Kind: kind, Period: period}
+	err := config.DB.Where(&limit).First(&limit).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	tighter := amount != nil && (limit.Amount == nil || *amount <= *limit.Amount)
+	if tighter {
+		limit.Amount = amount
+		limit.PendingAmount, limit.PendingRemove, limit.PendingEffectiveAt = nil, false, nil
+	} else if limit.Amount != nil {
+		effective := time.Now().Add(CoolingOffPeriod())
+		limit.PendingAmount = amount
+		limit.PendingRemove = amount == nil
+		limit.PendingEffectiveAt = &effective
+	}
+	if limit.ID == 0 && amount == nil {
+		return &limit, nil // removing a limit that doesn't exist
+	}
+
+	if err := config.DB.Save(&limit).Error; err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// SelfExclude blocks deposits and trading for the chosen period. An active
+// exclusion can be extended but never shortened.
+func SelfExclude(userID uint, period string) (time.Time, error) {
+	d, ok := SelfExclusionPeriods[period]
+	if !ok {
+		return time.Time{}, ErrSelfExclusionPeriod
+	}
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return time.Time{}, err
+	}
+	until := time.Now().Add(d)
+	if user.SelfExcludedUntil != nil && user.SelfExcludedUntil.After(until) {
+		return time.Time{}, ErrSelfExclusionShorter
+	}
+	if err := config.DB.Model(&user).Update("self_excluded_until", until).Error; err != nil {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// SetSessionReminder sets how often (in minutes) a logged-in user is reminded
+// how long their session has lasted; 0 turns reminders off.
+func SetSessionReminder(userID uint, minutes int) error {
+	if minutes < 0 || minutes > 1440 {
+		return ErrSessionReminderLength
+	}
+	return config.DB.Model(&models.User{}).Where("id = ?", userID).
+		Update("session_reminder_minutes", minutes).Error
+}
+
+func checkSelfExclusion(userID uint) error {
+	var user models.User
+	if err := config.DB.Select("self_excluded_until").First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.SelfExcludedUntil != nil && user.SelfExcludedUntil.After(time.Now()) {
+		return &SelfExcludedError{Until: *user.SelfExcludedUntil}
+	}
+	return nil
+}
+
+// periodUsage is how much of a limit kind has been used in the period window,
+// in LimitCurrency: total deposits, or net trading loss (stakes of open trades
+// count until they settle).
+func periodUsage(userID uint, kind, period string) (float64, error) {
+	since := time.Now().Add(-models.LimitPeriods[period])
+	if kind == models.LimitDeposit {
+		return realWalletTotal(userID, since, "deposit")
+	}
+	net, err := realWalletTotal(userID, since, tradingTxTypes...)
+	return math.Max(-net, 0), err
+}
+
+func checkGamingLimits(userID uint, kind, currency string, amount float64) error {
+	limits, err := GamingLimits(userID)
+	if err != nil {
+		return err
+	}
+	valued := false
+	for _, l := range limits {
+		if l.Kind != kind || l.Amount == nil {
+			continue
+		}
+		if !valued {
+			if amount, err = limitValue(currency, amount); err != nil {
+				return err
+			}
+			valued = true
+		}
+		used, err := periodUsage(userID, l.Kind, l.Period)
+		if err != nil {
+			return err
+		}
+		if used+amount > *l.Amount {
+			return &GamingLimitError{Kind: l.Kind, Period: l.Period, Limit: *l.Amount, Remaining: math.Max(*l.Amount-used, 0)}
+		}
+	}
+	return nil
+}
+
+// CheckDepositAllowed enforces self-exclusion and the user's deposit limits
+// for a deposit of amount currency
+func CheckDepositAllowed(userID uint, currency string, amount float64) error {
+	if err := checkSelfExclusion(userID); err != nil {
+		return err
+	}
+	return checkGamingLimits(userID, models.LimitDeposit, currency, amount)
+}
+
+// CheckTradeAllowed enforces self-exclusion and, for real money, the user's
+// loss limits, treating the whole stake (in currency) as a potential loss.
+func CheckTradeAllowed(userID uint, currency string, stake float64, isDemo bool) error {
+	if err := checkSelfExclusion(userID); err != nil {
+		return err
+	}
+	if isDemo {
+		return nil
+	}
+	return checkGamingLimits(userID, models.LimitLoss, currency, stake)
+}
+
+// LimitStatus is a limit together with how much of it has been used
+type LimitStatus struct {
+	models.GamingLimit
+	Used      float64  `json:"used"`
+	Remaining *float64 `json:"remaining,omitempty"`
+}
+
+// ResponsibleGaming is the user's responsible-gaming settings as shown in the profile
+type ResponsibleGaming struct {
+	Limits                 []LimitStatus `json:"limits"`
+	SelfExcludedUntil      *time.Time    `json:"self_excluded_until,omitempty"`
+	SessionReminderMinutes int           `json:"session_reminder_minutes"`
+	CoolingOffHours        int           `json:"cooling_off_hours"`
+}
+
+// ResponsibleGamingFor collects limits with current usage and the other settings
+func ResponsibleGamingFor(user *models.User) (*ResponsibleGaming, error) {
+	limits, err := GamingLimits(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	rg := &ResponsibleGaming{
+		Limits:                 make([]LimitStatus, 0, len(limits)),
+		SessionReminderMinutes: user.SessionReminderMinutes,
+		CoolingOffHours:        int(CoolingOffPeriod().Hours()),
+	}
+	if user.SelfExcludedUntil != nil && user.SelfExcludedUntil.After(time.Now()) {
+		rg.SelfExcludedUntil = user.SelfExcludedUntil
+	}
+	for _, l := range limits {
+		used, err := periodUsage(user.ID, l.Kind, l.Period)
+		if err != nil {
+			return nil, err
+		}
+		st := LimitStatus{GamingLimit: l, Used: used}
+		if l.Amount != nil {
+			remaining := math.Max(*l.Amount-used, 0)
+			st.Remaining = &remaining
+		}
+		rg.Limits = append(rg.Limits, st)
+	}
+	return rg, nil
+}
+
+// StartSessionReminders pushes a "session_reminder" WebSocket message each
+// time an active session crosses another multiple of the user's reminder interval.
+func StartSessionReminders() {
+	sent := map[string]int{} // token family -> reminders already sent
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			var rows []struct {
+				UserID           uint
+				FamilyID         string
+				SessionStartedAt time.Time
+				Minutes          int
+			}
+			config.DB.Model(&models.RefreshToken{}).
+				Select("refresh_tokens.user_id, refresh_tokens.family_id, refresh_tokens.session_started_at, users.session_reminder_minutes AS minutes").
+				Joins("JOIN users ON users.id = refresh_tokens.user_id").
+				Where("refresh_tokens.revoked = ? AND refresh_tokens.expires_at > ? AND refresh_tokens.family_id <> ''", false, time.Now()).
+				Where("users.session_reminder_minutes > 0 AND refresh_tokens.last_used_at > ?", time.Now().Add(-reminderActiveWindow)).
+				Scan(&rows)
+
+			live := make(map[string]bool, len(rows))
+			for _, r := range rows {
+				live[r.FamilyID] = true
+				elapsed := time.Since(r.SessionStartedAt)
+				n := int(elapsed / (time.Duration(r.Minutes) * time.Minute))
+				if n > sent[r.FamilyID] {
+					sent[r.FamilyID] = n
+					config.WSHub.SendToUser(r.UserID, fmt.Sprintf(
+						`{"type": "session_reminder", "elapsed_minutes": %d}`, int(elapsed.Minutes())))
+				}
+			}
+			for family := range sent {
+				if !live[family] {
+					delete(sent, family)
+				}
+			}
+		}
+	}()
+}
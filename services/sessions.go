@@ -0,0 +1,58 @@
+package services
+
+import (
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+// Session is the user-facing view of a refresh token family.
+type Session struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// ListSessions returns one entry per live token family; each family has exactly
+// one unrevoked token at a time.
+func ListSessions(userID uint, currentJTI string) ([]Session, error) {
+	var tokens []models.RefreshToken
+	if err := config.DB.
+		Where("user_id = ? AND revoked = ? AND expires_at > ?", userID, false, time.Now()).
+		Order("last_used_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, Session{
+			ID:         t.ID,
+			UserAgent:  t.UserAgent,
+			IP:         t.IP,
+			StartedAt:  t.SessionStartedAt,
+			LastUsedAt: t.LastUsedAt,
+			ExpiresAt:  t.ExpiresAt,
+			Current:    currentJTI != "" && t.JTI == currentJTI,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeTokenFamily revokes every token in the family of rt. A token issued
+// before families existed heads the family its rotations were given, named
+// after its JTI.
+func RevokeTokenFamily(rt models.RefreshToken) error {
+	q := config.DB.Model(&models.RefreshToken{}).Where("user_id = ?", rt.UserID)
+	if rt.FamilyID != "" {
+		q = q.Where("family_id = ?", rt.FamilyID)
+	} else {
+		q = q.Where("jti = ? OR family_id = ?", rt.JTI, rt.JTI)
+	}
+	return q.Update("revoked", true).Error
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/utils"
+)
+
+// StatementLine is a single wallet movement, enriched with trade details when it
+// originates from a trade.
+type StatementLine struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+	TradeID     *uint     `json:"trade_id,omitempty"`
+}
+
+type WalletStatement struct {
+	WalletID       uint            `json:"wallet_id"`
+	Currency       string          `json:"currency"`
+	OpeningBalance float64         `json:"opening_balance"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Lines          []StatementLine `json:"lines"`
+}
+
+type Statement struct {
+	UserID      uint              `json:"user_id"`
+	Username    string            `json:"username"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Wallets     []WalletStatement `json:"wallets"`
+}
+
+// BuildStatement assembles opening balance, line items and closing balance for
+// each of the user's wallets over [from, to).
+func BuildStatement(userID uint, from, to time.Time) (*Statement, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var wallets []models.Wallet
+	if err := config.DB.Where("user_id = ? AND is_demo = ?", userID, false).Order("id ASC").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+
+	st := &Statement{
+		UserID:      userID,
+		Username:    user.Username,
+		From:        from,
+		To:          to,
+		GeneratedAt: time.Now(),
+	}
+
+	for _, w := range wallets {
+		// The opening balance is everything booked before the period. Walking
+		// back from the current balance instead would be thrown off by any
+		// balance change that wasn't booked as a transaction.
+		var opening float64
+		if err := config.DB.Model(&models.WalletTransaction{}).
+			Where("wallet_id = ? AND created_at < ?", w.ID, from).
+			Select("COALESCE(SUM(amount), 0)").Scan(&opening).Error; err != nil {
+			return nil, err
+		}
+
+		var txs []models.WalletTransaction
+		if err := config.DB.Preload("Trade").
+			Where("wallet_id = ? AND created_at >= ? AND created_at < ?", w.ID, from, to).
+			Order("created_at ASC, id ASC").Find(&txs).Error; err != nil {
+			return nil, err
+		}
+
+		st.Wallets = append(st.Wallets, walletStatement(w, opening, txs))
+	}
+
+	return st, nil
+}
+
+// walletStatement runs the balance from opening through txs, which must be in
+// booking order.
+func walletStatement(w models.Wallet, opening float64, txs []models.WalletTransaction) WalletStatement {
+	ws := WalletStatement{
+		WalletID:       w.ID,
+		Currency:       w.Currency,
+		OpeningBalance: opening,
+	}
+	balance := opening
+	for _, tx := range txs {
+		balance += tx.Amount
+		ws.Lines = append(ws.Lines, StatementLine{
+			Time:        tx.CreatedAt,
+			Type:        tx.Type,
+			Description: describeTransaction(tx),
+			Amount:      tx.Amount,
+			Balance:     balance,
+			TradeID:     tx.TradeID,
+		})
+	}
+	ws.ClosingBalance = balance
+	return ws
+}
+
+func describeTransaction(tx models.WalletTransaction) string {
+	if tx.Trade == nil {
+		return tx.Reference
+	}
+	t := tx.Trade
+	return fmt.Sprintf("Trade #%d %s %s %ds @ %s (%s)",
+		t.ID, t.Asset, t.Direction, t.Duration, strconv.FormatFloat(t.EntryPrice, 'f', -1, 64), t.Status)
+}
+
+// WriteStatementCSV writes one row per line item, with opening/closing rows per wallet.
+func WriteStatementCSV(w io.Writer, st *Statement) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"wallet_id", "currency", "time", "type", "description", "amount", "balance", "trade_id"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	for _, ws := range st.Wallets {
+		id := strconv.FormatUint(uint64(ws.WalletID), 10)
+		_ = cw.Write([]string{id, ws.Currency, st.From.Format(time.RFC3339), "opening_balance", "", "", f(ws.OpeningBalance), ""})
+		for _, l := range ws.Lines {
+			tradeID := ""
+			if l.TradeID != nil {
+				tradeID = strconv.FormatUint(uint64(*l.TradeID), 10)
+			}
+			_ = cw.Write([]string{id, ws.Currency, l.Time.Format(time.RFC3339), l.Type, l.Description, f(l.Amount), f(l.Balance), tradeID})
+		}
+		_ = cw.Write([]string{id, ws.Currency, st.To.Format(time.RFC3339), "closing_balance", "", "", f(ws.ClosingBalance), ""})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteStatementPDF renders the statement as a simple fixed-width PDF document.
+func WriteStatementPDF(w io.Writer, st *Statement) error {
+	lines := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		fmt.Sprintf("User:      %s (#%d)", st.Username, st.UserID),
+		fmt.Sprintf("Period:    %s - %s", st.From.Format("2006-01-02 15:04"), st.To.Format("2006-01-02 15:04")),
+		fmt.Sprintf("Generated: %s", st.GeneratedAt.Format(time.RFC1123)),
+	}
+	row := "%-19s  %-10s  %-44s  %14s  %14s"
+	for _, ws := range st.Wallets {
+		lines = append(lines,
+			"",
+			fmt.Sprintf("Wallet #%d (%s)", ws.WalletID, ws.Currency),
+			strings.Repeat("-", 107),
+			fmt.Sprintf(row, "Time", "Type", "Description", "Amount", "Balance"),
+			strings.Repeat("-", 107),
+			fmt.Sprintf(row, st.From.Format("2006-01-02 15:04:05"), "", "Opening balance", "", fmt.Sprintf("%.8f", ws.OpeningBalance)),
+		)
+		for _, l := range ws.Lines {
+			desc := l.Description
+			if len(desc) > 44 {
+				desc = desc[:41] + "..."
+			}
+			lines = append(lines, fmt.Sprintf(row,
+				l.Time.Format("2006-01-02 15:04:05"), l.Type, desc,
+				fmt.Sprintf("%.8f", l.Amount), fmt.Sprintf("%.8f", l.Balance)))
+		}
+		lines = append(lines,
+			fmt.Sprintf(row, st.To.Format("2006-01-02 15:04:05"), "", "Closing balance", "", fmt.Sprintf("%.8f", ws.ClosingBalance)),
+		)
+	}
+	return utils.WriteTextPDF(w, lines)
+}
+
+// WriteStatement dispatches to the CSV or PDF writer.
+func WriteStatement(w io.Writer, st *Statement, format string) error {
+	switch format {
+	case "csv":
+		return WriteStatementCSV(w, st)
+	case "pdf":
+		return WriteStatementPDF(w, st)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+func statementDir() string {
+	dir := os.Getenv("STATEMENT_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "statements")
+	}
+	return dir
+}
+
+// GenerateStatementExport builds the statement for a queued export and writes it
+// to STATEMENT_DIR, updating the export row with the outcome.
+func GenerateStatementExport(exportID uint) {
+	var exp models.StatementExport
+	if err := config.DB.First(&exp, exportID).Error; err != nil {
+		return
+	}
+
+	fail := func(err error) {
+		config.DB.Model(&exp).Updates(map[string]interface{}{"status": "FAILED", "error": err.Error()})
+	}
+
+	st, err := BuildStatement(exp.UserID, exp.From, exp.To)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if err := os.MkdirAll(statementDir(), 0o700); err != nil {
+		fail(err)
+		return
+	}
+	path := filepath.Join(statementDir(), fmt.Sprintf("statement-%d-%d.%s", exp.UserID, exp.ID, exp.Format))
+	f, err := os.Create(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer f.Close()
+
+	if err := WriteStatement(f, st, exp.Format); err != nil {
+		fail(err)
+		return
+	}
+
+	config.DB.Model(&exp).Updates(map[string]interface{}{"status": "READY", "file_path": path})
+	config.WSHub.SendToUser(exp.UserID, fmt.Sprintf(`{"type": "statement_ready", "export_id": %d}`, exp.ID))
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/models"
+)
+
+func testStatement() *Statement {
+	at := func(h int) time.Time { return time.Date(2024, 3, 5, h, 0, 0, 0, time.UTC) }
+	tradeID := uint(9)
+	trade := &models.Trade{ID: 9, Asset: "BTCUSDT", Direction: "UP", Duration: 60, EntryPrice: 61500.5, Status: "WON"}
+	txs := []models.WalletTransaction{
+		{Type: "deposit", Amount: 100, Reference: "Deposit via card", CreatedAt: at(9)},
+		{Type: "trade", Amount: -25.5, TradeID: &tradeID, Trade: trade, CreatedAt: at(10)},
+		{Type: "trade_win", Amount: 40.25, TradeID: &tradeID, Trade: trade, CreatedAt: at(11)},
+	}
+	return &Statement{
+		UserID:      3,
+		Username:    "alice",
+		From:        at(0),
+		To:          at(0).AddDate(0, 0, 1),
+		GeneratedAt: at(12),
+		Wallets: []WalletStatement{
+			walletStatement(models.Wallet{ID: 7, Currency: "USDT"}, 50, txs),
+			walletStatement(models.Wallet{ID: 8, Currency: "BTC"}, 0.5, nil),
+		},
+	}
+}
+
+func TestWalletStatementBalances(t *testing.T) {
+	st := testStatement()
+
+	ws := st.Wallets[0]
+	want := []float64{150, 124.5, 164.75}
+	if len(ws.Lines) != len(want) {
+		t.Fatalf("%d lines, want %d", len(ws.Lines), len(want))
+	}
+	for i, l := range ws.Lines {
+		if l.Balance != want[i] {
+			t.Errorf("line %d balance %v, want %v", i, l.Balance, want[i])
+		}
+	}
+	if ws.OpeningBalance != 50 || ws.ClosingBalance != 164.75 {
+		t.Errorf("opening %v closing %v, want 50 and 164.75", ws.OpeningBalance, ws.ClosingBalance)
+	}
+
+	// A wallet without movements closes where it opened
+	if quiet := st.Wallets[1]; len(quiet.Lines) != 0 || quiet.ClosingBalance != 0.5 {
+		t.Errorf("quiet wallet = %+v, want closing 0.5 and no lines", quiet)
+	}
+}
+
+func TestWriteStatementCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteStatement(&buf, testStatement(), "csv"); err != nil {
+		t.Fatal(err)
+	}
+	want := `wallet_id,currency,time,type,description,amount,balance,trade_id
+7,USDT,2024-03-05T00:00:00Z,opening_balance,,,50,
+7,USDT,2024-03-05T09:00:00Z,deposit,Deposit via card,100,150,
+7,USDT,2024-03-05T10:00:00Z,trade,Trade #9 BTCUSDT UP 60s @ 61500.5 (WON),-25.5,124.5,9
+7,USDT,2024-03-05T11:00:00Z,trade_win,Trade #9 BTCUSDT UP 60s @ 61500.5 (WON),40.25,164.75,9
+7,USDT,2024-03-06T00:00:00Z,closing_balance,,,164.75,
+8,BTC,2024-03-05T00:00:00Z,opening_balance,,,0.5,
+8,BTC,2024-03-06T00:00:00Z,closing_balance,,,0.5,
+`
+	if got := buf.String(); got != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteStatementPDF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteStatement(&buf, testStatement(), "pdf"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(strings.TrimSpace(out), "%%EOF") {
+		t.Fatalf("not a PDF document:\n%s", out)
+	}
+	for _, want := range []string{
+		"(User:      alice \\(#3\\)) Tj",
+		"(Period:    2024-03-05 00:00 - 2024-03-06 00:00) Tj",
+		"(Wallet #7 \\(USDT\\)) Tj",
+		"(2024-03-05 00:00:00              Opening balance                                                  50.00000000) Tj",
+		"(2024-03-05 10:00:00  trade       Trade #9 BTCUSDT UP 60s @ 61500.5 \\(WON\\)         -25.50000000    124.50000000) Tj",
+		"(2024-03-06 00:00:00              Closing balance                                                 164.75000000) Tj",
+		"(Wallet #8 \\(BTC\\)) Tj",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("PDF is missing %q", want)
+		}
+	}
+}
+
+func TestWriteStatementUnknownFormat(t *testing.T) {
+	if err := WriteStatement(&bytes.Buffer{}, testStatement(), "xlsx"); err == nil {
+		t.Error("xlsx accepted")
+	}
+}
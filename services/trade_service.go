@@ -1,7 +1,9 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/solchef/crypto-options-backend/config"
@@ -9,6 +11,75 @@ import (
 	"gorm.io/gorm"
 )
 
+var errTradeNotOpen = errors.New("trade no longer open")
+
+// SettlementGrace is how long after expiry settlement waits for a halted feed
+// to recover before voiding the trade. Override with SETTLEMENT_GRACE_SECONDS.
+func SettlementGrace() time.Duration {
+	return time.Duration(envFloat("SETTLEMENT_GRACE_SECONDS", 30) * float64(time.Second))
+}
+
+// settlementPrice returns the index price once the symbol's feed is healthy,
+// polling until deadline.
+func settlementPrice(symbol string, deadline time.Time) (float64, error) {
+	for {
+		price, err := settlementQuote(symbol)
+		if err == nil {
+			return price, nil
+		}
+		if clockNow().After(deadline) {
+			return 0, err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// settlementQuote is one attempt at pricing a settlement: the index price,
+// provided the symbol's feed is healthy.
+func settlementQuote(symbol string) (float64, error) {
+	if err := CheckFeedHealthy(symbol); err != nil {
+		return 0, err
+	}
+	index, err := IndexPrice(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return index.Price, nil
+}
+
+// tradeOutcome resolves a trade at exitPrice: WON with its payout, or LOST
+func tradeOutcome(trade models.Trade, exitPrice float64) (string, float64) {
+	if (trade.Direction == "UP" && exitPrice > trade.EntryPrice) ||
+		(trade.Direction == "DOWN" && exitPrice < trade.EntryPrice) {
+		rate := trade.Payout
+		if rate == 0 {
+			rate = models.DefaultPayout // placed before markets had a payout
+		}
+		return "WON", trade.Amount * (1 + rate)
+	}
+	return "LOST", 0
+}
+
+// voidUnpriceable refunds a trade that could not be priced at expiry
+func voidUnpriceable(trade models.Trade, cause error) {
+	// The trade may have been closed while settlement waited
+	if err := config.DB.First(&trade, trade.ID).Error; err != nil || trade.Status != "OPEN" {
+		return
+	}
+	reason := "no reliable price at expiry: " + cause.Error()
+	if _, err := VoidTrade(trade.ID, reason, 0); err != nil {
+		log.Printf("void unpriceable trade %d: %v", trade.ID, err)
+		return
+	}
+	RecordAudit(AuditEntry{
+		Action:     AuditTradeVoid,
+		TargetType: "trade",
+		TargetID:   trade.ID,
+		Before:     map[string]interface{}{"status": "OPEN"},
+		After:      map[string]interface{}{"status": "VOID", "reason": reason},
+	})
+}
+
 // SettleTrade waits until expiry and then resolves the trade
 func SettleTrade(tradeID uint) {
 	var trade models.Trade
@@ -19,37 +90,62 @@ func SettleTrade(tradeID uint) {
 	// Wait until expiry
 	time.Sleep(time.Until(trade.ExpiredAt))
 
-	// Fetch exit price
-	exitPrice, err := GetCurrentPriceByAPI(trade.Asset)
+	// The trade may have been voided or closed while we waited
+	if err := config.DB.First(&trade, tradeID).Error; err != nil || trade.Status != "OPEN" {
+		return
+	}
+
+	// Exit at the composite index price, waiting out a short feed outage
+	exitPrice, err := settlementPrice(trade.Asset, trade.ExpiredAt.Add(SettlementGrace()))
 	if err != nil {
+		voidUnpriceable(trade, err)
 		return
 	}
 
-	var result string
-	if (trade.Direction == "UP" && exitPrice > trade.EntryPrice) ||
-		(trade.Direction == "DOWN" && exitPrice < trade.EntryPrice) {
-		result = "WON"
-		payout := trade.Amount * 1.8 // 80% return
+	result, payout := tradeOutcome(trade, exitPrice)
 
-		config.DB.Model(&models.Wallet{}).
-			Where("id = ?", trade.WalletID).
-			Update("balance", gorm.Expr("balance + ?", payout))
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the trade; a concurrent void/close wins if it got there first
+		res := tx.Model(&models.Trade{}).
+			Where("id = ? AND status = ?", trade.ID, "OPEN").
+			Updates(map[string]interface{}{
+				"status":     result,
+				"exit_price": exitPrice,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errTradeNotOpen
+		}
+		if payout == 0 {
+			return nil
+		}
 
-		config.DB.Create(&models.WalletTransaction{
+		if err := tx.Model(&models.Wallet{}).
+			Where("id = ?", trade.WalletID).
+			Update("balance", gorm.Expr("balance + ?", payout)).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WalletTransaction{
 			WalletID:  trade.WalletID,
 			Amount:    payout,
 			Type:      "trade_win",
 			Reference: fmt.Sprintf("Trade #%d", trade.ID),
+			TradeID:   &trade.ID,
 			CreatedAt: time.Now(),
-		})
-	} else {
-		result = "LOST"
+		}).Error
+	})
+	if err != nil {
+		return
 	}
 
-	// Update trade status & exit price
-	config.DB.Model(&trade).Updates(map[string]interface{}{
-		"status":     result,
-		"exit_price": exitPrice,
+	RecordAudit(AuditEntry{
+		Action:     AuditTradeSettle,
+		TargetType: "trade",
+		TargetID:   trade.ID,
+		Before:     map[string]interface{}{"status": "OPEN"},
+		After:      map[string]interface{}{"status": result, "exit_price": exitPrice, "payout": payout},
 	})
 
 	// TODO: Push WebSocket notification to frontend
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"github.com/solchef/crypto-options-backend/utils"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// ReplaceRecoveryCodes discards the user's existing recovery codes and returns a
+// fresh set. The plaintext codes are only ever returned here.
+func ReplaceRecoveryCodes(userID uint) ([]string, error) {
+	codes, err := utils.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for _, code := range codes {
+			if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// SetTOTPSecret stores a new TOTP secret for the user, encrypted, ready to be
+// confirmed with a first code
+func SetTOTPSecret(userID uint, secret string) error {
+	sealed, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return err
+	}
+	return config.DB.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": sealed, "totp_last_step": 0}).Error
+}
+
+// EncryptTOTPSecrets encrypts TOTP secrets stored in plaintext before secrets
+// were sealed. Run at startup; already encrypted secrets are left alone.
+func EncryptTOTPSecrets() {
+	var users []models.User
+	if err := config.DB.Select("id", "totp_secret").Where("totp_secret <> ''").Find(&users).Error; err != nil {
+		log.Println("encrypt TOTP secrets:", err)
+		return
+	}
+	for _, u := range users {
+		if _, err := utils.DecryptSecret(u.TOTPSecret); err == nil || !utils.ValidTOTPSecret(u.TOTPSecret) {
+			continue
+		}
+		sealed, err := utils.EncryptSecret(u.TOTPSecret)
+		if err != nil {
+			log.Println("encrypt TOTP secrets:", err)
+			return
+		}
+		config.DB.Model(&models.User{}).Where("id = ? AND totp_secret = ?", u.ID, u.TOTPSecret).
+			Update("totp_secret", sealed)
+	}
+}
+
+// VerifyTOTP checks a code against the user's secret and records the accepted
+// time step so the same code can't be replayed.
+func VerifyTOTP(user *models.User, code string) bool {
+	if user.TOTPSecret == "" {
+		return false
+	}
+	secret, err := utils.DecryptSecret(user.TOTPSecret)
+	if err != nil {
+		log.Printf("TOTP secret of user %d: %v", user.ID, err)
+		return false
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false
+	}
+	// Conditional update so two concurrent requests can't both use one step
+	res := config.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	user.TOTPLastStep = step
+	return true
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused recovery
+// code (which is consumed).
+func VerifySecondFactor(user *models.User, code string) bool {
+	if !user.TOTPEnabled {
+		return false
+	}
+	if VerifyTOTP(user, code) {
+		return true
+	}
+	res := config.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected > 0
+}
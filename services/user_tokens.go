@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/solchef/crypto-options-backend/config"
+	"github.com/solchef/crypto-options-backend/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+var userTokenTTL = map[string]time.Duration{
+	models.TokenPurposeEmailVerify:   48 * time.Hour,
+	models.TokenPurposePasswordReset: time.Hour,
+	models.TokenPurposeEmailChange:   24 * time.Hour,
+}
+
+func hashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueUserToken creates a single-use token for purpose, invalidating any
+// earlier unused token of the same purpose for the user.
+func IssueUserToken(userID uint, purpose, email string) (string, time.Duration, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", 0, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	ttl := userTokenTTL[purpose]
+	now := time.Now()
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashUserToken(token),
+			Email:     email,
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return token, ttl, nil
+}
+
+// ConsumeUserToken marks the token used and returns it, or ErrInvalidUserToken
+// if it is unknown, expired, already used or for a different purpose.
+func ConsumeUserToken(token, purpose string) (*models.UserToken, error) {
+	var ut models.UserToken
+	if err := config.DB.Where("token_hash = ? AND purpose = ?", hashUserToken(token), purpose).First(&ut).Error; err != nil {
+		return nil, ErrInvalidUserToken
+	}
+	if ut.UsedAt != nil || time.Now().After(ut.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+	// Conditional update keeps it single-use under concurrent requests
+	res := config.DB.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", ut.ID).
+		Update("used_at", time.Now())
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, ErrInvalidUserToken
+	}
+	return &ut, nil
+}
+
+// SendVerificationEmail issues an email_verify token and mails the link.
+func SendVerificationEmail(user *models.User) error {
+	token, ttl, err := IssueUserToken(user.ID, models.TokenPurposeEmailVerify, user.Email)
+	if err != nil {
+		return err
+	}
+	return SendTemplate(user.Email, "verify_email", map[string]interface{}{
+		"Username": user.Username,
+		"Link":     AppURL() + "/verify-email?token=" + token,
+		"TTL":      expiryText(ttl),
+	})
+}
+
+// SendPasswordResetEmail issues a password_reset token and mails the link.
+func SendPasswordResetEmail(user *models.User) error {
+	token, ttl, err := IssueUserToken(user.ID, models.TokenPurposePasswordReset, user.Email)
+	if err != nil {
+		return err
+	}
+	return SendTemplate(user.Email, "password_reset", map[string]interface{}{
+		"Username": user.Username,
+		"Link":     AppURL() + "/reset-password?token=" + token,
+		"TTL":      expiryText(ttl),
+	})
+}
+
+// RevokeAllRefreshTokens signs the user out of every session.
+func RevokeAllRefreshTokens(userID uint) error {
+	return config.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked = ?", userID, false).
+		Update("revoked", true).Error
+}
@@ -1,13 +1,21 @@
 package services
 
 import (
+	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/solchef/crypto-options-backend/indicators"
 )
 
+// maxStreamIndicators caps the indicators one /trading connection can follow
+const maxStreamIndicators = 5
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
@@ -18,7 +26,61 @@ type WSMessage struct {
 	Timestamp int64       `json:"timestamp"`
 }
 
+// marketClient is a /trading connection following one symbol
+type marketClient struct {
+	symbol string
+	depth  int // order book levels wanted; 0 for none
+	// indicators to stream on each closed candle of interval
+	interval   string
+	indicators []indicators.Spec
+	send       chan []byte
+}
+
+var marketClients = struct {
+	sync.Mutex
+	m map[*marketClient]bool
+}{m: map[*marketClient]bool{}}
+
+var marketFanOut sync.Once
+
+// ServeWS streams price updates for ?symbol= (default BTCUSDT) from the shared
+// market stream, plus "feed_status" messages whenever any symbol is halted or
+// resumes. With ?depth=N (1-100) it also sends the top N order book levels as
+// "depth" messages, at most every DepthPushInterval. Each ?indicator=type:params
+// (e.g. rsi:14, macd:12,26,9; up to 5) is sent as an "indicator" message as
+// candles of ?interval= (1s, 1m, 5m or 1h; default 1m) close. Slow clients
+// miss updates rather than holding up the stream.
 func ServeWS(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	symbol := strings.ToUpper(query.Get("symbol"))
+	if symbol == "" {
+		symbol = "BTCUSDT"
+	}
+	client := &marketClient{symbol: symbol, interval: query.Get("interval"), send: make(chan []byte, 64)}
+	if n, err := strconv.Atoi(query.Get("depth")); err == nil && n > 0 {
+		client.depth = min(n, MaxDepthLevels)
+	}
+	if client.interval == "" {
+		client.interval = "1m"
+	}
+	if _, ok := CandleResolutions[client.interval]; !ok {
+		http.Error(w, "interval must be one of 1s, 1m, 5m, 1h", http.StatusBadRequest)
+		return
+	}
+	if len(query["indicator"]) > maxStreamIndicators {
+		http.Error(w, "at most 5 indicators per connection", http.StatusBadRequest)
+		return
+	}
+	for _, v := range query["indicator"] {
+		typ, params, _ := strings.Cut(v, ":")
+		spec, err := indicators.Parse(typ, params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		client.indicators = append(client.indicators, spec)
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("WebSocket upgrade error:", err)
@@ -26,55 +88,166 @@ func ServeWS(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	// Example: hardcode symbol for now
-	symbol := "btcusdt"
-
-	// Channels for trade + stats
-	tradeCh := make(chan float64)
-	statsCh := make(chan map[string]float64)
+	marketFanOut.Do(func() { OnMarketTick(fanOutTick) })
+	if len(client.indicators) > 0 {
+		startIndicatorStream()
+	}
+	marketClients.Lock()
+	marketClients.m[client] = true
+	marketClients.Unlock()
+	defer func() {
+		marketClients.Lock()
+		delete(marketClients.m, client)
+		marketClients.Unlock()
+	}()
 
-	// Start Binance subscriptions
-	go ListenPriceStream(symbol, tradeCh)
-	go ListenTickerStream(symbol, statsCh)
+	// Reads only detect the client going away
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
 
-	// Keep last stats
-	var lastChange, lastChangePct float64
+	// Start with the symbol's current feed state so clients know if it is halted
+	if msg, err := json.Marshal(WSMessage{Type: "feed_status", Data: symbolFeedStatus(symbol), Timestamp: time.Now().UnixMilli()}); err == nil {
+		client.send <- msg
+	}
 
 	for {
 		select {
-		case price := <-tradeCh:
-			msg := WSMessage{
-				Type: "price_update",
-				Data: map[string]interface{}{
-					"symbol":       symbol,
-					"price":        price,
-					"change24h":    lastChange,
-					"change24hPct": lastChangePct,
-				},
-				Timestamp: time.Now().UnixMilli(),
-			}
-			if err := conn.WriteJSON(msg); err != nil {
+		case msg := <-client.send:
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
 				log.Println("write error:", err)
 				return
 			}
+		case <-done:
+			return
+		}
+	}
+}
 
-		case stats := <-statsCh:
-			lastChange = stats["change"]
-			lastChangePct = stats["changePct"]
-
-			// Optionally also push a stats-only update
-			statsMsg := WSMessage{
-				Type: "stats_update",
-				Data: map[string]interface{}{
-					"symbol":       symbol,
-					"change24h":    lastChange,
-					"change24hPct": lastChangePct,
-				},
-				Timestamp: time.Now().UnixMilli(),
+func fanOutTick(t MarketTick) {
+	stats := GetCurrentPrice(t.Symbol)
+	msg, err := json.Marshal(WSMessage{
+		Type: "price_update",
+		Data: map[string]interface{}{
+			"symbol":       t.Symbol,
+			"price":        t.Price,
+			"change24h":    stats.Change,
+			"change24hPct": stats.ChangePercent,
+		},
+		Timestamp: t.Time.UnixMilli(),
+	})
+	if err != nil {
+		return
+	}
+
+	marketClients.Lock()
+	defer marketClients.Unlock()
+	for c := range marketClients.m {
+		if c.symbol == t.Symbol {
+			select {
+			case c.send <- msg:
+			default:
 			}
-			if err := conn.WriteJSON(statsMsg); err != nil {
-				log.Println("write error:", err)
-				return
+		}
+	}
+}
+
+// broadcastMarket sends msg to every /trading connection
+func broadcastMarket(msg string) {
+	marketClients.Lock()
+	defer marketClients.Unlock()
+	for c := range marketClients.m {
+		select {
+		case c.send <- []byte(msg):
+		default:
+		}
+	}
+}
+
+// depthSubscriptions maps each symbol with depth subscribers to the most
+// levels any of them wants
+func depthSubscriptions() map[string]int {
+	marketClients.Lock()
+	defer marketClients.Unlock()
+	want := map[string]int{}
+	for c := range marketClients.m {
+		if c.depth > want[c.symbol] {
+			want[c.symbol] = c.depth
+		}
+	}
+	return want
+}
+
+// sendDepth sends snap to the symbol's depth subscribers, cut to the levels each asked for
+func sendDepth(snap *DepthSnapshot) {
+	encoded := map[int][]byte{}
+	marketClients.Lock()
+	defer marketClients.Unlock()
+	for c := range marketClients.m {
+		if c.symbol != snap.Symbol || c.depth == 0 {
+			continue
+		}
+		msg, ok := encoded[c.depth]
+		if !ok {
+			cut := *snap
+			cut.Bids = cut.Bids[:min(c.depth, len(cut.Bids))]
+			cut.Asks = cut.Asks[:min(c.depth, len(cut.Asks))]
+			msg, _ = json.Marshal(WSMessage{Type: "depth", Data: cut, Timestamp: time.Now().UnixMilli()})
+			encoded[c.depth] = msg
+		}
+		select {
+		case c.send <- msg:
+		default:
+		}
+	}
+}
+
+// indicatorSubscriptions lists the distinct specs followed on symbol's interval candles
+func indicatorSubscriptions(symbol, interval string) []indicators.Spec {
+	marketClients.Lock()
+	defer marketClients.Unlock()
+	seen := map[string]bool{}
+	var specs []indicators.Spec
+	for c := range marketClients.m {
+		if c.symbol != symbol || c.interval != interval {
+			continue
+		}
+		for _, s := range c.indicators {
+			if !seen[s.String()] {
+				seen[s.String()] = true
+				specs = append(specs, s)
+			}
+		}
+	}
+	return specs
+}
+
+// sendIndicator sends u to the clients following its indicator
+func sendIndicator(u IndicatorUpdate) {
+	msg, err := json.Marshal(WSMessage{Type: "indicator", Data: u, Timestamp: time.Now().UnixMilli()})
+	if err != nil {
+		return
+	}
+	want := u.Indicator.String()
+	marketClients.Lock()
+	defer marketClients.Unlock()
+	for c := range marketClients.m {
+		if c.symbol != u.Symbol || c.interval != u.Interval {
+			continue
+		}
+		for _, s := range c.indicators {
+			if s.String() == want {
+				select {
+				case c.send <- msg:
+				default:
+				}
+				break
 			}
 		}
 	}
@@ -1,7 +1,21 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -11,61 +25,229 @@ import (
 var (
 	accessTTL  = time.Minute * 180
 	refreshTTL = time.Hour * 24 * 7
+	mfaTTL     = time.Minute * 5
 )
 
-func jwtSecret() []byte {
-	// set JWT_SECRET in env for prod
-	sec := os.Getenv("JWT_SECRET")
-	if sec == "" {
-		sec = "dev-super-secret"
+// Token types, carried in the "typ" claim and mirrored in "aud" so a token
+// minted for one purpose is rejected everywhere else.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeMFA     = "mfa"
+)
+
+// signingKey is one entry in the keyring; every key verifies, only the active one signs.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+var keyring struct {
+	sync.RWMutex
+	keys   map[string]*signingKey
+	active *signingKey
+}
+
+// IsDevMode reports whether APP_ENV is development; only then are ephemeral keys allowed.
+func IsDevMode() bool {
+	env := strings.ToLower(os.Getenv("APP_ENV"))
+	return env == "development" || env == "dev"
+}
+
+func jwtIssuer() string {
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		return v
+	}
+	return "crypto-options-backend"
+}
+
+// InitJWTKeys loads every PEM private key (RSA or Ed25519, PKCS#8 or PKCS#1) in
+// JWT_KEYS_DIR; the file name without extension is the kid. JWT_ACTIVE_KID picks
+// the signing key, defaulting to the last kid in sort order, so rotating means
+// dropping in a new file and retiring the old one once its tokens have expired.
+// Without keys it fails, unless APP_ENV=development where an ephemeral key is used.
+func InitJWTKeys() error {
+	keys := make(map[string]*signingKey)
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			raw, err := os.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			kid := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+			key, err := parseSigningKey(kid, raw)
+			if err != nil {
+				return fmt.Errorf("%s: %w", f, err)
+			}
+			keys[kid] = key
+		}
+	}
+
+	if len(keys) == 0 {
+		if !IsDevMode() {
+			return errors.New("no JWT signing keys found: set JWT_KEYS_DIR (or APP_ENV=development for an ephemeral key)")
+		}
+		_, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return err
+		}
+		kid := "dev-" + uuid.NewString()[:8]
+		keys[kid] = &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, private: priv}
+		log.Println("⚠️ Using an ephemeral JWT signing key; tokens won't survive a restart")
+	}
+
+	activeKID := os.Getenv("JWT_ACTIVE_KID")
+	if activeKID == "" {
+		kids := make([]string, 0, len(keys))
+		for kid := range keys {
+			kids = append(kids, kid)
+		}
+		sort.Strings(kids)
+		activeKID = kids[len(kids)-1]
+	}
+	active, ok := keys[activeKID]
+	if !ok {
+		return fmt.Errorf("JWT_ACTIVE_KID %q not found in JWT_KEYS_DIR", activeKID)
+	}
+
+	keyring.Lock()
+	keyring.keys = keys
+	keyring.active = active
+	keyring.Unlock()
+	return nil
+}
+
+func parseSigningKey(kid string, raw []byte) (*signingKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &signingKey{kid: kid, method: jwt.SigningMethodRS256, private: k}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{kid: kid, method: jwt.SigningMethodEdDSA, private: k}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", parsed)
+}
+
+// sign signs claims with the active key and sets the kid header.
+func sign(claims jwt.Claims) (string, error) {
+	keyring.RLock()
+	key := keyring.active
+	keyring.RUnlock()
+	if key == nil {
+		return "", errors.New("JWT keys not initialised")
+	}
+	tok := jwt.NewWithClaims(key.method, claims)
+	tok.Header["kid"] = key.kid
+	return tok.SignedString(key.private)
+}
+
+// keyFunc resolves the verification key by kid and insists the token's alg
+// matches that key, so an attacker can't pick "none" or an HMAC alg.
+func keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	keyring.RLock()
+	key := keyring.keys[kid]
+	keyring.RUnlock()
+	if key == nil {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+	return key.private.Public(), nil
+}
+
+func registered(now, exp time.Time, typ, id string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    jwtIssuer(),
+		Audience:  jwt.ClaimStrings{typ},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(exp),
+		ID:        id,
+	}
+}
+
+// checkType enforces typ, aud and iss after signature/expiry validation.
+func checkType(rc *jwt.RegisteredClaims, typ, want string) error {
+	if typ != want || !rc.VerifyAudience(want, true) || !rc.VerifyIssuer(jwtIssuer(), true) {
+		return jwt.ErrTokenInvalidClaims
 	}
-	return []byte(sec)
+	return nil
 }
 
 type AccessClaims struct {
 	Sub      uint   `json:"sub"`
 	Username string `json:"username"`
+	Typ      string `json:"typ"`
 	jwt.RegisteredClaims
 }
 
 type RefreshClaims struct {
 	Sub uint   `json:"sub"`
 	JTI string `json:"jti"`
+	Typ string `json:"typ"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(username string) (string, error) {
-	claims := jwt.MapClaims{}
-	claims["authorized"] = true
-	claims["username"] = username
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret())
-}
-
-func ValidateJWT(tokenString string) (*jwt.Token, error) {
-	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret(), nil
-	})
+// MFAClaims identify a user who passed the password step but not yet the OTP step
+type MFAClaims struct {
+	Sub uint   `json:"sub"`
+	Typ string `json:"typ"`
+	jwt.RegisteredClaims
 }
 
 func NewAccessToken(userID uint, username string) (string, time.Time, error) {
 	now := time.Now()
 	exp := now.Add(accessTTL)
 	claims := AccessClaims{
-		Sub:      userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(exp),
-		},
-	}
-	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := tok.SignedString(jwtSecret())
+		Sub:              userID,
+		Username:         username,
+		Typ:              TokenTypeAccess,
+		RegisteredClaims: registered(now, exp, TokenTypeAccess, ""),
+	}
+	signed, err := sign(claims)
 	return signed, exp, err
 }
 
+func ParseAccessToken(tokenStr string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if err := checkType(&claims.RegisteredClaims, claims.Typ, TokenTypeAccess); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 // returns: token string, jti, expiry, error
 func NewRefreshToken(userID uint) (string, string, time.Time, error) {
 	now := time.Now()
@@ -73,29 +255,92 @@ func NewRefreshToken(userID uint) (string, string, time.Time, error) {
 	jti := uuid.NewString()
 
 	claims := RefreshClaims{
-		Sub: userID,
-		JTI: jti,
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(exp),
-			ID:        jti,
-		},
-	}
-	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := tok.SignedString(jwtSecret())
+		Sub:              userID,
+		JTI:              jti,
+		Typ:              TokenTypeRefresh,
+		RegisteredClaims: registered(now, exp, TokenTypeRefresh, jti),
+	}
+	signed, err := sign(claims)
 	return signed, jti, exp, err
 }
 
 func ParseRefreshToken(tokenStr string) (*RefreshClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &RefreshClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret(), nil
-	})
+	claims := &RefreshClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if err := checkType(&claims.RegisteredClaims, claims.Typ, TokenTypeRefresh); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func NewMFAChallengeToken(userID uint) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(mfaTTL)
+	claims := MFAClaims{
+		Sub:              userID,
+		Typ:              TokenTypeMFA,
+		RegisteredClaims: registered(now, exp, TokenTypeMFA, ""),
+	}
+	signed, err := sign(claims)
+	return signed, exp, err
+}
+
+func ParseMFAChallengeToken(tokenStr string) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keyFunc)
 	if err != nil {
 		return nil, err
 	}
-	claims, ok := token.Claims.(*RefreshClaims)
-	if !ok || !token.Valid {
+	if !token.Valid {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
+	if err := checkType(&claims.RegisteredClaims, claims.Typ, TokenTypeMFA); err != nil {
+		return nil, err
+	}
 	return claims, nil
 }
+
+// JWK is a public key in RFC 7517 form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public half of every key in the keyring.
+func JWKS() []JWK {
+	keyring.RLock()
+	defer keyring.RUnlock()
+
+	b64 := base64.RawURLEncoding
+	out := make([]JWK, 0, len(keyring.keys))
+	for kid, k := range keyring.keys {
+		jwk := JWK{Kid: kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = b64.EncodeToString(pub.N.Bytes())
+			jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = b64.EncodeToString(pub)
+		default:
+			continue
+		}
+		out = append(out, jwk)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kid < out[j].Kid })
+	return out
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+)
+
+// encryptionKey returns the 32-byte AES key from SECRET_ENCRYPTION_KEY (base64).
+// In dev mode a fixed, insecure key is used when it's unset.
+func encryptionKey() ([]byte, error) {
+	if v := os.Getenv("SECRET_ENCRYPTION_KEY"); v != "" {
+		key, err := base64.StdEncoding.DecodeString(v)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("SECRET_ENCRYPTION_KEY must be 32 bytes, base64-encoded")
+		}
+		return key, nil
+	}
+	if IsDevMode() {
+		sum := sha256.Sum256([]byte("dev-only-secret-encryption-key"))
+		return sum[:], nil
+	}
+	return nil, errors.New("SECRET_ENCRYPTION_KEY is not set")
+}
+
+// EncryptSecret seals plaintext with AES-256-GCM; output is base64(nonce|ciphertext).
+func EncryptSecret(plaintext string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+// DecryptSecret reverses EncryptSecret.
+func DecryptSecret(sealed string) (string, error) {
+	key, err := encryptionKey()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	plain, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}